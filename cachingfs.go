@@ -0,0 +1,435 @@
+package vfs
+
+import (
+	"container/list"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// A CachingFSOption sets an option on a CachingFS.
+type CachingFSOption func(*CachingFS)
+
+// A CachingFS operates on an existing FS and caches the results of Lstat,
+// ReadDir, ReadFile, Readlink, and Stat, including fs.ErrNotExist errors, for a
+// limited time. Modifications made through a CachingFS invalidate the cached
+// results for the modified path, its descendants, and its parent directory's
+// ReadDir. Modifications made by other means, including writes to *os.Files
+// returned by Create and OpenFile and changes to the targets of symlinks
+// followed by Stat, are only seen once the cached results expire or are
+// removed with Invalidate.
+type CachingFS struct {
+	fileSystem FS
+	ttl        time.Duration
+	maxEntries int
+	maxBytes   int64
+
+	mu         sync.Mutex
+	entries    map[cacheKey]*list.Element
+	lru        *list.List
+	bytes      int64
+	generation uint64
+}
+
+// A cacheKey identifies a cached result.
+type cacheKey struct {
+	method string
+	name   string
+}
+
+// A cacheEntry is a cached result.
+type cacheEntry struct {
+	key     cacheKey
+	value   any
+	err     error
+	size    int64
+	expires time.Time
+}
+
+// CachingFSMaxBytes sets the maximum total size of the file contents cached by
+// a CachingFS. Files larger than maxBytes are not cached. The default is 16MiB.
+func CachingFSMaxBytes(maxBytes int64) CachingFSOption {
+	return func(c *CachingFS) {
+		c.maxBytes = maxBytes
+	}
+}
+
+// CachingFSMaxEntries sets the maximum number of results cached by a CachingFS.
+// When the limit is reached, the least recently used results are evicted. The
+// default is 4096.
+func CachingFSMaxEntries(maxEntries int) CachingFSOption {
+	return func(c *CachingFS) {
+		c.maxEntries = maxEntries
+	}
+}
+
+// CachingFSTTL sets the time for which a CachingFS caches results. The default
+// is one second.
+func CachingFSTTL(ttl time.Duration) CachingFSOption {
+	return func(c *CachingFS) {
+		c.ttl = ttl
+	}
+}
+
+// NewCachingFS returns a new *CachingFS operating on fileSystem with the given
+// options set.
+func NewCachingFS(fileSystem FS, options ...CachingFSOption) *CachingFS {
+	c := &CachingFS{
+		fileSystem: fileSystem,
+		ttl:        time.Second,
+		maxEntries: 4096,
+		maxBytes:   16 << 20,
+		entries:    make(map[cacheKey]*list.Element),
+		lru:        list.New(),
+	}
+	for _, option := range options {
+		option(c)
+	}
+	return c
+}
+
+// Chmod implements os.Chmod.
+func (c *CachingFS) Chmod(name string, mode fs.FileMode) error {
+	defer c.Invalidate(name)
+	return c.fileSystem.Chmod(name, mode)
+}
+
+// Chown implements os.Chown.
+func (c *CachingFS) Chown(name string, uid, gid int) error {
+	defer c.Invalidate(name)
+	return c.fileSystem.Chown(name, uid, gid)
+}
+
+// Chtimes implements os.Chtimes.
+func (c *CachingFS) Chtimes(name string, atime, mtime time.Time) error {
+	defer c.Invalidate(name)
+	return c.fileSystem.Chtimes(name, atime, mtime)
+}
+
+// Create implements os.Create.
+func (c *CachingFS) Create(name string) (*os.File, error) {
+	defer c.Invalidate(name)
+	return c.fileSystem.Create(name)
+}
+
+// DefaultTempDir implements DefaultTempDirer.DefaultTempDir by returning
+// TempDir(c's underlying FS).
+func (c *CachingFS) DefaultTempDir() string {
+	return TempDir(c.fileSystem)
+}
+
+// Getxattr implements XattrFS.Getxattr if c's underlying FS implements XattrFS.
+func (c *CachingFS) Getxattr(name, attr string) ([]byte, error) {
+	xattrFS, ok := c.fileSystem.(XattrFS)
+	if !ok {
+		return nil, unsupportedError("Getxattr", name)
+	}
+	return xattrFS.Getxattr(name, attr)
+}
+
+// Glob implements filepath.Glob.
+func (c *CachingFS) Glob(pattern string) ([]string, error) {
+	return c.fileSystem.Glob(pattern)
+}
+
+// Invalidate removes all cached results for name and its descendants, and the
+// cached ReadDir result for name's parent directory.
+func (c *CachingFS) Invalidate(name string) {
+	name = filepath.Clean(name)
+	prefix := name
+	if !strings.HasSuffix(prefix, string(filepath.Separator)) {
+		prefix += string(filepath.Separator)
+	}
+	parent := cacheKey{
+		method: "ReadDir",
+		name:   filepath.Dir(name),
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.generation++
+	for key, element := range c.entries {
+		if key.name == name || strings.HasPrefix(key.name, prefix) || key == parent {
+			c.removeLocked(element)
+		}
+	}
+}
+
+// Lchown implements os.Lchown.
+func (c *CachingFS) Lchown(name string, uid, gid int) error {
+	defer c.Invalidate(name)
+	return c.fileSystem.Lchown(name, uid, gid)
+}
+
+// Lgetxattr implements XattrFS.Lgetxattr if c's underlying FS implements
+// XattrFS.
+func (c *CachingFS) Lgetxattr(name, attr string) ([]byte, error) {
+	xattrFS, ok := c.fileSystem.(XattrFS)
+	if !ok {
+		return nil, unsupportedError("Lgetxattr", name)
+	}
+	return xattrFS.Lgetxattr(name, attr)
+}
+
+// Link implements os.Link.
+func (c *CachingFS) Link(oldname, newname string) error {
+	defer c.Invalidate(oldname)
+	defer c.Invalidate(newname)
+	return c.fileSystem.Link(oldname, newname)
+}
+
+// Listxattr implements XattrFS.Listxattr if c's underlying FS implements
+// XattrFS.
+func (c *CachingFS) Listxattr(name string) ([]string, error) {
+	xattrFS, ok := c.fileSystem.(XattrFS)
+	if !ok {
+		return nil, unsupportedError("Listxattr", name)
+	}
+	return xattrFS.Listxattr(name)
+}
+
+// Llistxattr implements XattrFS.Llistxattr if c's underlying FS implements
+// XattrFS.
+func (c *CachingFS) Llistxattr(name string) ([]string, error) {
+	xattrFS, ok := c.fileSystem.(XattrFS)
+	if !ok {
+		return nil, unsupportedError("Llistxattr", name)
+	}
+	return xattrFS.Llistxattr(name)
+}
+
+// Lremovexattr implements XattrFS.Lremovexattr if c's underlying FS implements
+// XattrFS.
+func (c *CachingFS) Lremovexattr(name, attr string) error {
+	xattrFS, ok := c.fileSystem.(XattrFS)
+	if !ok {
+		return unsupportedError("Lremovexattr", name)
+	}
+	return xattrFS.Lremovexattr(name, attr)
+}
+
+// Lsetxattr implements XattrFS.Lsetxattr if c's underlying FS implements
+// XattrFS.
+func (c *CachingFS) Lsetxattr(name, attr string, value []byte) error {
+	xattrFS, ok := c.fileSystem.(XattrFS)
+	if !ok {
+		return unsupportedError("Lsetxattr", name)
+	}
+	return xattrFS.Lsetxattr(name, attr, value)
+}
+
+// Lstat implements os.Lstat.
+func (c *CachingFS) Lstat(name string) (fs.FileInfo, error) {
+	value, err := c.cached("Lstat", name, func() (any, int64, error) {
+		info, err := c.fileSystem.Lstat(name)
+		return info, 0, err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return value.(fs.FileInfo), nil //nolint:forcetypeassert
+}
+
+// Mkdir implements os.Mkdir.
+func (c *CachingFS) Mkdir(name string, perm fs.FileMode) error {
+	defer c.Invalidate(name)
+	return c.fileSystem.Mkdir(name, perm)
+}
+
+// Open implements os.Open.
+func (c *CachingFS) Open(name string) (fs.File, error) {
+	return c.fileSystem.Open(name)
+}
+
+// OpenFile implements os.OpenFile.
+func (c *CachingFS) OpenFile(name string, flag int, perm fs.FileMode) (*os.File, error) {
+	if flag&(os.O_WRONLY|os.O_RDWR|os.O_APPEND|os.O_CREATE|os.O_TRUNC) != 0 {
+		defer c.Invalidate(name)
+	}
+	return c.fileSystem.OpenFile(name, flag, perm)
+}
+
+// PathSeparator implements PathSeparator.
+func (c *CachingFS) PathSeparator() rune {
+	return c.fileSystem.PathSeparator()
+}
+
+// RawPath implements RawPath.
+func (c *CachingFS) RawPath(path string) (string, error) {
+	return c.fileSystem.RawPath(path)
+}
+
+// ReadDir implements os.ReadDir.
+func (c *CachingFS) ReadDir(dirname string) ([]fs.DirEntry, error) {
+	value, err := c.cached("ReadDir", dirname, func() (any, int64, error) {
+		dirEntries, err := c.fileSystem.ReadDir(dirname)
+		return dirEntries, 0, err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return append([]fs.DirEntry(nil), value.([]fs.DirEntry)...), nil //nolint:forcetypeassert
+}
+
+// ReadFile implements os.ReadFile.
+func (c *CachingFS) ReadFile(filename string) ([]byte, error) {
+	value, err := c.cached("ReadFile", filename, func() (any, int64, error) {
+		data, err := c.fileSystem.ReadFile(filename)
+		return data, int64(len(data)), err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte(nil), value.([]byte)...), nil //nolint:forcetypeassert
+}
+
+// Readlink implements os.Readlink.
+func (c *CachingFS) Readlink(name string) (string, error) {
+	value, err := c.cached("Readlink", name, func() (any, int64, error) {
+		target, err := c.fileSystem.Readlink(name)
+		return target, 0, err
+	})
+	if err != nil {
+		return "", err
+	}
+	return value.(string), nil //nolint:forcetypeassert
+}
+
+// Remove implements os.Remove.
+func (c *CachingFS) Remove(name string) error {
+	defer c.Invalidate(name)
+	return c.fileSystem.Remove(name)
+}
+
+// RemoveAll implements os.RemoveAll.
+func (c *CachingFS) RemoveAll(name string) error {
+	defer c.Invalidate(name)
+	return c.fileSystem.RemoveAll(name)
+}
+
+// Removexattr implements XattrFS.Removexattr if c's underlying FS implements
+// XattrFS.
+func (c *CachingFS) Removexattr(name, attr string) error {
+	xattrFS, ok := c.fileSystem.(XattrFS)
+	if !ok {
+		return unsupportedError("Removexattr", name)
+	}
+	return xattrFS.Removexattr(name, attr)
+}
+
+// Rename implements os.Rename.
+func (c *CachingFS) Rename(oldpath, newpath string) error {
+	defer c.Invalidate(oldpath)
+	defer c.Invalidate(newpath)
+	return c.fileSystem.Rename(oldpath, newpath)
+}
+
+// Setxattr implements XattrFS.Setxattr if c's underlying FS implements XattrFS.
+func (c *CachingFS) Setxattr(name, attr string, value []byte) error {
+	xattrFS, ok := c.fileSystem.(XattrFS)
+	if !ok {
+		return unsupportedError("Setxattr", name)
+	}
+	return xattrFS.Setxattr(name, attr, value)
+}
+
+// Stat implements os.Stat.
+func (c *CachingFS) Stat(name string) (fs.FileInfo, error) {
+	value, err := c.cached("Stat", name, func() (any, int64, error) {
+		info, err := c.fileSystem.Stat(name)
+		return info, 0, err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return value.(fs.FileInfo), nil //nolint:forcetypeassert
+}
+
+// Statfs implements Statfser.Statfs if c's underlying FS implements Statfser.
+func (c *CachingFS) Statfs(name string) (*FSStat, error) {
+	statfser, ok := c.fileSystem.(Statfser)
+	if !ok {
+		return nil, unsupportedError("Statfs", name)
+	}
+	return statfser.Statfs(name)
+}
+
+// Symlink implements os.Symlink.
+func (c *CachingFS) Symlink(oldname, newname string) error {
+	defer c.Invalidate(newname)
+	return c.fileSystem.Symlink(oldname, newname)
+}
+
+// Truncate implements os.Truncate.
+func (c *CachingFS) Truncate(name string, size int64) error {
+	defer c.Invalidate(name)
+	return c.fileSystem.Truncate(name, size)
+}
+
+// WriteFile implements os.WriteFile.
+func (c *CachingFS) WriteFile(filename string, data []byte, perm fs.FileMode) error {
+	defer c.Invalidate(filename)
+	return c.fileSystem.WriteFile(filename, data, perm)
+}
+
+// cached returns the cached result of method on name, calling f to compute it
+// if there is no unexpired cached result. f returns the value, its size in
+// bytes, and any error.
+func (c *CachingFS) cached(method, name string, f func() (any, int64, error)) (any, error) {
+	key := cacheKey{
+		method: method,
+		name:   filepath.Clean(name),
+	}
+
+	c.mu.Lock()
+	if element, ok := c.entries[key]; ok {
+		entry := element.Value.(*cacheEntry) //nolint:forcetypeassert
+		if time.Now().Before(entry.expires) {
+			c.lru.MoveToFront(element)
+			c.mu.Unlock()
+			return entry.value, entry.err
+		}
+		c.removeLocked(element)
+	}
+	generation := c.generation
+	c.mu.Unlock()
+
+	value, size, err := f()
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return nil, err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	// Do not cache the result if there was an invalidation while it was being
+	// computed, as it might be stale.
+	if c.generation != generation || size > c.maxBytes {
+		return value, err
+	}
+	if element, ok := c.entries[key]; ok {
+		c.removeLocked(element)
+	}
+	c.entries[key] = c.lru.PushFront(&cacheEntry{
+		key:     key,
+		value:   value,
+		err:     err,
+		size:    size,
+		expires: time.Now().Add(c.ttl),
+	})
+	c.bytes += size
+	for c.lru.Len() > 0 && (c.lru.Len() > c.maxEntries || c.bytes > c.maxBytes) {
+		c.removeLocked(c.lru.Back())
+	}
+	return value, err
+}
+
+// removeLocked removes element from c. c.mu must be held.
+func (c *CachingFS) removeLocked(element *list.Element) {
+	entry := c.lru.Remove(element).(*cacheEntry) //nolint:forcetypeassert
+	delete(c.entries, entry.key)
+	c.bytes -= entry.size
+}
@@ -0,0 +1,11 @@
+package vfs_test
+
+import "github.com/twpayne/go-vfs/v5"
+
+var _ vfs.FS = &vfs.CachingFS{}
+
+var _ vfs.XattrFS = &vfs.CachingFS{}
+
+var _ vfs.Statfser = &vfs.CachingFS{}
+
+var _ vfs.DefaultTempDirer = &vfs.CachingFS{}
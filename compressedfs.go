@@ -0,0 +1,522 @@
+package vfs
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// Compressed file format constants. A compressed file consists of a header
+// containing a magic number and the uncompressed size, followed by the
+// compressed data.
+const (
+	compressedMagic      = "VFZ\x01"
+	compressedHeaderSize = len(compressedMagic) + 8
+)
+
+// errCompressedSize is returned when the size of decompressed data does not
+// match the size recorded in its header.
+var errCompressedSize = errors.New("decompressed size mismatch")
+
+// A CompressionCodec compresses and decompresses data for a CompressedFS.
+type CompressionCodec interface {
+	NewReader(r io.Reader) (io.ReadCloser, error)
+	NewWriter(w io.Writer) (io.WriteCloser, error)
+}
+
+// A CompressedFSOption sets an option on a CompressedFS.
+type CompressedFSOption func(*CompressedFS)
+
+// A CompressedFS operates on an existing FS and stores the contents of regular
+// files compressed. A policy function chooses which paths are compressed.
+// Files at compressed paths are written with WriteFile and read with ReadFile
+// and Open, and Create and OpenFile return errors wrapping
+// errors.ErrUnsupported for them, since writes to *os.Files cannot be
+// intercepted. Files at other paths are passed through unchanged. Stat, Lstat,
+// and ReadDir report uncompressed sizes, which requires opening each regular
+// file at a compressed path to read its header. Files that cannot be opened
+// for lack of permission are reported with their stored sizes. Files at
+// compressed paths that were not written by a CompressedFS are read unchanged.
+//
+// The policy is applied to the names passed to the CompressedFS, so a symlink
+// and its target should either both be compressed or both not be compressed.
+type CompressedFS struct {
+	fileSystem FS
+	codec      CompressionCodec
+	policy     func(string) bool
+}
+
+// A gzipCodec is a CompressionCodec that uses gzip.
+type gzipCodec struct {
+	level int
+}
+
+// CompressedFSCodec sets the CompressionCodec used by a CompressedFS. The
+// default is NewGzipCodec(gzip.DefaultCompression). Files written with one
+// codec cannot be read with another.
+func CompressedFSCodec(codec CompressionCodec) CompressedFSOption {
+	return func(c *CompressedFS) {
+		c.codec = codec
+	}
+}
+
+// CompressedFSPolicy sets the function that a CompressedFS uses to choose
+// whether the file at a path is compressed. The default is to compress all
+// files.
+func CompressedFSPolicy(policy func(name string) bool) CompressedFSOption {
+	return func(c *CompressedFS) {
+		c.policy = policy
+	}
+}
+
+// NewCompressedFS returns a new *CompressedFS operating on fileSystem with the
+// given options set.
+func NewCompressedFS(fileSystem FS, options ...CompressedFSOption) *CompressedFS {
+	c := &CompressedFS{
+		fileSystem: fileSystem,
+		codec:      NewGzipCodec(gzip.DefaultCompression),
+		policy: func(string) bool {
+			return true
+		},
+	}
+	for _, option := range options {
+		option(c)
+	}
+	return c
+}
+
+// NewGzipCodec returns a new CompressionCodec that uses gzip with level.
+func NewGzipCodec(level int) CompressionCodec {
+	return gzipCodec{
+		level: level,
+	}
+}
+
+// Chmod implements os.Chmod.
+func (c *CompressedFS) Chmod(name string, mode fs.FileMode) error {
+	return c.fileSystem.Chmod(name, mode)
+}
+
+// Chown implements os.Chown.
+func (c *CompressedFS) Chown(name string, uid, gid int) error {
+	return c.fileSystem.Chown(name, uid, gid)
+}
+
+// Chtimes implements os.Chtimes.
+func (c *CompressedFS) Chtimes(name string, atime, mtime time.Time) error {
+	return c.fileSystem.Chtimes(name, atime, mtime)
+}
+
+// Create implements os.Create if name is not compressed.
+func (c *CompressedFS) Create(name string) (*os.File, error) {
+	if c.policy(name) {
+		return nil, unsupportedError("Create", name)
+	}
+	return c.fileSystem.Create(name)
+}
+
+// Glob implements filepath.Glob.
+func (c *CompressedFS) Glob(pattern string) ([]string, error) {
+	return c.fileSystem.Glob(pattern)
+}
+
+// Lchown implements os.Lchown.
+func (c *CompressedFS) Lchown(name string, uid, gid int) error {
+	return c.fileSystem.Lchown(name, uid, gid)
+}
+
+// Link implements os.Link. It returns an error wrapping errors.ErrUnsupported
+// if exactly one of oldname and newname is compressed.
+func (c *CompressedFS) Link(oldname, newname string) error {
+	if c.policy(oldname) != c.policy(newname) {
+		return unsupportedError("Link", newname)
+	}
+	return c.fileSystem.Link(oldname, newname)
+}
+
+// Lstat implements os.Lstat.
+func (c *CompressedFS) Lstat(name string) (fs.FileInfo, error) {
+	info, err := c.fileSystem.Lstat(name)
+	if err != nil {
+		return nil, err
+	}
+	return c.uncompressedFileInfo(name, info)
+}
+
+// Mkdir implements os.Mkdir.
+func (c *CompressedFS) Mkdir(name string, perm fs.FileMode) error {
+	return c.fileSystem.Mkdir(name, perm)
+}
+
+// Open implements os.Open. Compressed regular files are decompressed as they
+// are read, and reading them returns an error if their decompressed size does
+// not match the size in their header.
+func (c *CompressedFS) Open(name string) (fs.File, error) {
+	file, err := c.fileSystem.Open(name)
+	if err != nil || !c.policy(name) {
+		return file, err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+	if !info.Mode().IsRegular() {
+		return file, nil
+	}
+	header := make([]byte, compressedHeaderSize)
+	n, err := io.ReadFull(file, header)
+	switch {
+	case errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF):
+	case err != nil:
+		file.Close()
+		return nil, err
+	}
+	size, ok := parseCompressedHeader(header[:n])
+	if !ok {
+		return &compressedFile{
+			file:   file,
+			reader: io.MultiReader(bytes.NewReader(header[:n]), file),
+			info:   info,
+		}, nil
+	}
+	reader, err := c.codec.NewReader(file)
+	if err != nil {
+		file.Close()
+		return nil, &os.PathError{
+			Op:   "Open",
+			Path: name,
+			Err:  err,
+		}
+	}
+	return &compressedFile{
+		file: file,
+		reader: &compressedSizeReader{
+			reader: reader,
+			size:   size,
+		},
+		closer: reader,
+		info: &compressedFileInfo{
+			FileInfo: info,
+			size:     size,
+		},
+	}, nil
+}
+
+// OpenFile implements os.OpenFile if name is not compressed.
+func (c *CompressedFS) OpenFile(name string, flag int, perm fs.FileMode) (*os.File, error) {
+	if c.policy(name) {
+		return nil, unsupportedError("OpenFile", name)
+	}
+	return c.fileSystem.OpenFile(name, flag, perm)
+}
+
+// PathSeparator implements PathSeparator.
+func (c *CompressedFS) PathSeparator() rune {
+	return c.fileSystem.PathSeparator()
+}
+
+// RawPath implements RawPath.
+func (c *CompressedFS) RawPath(path string) (string, error) {
+	return c.fileSystem.RawPath(path)
+}
+
+// ReadDir implements os.ReadDir.
+func (c *CompressedFS) ReadDir(dirname string) ([]fs.DirEntry, error) {
+	dirEntries, err := c.fileSystem.ReadDir(dirname)
+	if err != nil {
+		return nil, err
+	}
+	for i, dirEntry := range dirEntries {
+		dirEntries[i] = &compressedDirEntry{
+			DirEntry:     dirEntry,
+			compressedFS: c,
+			name:         filepath.Join(dirname, dirEntry.Name()),
+		}
+	}
+	return dirEntries, nil
+}
+
+// ReadFile implements os.ReadFile.
+func (c *CompressedFS) ReadFile(filename string) ([]byte, error) {
+	data, err := c.fileSystem.ReadFile(filename)
+	if err != nil || !c.policy(filename) {
+		return data, err
+	}
+	data, err = c.decompress(data)
+	if err != nil {
+		return nil, &os.PathError{
+			Op:   "ReadFile",
+			Path: filename,
+			Err:  err,
+		}
+	}
+	return data, nil
+}
+
+// Readlink implements os.Readlink.
+func (c *CompressedFS) Readlink(name string) (string, error) {
+	return c.fileSystem.Readlink(name)
+}
+
+// Remove implements os.Remove.
+func (c *CompressedFS) Remove(name string) error {
+	return c.fileSystem.Remove(name)
+}
+
+// RemoveAll implements os.RemoveAll.
+func (c *CompressedFS) RemoveAll(name string) error {
+	return c.fileSystem.RemoveAll(name)
+}
+
+// Rename implements os.Rename. If oldpath is a regular file and exactly one of
+// oldpath and newpath is compressed, then the file is written to newpath with
+// the new compression and oldpath is removed.
+func (c *CompressedFS) Rename(oldpath, newpath string) error {
+	if c.policy(oldpath) == c.policy(newpath) {
+		return c.fileSystem.Rename(oldpath, newpath)
+	}
+	info, err := c.fileSystem.Lstat(oldpath)
+	if err != nil {
+		return err
+	}
+	if !info.Mode().IsRegular() {
+		return c.fileSystem.Rename(oldpath, newpath)
+	}
+	data, err := c.ReadFile(oldpath)
+	if err != nil {
+		return err
+	}
+	if err := c.WriteFile(newpath, data, info.Mode().Perm()); err != nil {
+		return err
+	}
+	return c.fileSystem.Remove(oldpath)
+}
+
+// Stat implements os.Stat.
+func (c *CompressedFS) Stat(name string) (fs.FileInfo, error) {
+	info, err := c.fileSystem.Stat(name)
+	if err != nil {
+		return nil, err
+	}
+	return c.uncompressedFileInfo(name, info)
+}
+
+// Symlink implements os.Symlink.
+func (c *CompressedFS) Symlink(oldname, newname string) error {
+	return c.fileSystem.Symlink(oldname, newname)
+}
+
+// Truncate implements os.Truncate. Compressed files are decompressed, truncated
+// or extended with zero bytes, and recompressed.
+func (c *CompressedFS) Truncate(name string, size int64) error {
+	if !c.policy(name) {
+		return c.fileSystem.Truncate(name, size)
+	}
+	info, err := c.fileSystem.Stat(name)
+	if err != nil {
+		return err
+	}
+	data, err := c.ReadFile(name)
+	if err != nil {
+		return err
+	}
+	if size < int64(len(data)) {
+		data = data[:size]
+	} else {
+		data = append(data, make([]byte, size-int64(len(data)))...)
+	}
+	return c.WriteFile(name, data, info.Mode().Perm())
+}
+
+// WriteFile implements os.WriteFile.
+func (c *CompressedFS) WriteFile(filename string, data []byte, perm fs.FileMode) error {
+	if !c.policy(filename) {
+		return c.fileSystem.WriteFile(filename, data, perm)
+	}
+	compressedData, err := c.compress(data)
+	if err != nil {
+		return &os.PathError{
+			Op:   "WriteFile",
+			Path: filename,
+			Err:  err,
+		}
+	}
+	return c.fileSystem.WriteFile(filename, compressedData, perm)
+}
+
+// compress returns data compressed with a header.
+func (c *CompressedFS) compress(data []byte) ([]byte, error) {
+	buffer := &bytes.Buffer{}
+	buffer.WriteString(compressedMagic)
+	_ = binary.Write(buffer, binary.BigEndian, uint64(len(data)))
+	writer, err := c.codec.NewWriter(buffer)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := writer.Write(data); err != nil {
+		return nil, err
+	}
+	if err := writer.Close(); err != nil {
+		return nil, err
+	}
+	return buffer.Bytes(), nil
+}
+
+// decompress returns the decompressed contents of data. If data does not have
+// a header then it is returned unchanged.
+func (c *CompressedFS) decompress(data []byte) ([]byte, error) {
+	size, ok := parseCompressedHeader(data)
+	if !ok {
+		return data, nil
+	}
+	reader, err := c.codec.NewReader(bytes.NewReader(data[compressedHeaderSize:]))
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+	decompressedData, err := io.ReadAll(reader)
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(decompressedData)) != size {
+		return nil, errCompressedSize
+	}
+	return decompressedData, nil
+}
+
+// uncompressedFileInfo returns info for name with the uncompressed size. It
+// opens name to read its header, and returns info unchanged if name cannot be
+// opened for lack of permission.
+func (c *CompressedFS) uncompressedFileInfo(name string, info fs.FileInfo) (fs.FileInfo, error) {
+	if !info.Mode().IsRegular() || !c.policy(name) {
+		return info, nil
+	}
+	file, err := c.fileSystem.Open(name)
+	switch {
+	case errors.Is(err, fs.ErrPermission):
+		return info, nil
+	case err != nil:
+		return nil, err
+	}
+	defer file.Close()
+	header := make([]byte, compressedHeaderSize)
+	n, err := io.ReadFull(file, header)
+	switch {
+	case errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF):
+	case err != nil:
+		return nil, err
+	}
+	size, ok := parseCompressedHeader(header[:n])
+	if !ok {
+		return info, nil
+	}
+	return &compressedFileInfo{
+		FileInfo: info,
+		size:     size,
+	}, nil
+}
+
+// A compressedDirEntry is an fs.DirEntry in a CompressedFS.
+type compressedDirEntry struct {
+	fs.DirEntry
+	compressedFS *CompressedFS
+	name         string
+}
+
+// Info implements fs.DirEntry.Info.
+func (d *compressedDirEntry) Info() (fs.FileInfo, error) {
+	info, err := d.DirEntry.Info()
+	if err != nil {
+		return nil, err
+	}
+	return d.compressedFS.uncompressedFileInfo(d.name, info)
+}
+
+// A compressedFile is a regular file in a CompressedFS open for reading.
+type compressedFile struct {
+	file   fs.File
+	reader io.Reader
+	closer io.Closer
+	info   fs.FileInfo
+}
+
+// Close implements fs.File.Close.
+func (f *compressedFile) Close() error {
+	var err error
+	if f.closer != nil {
+		err = f.closer.Close()
+	}
+	if closeErr := f.file.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+// Read implements fs.File.Read.
+func (f *compressedFile) Read(p []byte) (int, error) {
+	return f.reader.Read(p)
+}
+
+// Stat implements fs.File.Stat.
+func (f *compressedFile) Stat() (fs.FileInfo, error) {
+	return f.info, nil
+}
+
+// A compressedFileInfo is an fs.FileInfo in a CompressedFS.
+type compressedFileInfo struct {
+	fs.FileInfo
+	size int64
+}
+
+// Size implements fs.FileInfo.Size.
+func (i *compressedFileInfo) Size() int64 {
+	return i.size
+}
+
+// A compressedSizeReader is an io.Reader that returns errCompressedSize if the
+// number of bytes read from reader does not match size.
+type compressedSizeReader struct {
+	reader io.Reader
+	size   int64
+	n      int64
+}
+
+// Read implements io.Reader.Read.
+func (r *compressedSizeReader) Read(p []byte) (int, error) {
+	n, err := r.reader.Read(p)
+	r.n += int64(n)
+	switch {
+	case r.n > r.size:
+		return n, errCompressedSize
+	case errors.Is(err, io.EOF) && r.n != r.size:
+		return n, errCompressedSize
+	default:
+		return n, err
+	}
+}
+
+// NewReader implements CompressionCodec.NewReader.
+func (g gzipCodec) NewReader(r io.Reader) (io.ReadCloser, error) {
+	return gzip.NewReader(r)
+}
+
+// NewWriter implements CompressionCodec.NewWriter.
+func (g gzipCodec) NewWriter(w io.Writer) (io.WriteCloser, error) {
+	return gzip.NewWriterLevel(w, g.level)
+}
+
+// parseCompressedHeader returns the uncompressed size recorded in header, and
+// whether header is a valid header.
+func parseCompressedHeader(header []byte) (int64, bool) {
+	if len(header) < compressedHeaderSize || !bytes.HasPrefix(header, []byte(compressedMagic)) {
+		return 0, false
+	}
+	return int64(binary.BigEndian.Uint64(header[len(compressedMagic):compressedHeaderSize])), true //nolint:gosec
+}
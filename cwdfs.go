@@ -0,0 +1,352 @@
+package vfs
+
+import (
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// A CwdFS operates on an existing FS, but has a current working directory
+// against which relative names are resolved, so that code that accepts
+// relative paths, for example from command line arguments, can be tested on an
+// FS that only accepts absolute paths, like a PathFS. Relative symlink targets
+// are relative to the directory containing the symlink, not the current
+// working directory, and so are passed to and returned from the underlying FS
+// unchanged.
+type CwdFS struct {
+	fileSystem FS
+	mu         sync.RWMutex
+	cwd        string
+}
+
+// NewCwdFS returns a new *CwdFS operating on fileSystem with the current
+// working directory cwd. It returns an error if cwd is not absolute.
+func NewCwdFS(fileSystem FS, cwd string) (*CwdFS, error) {
+	if !isAbs(cwd) {
+		return nil, &os.PathError{
+			Op:   "NewCwdFS",
+			Path: cwd,
+			Err:  errRelativePath,
+		}
+	}
+	return &CwdFS{
+		fileSystem: fileSystem,
+		cwd:        filepath.Clean(cwd),
+	}, nil
+}
+
+// Chdir implements os.Chdir.
+func (c *CwdFS) Chdir(dir string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	absDir := c.absLocked(dir)
+	info, err := c.fileSystem.Stat(absDir)
+	if err != nil {
+		return err
+	}
+	if !info.IsDir() {
+		return &os.PathError{
+			Op:   "Chdir",
+			Path: dir,
+			Err:  syscall.ENOTDIR,
+		}
+	}
+	c.cwd = absDir
+	return nil
+}
+
+// Chmod implements os.Chmod.
+func (c *CwdFS) Chmod(name string, mode fs.FileMode) error {
+	return c.fileSystem.Chmod(c.abs(name), mode)
+}
+
+// Chown implements os.Chown.
+func (c *CwdFS) Chown(name string, uid, gid int) error {
+	return c.fileSystem.Chown(c.abs(name), uid, gid)
+}
+
+// Chtimes implements os.Chtimes.
+func (c *CwdFS) Chtimes(name string, atime, mtime time.Time) error {
+	return c.fileSystem.Chtimes(c.abs(name), atime, mtime)
+}
+
+// Create implements os.Create.
+func (c *CwdFS) Create(name string) (*os.File, error) {
+	return c.fileSystem.Create(c.abs(name))
+}
+
+// DefaultTempDir implements DefaultTempDirer.DefaultTempDir by returning
+// TempDir(c's underlying FS).
+func (c *CwdFS) DefaultTempDir() string {
+	return TempDir(c.fileSystem)
+}
+
+// Getwd implements os.Getwd.
+func (c *CwdFS) Getwd() (string, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.cwd, nil
+}
+
+// Getxattr implements XattrFS.Getxattr if c's underlying FS implements XattrFS.
+func (c *CwdFS) Getxattr(name, attr string) ([]byte, error) {
+	xattrFS, ok := c.fileSystem.(XattrFS)
+	if !ok {
+		return nil, unsupportedError("Getxattr", name)
+	}
+	return xattrFS.Getxattr(c.abs(name), attr)
+}
+
+// Glob implements filepath.Glob. As with filepath.Glob, if pattern is relative
+// then the returned matches are relative to the current working directory.
+func (c *CwdFS) Glob(pattern string) ([]string, error) {
+	if isAbs(pattern) {
+		return c.fileSystem.Glob(pattern)
+	}
+	c.mu.RLock()
+	cwd := c.cwd
+	c.mu.RUnlock()
+	matches, err := c.fileSystem.Glob(filepath.Join(cwd, pattern))
+	if err != nil {
+		return nil, err
+	}
+	for i, match := range matches {
+		matches[i], err = filepath.Rel(cwd, match)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return matches, nil
+}
+
+// Lchmod implements Lchmoder.Lchmod if c's underlying FS implements Lchmoder.
+func (c *CwdFS) Lchmod(name string, mode fs.FileMode) error {
+	lchmoder, ok := c.fileSystem.(Lchmoder)
+	if !ok {
+		return unsupportedError("Lchmod", name)
+	}
+	return lchmoder.Lchmod(c.abs(name), mode)
+}
+
+// Lchown implements os.Lchown.
+func (c *CwdFS) Lchown(name string, uid, gid int) error {
+	return c.fileSystem.Lchown(c.abs(name), uid, gid)
+}
+
+// Lchtimes implements Lchtimeser.Lchtimes if c's underlying FS implements
+// Lchtimeser.
+func (c *CwdFS) Lchtimes(name string, atime, mtime time.Time) error {
+	lchtimeser, ok := c.fileSystem.(Lchtimeser)
+	if !ok {
+		return unsupportedError("Lchtimes", name)
+	}
+	return lchtimeser.Lchtimes(c.abs(name), atime, mtime)
+}
+
+// Lgetxattr implements XattrFS.Lgetxattr if c's underlying FS implements
+// XattrFS.
+func (c *CwdFS) Lgetxattr(name, attr string) ([]byte, error) {
+	xattrFS, ok := c.fileSystem.(XattrFS)
+	if !ok {
+		return nil, unsupportedError("Lgetxattr", name)
+	}
+	return xattrFS.Lgetxattr(c.abs(name), attr)
+}
+
+// Link implements os.Link.
+func (c *CwdFS) Link(oldname, newname string) error {
+	return c.fileSystem.Link(c.abs(oldname), c.abs(newname))
+}
+
+// Listxattr implements XattrFS.Listxattr if c's underlying FS implements
+// XattrFS.
+func (c *CwdFS) Listxattr(name string) ([]string, error) {
+	xattrFS, ok := c.fileSystem.(XattrFS)
+	if !ok {
+		return nil, unsupportedError("Listxattr", name)
+	}
+	return xattrFS.Listxattr(c.abs(name))
+}
+
+// Llistxattr implements XattrFS.Llistxattr if c's underlying FS implements
+// XattrFS.
+func (c *CwdFS) Llistxattr(name string) ([]string, error) {
+	xattrFS, ok := c.fileSystem.(XattrFS)
+	if !ok {
+		return nil, unsupportedError("Llistxattr", name)
+	}
+	return xattrFS.Llistxattr(c.abs(name))
+}
+
+// Lock implements Locker.Lock if c's underlying FS implements Locker.
+func (c *CwdFS) Lock(name string, mode LockMode, wait bool) (*Lock, error) {
+	locker, ok := c.fileSystem.(Locker)
+	if !ok {
+		return nil, unsupportedError("Lock", name)
+	}
+	return locker.Lock(c.abs(name), mode, wait)
+}
+
+// Lremovexattr implements XattrFS.Lremovexattr if c's underlying FS implements
+// XattrFS.
+func (c *CwdFS) Lremovexattr(name, attr string) error {
+	xattrFS, ok := c.fileSystem.(XattrFS)
+	if !ok {
+		return unsupportedError("Lremovexattr", name)
+	}
+	return xattrFS.Lremovexattr(c.abs(name), attr)
+}
+
+// Lsetxattr implements XattrFS.Lsetxattr if c's underlying FS implements
+// XattrFS.
+func (c *CwdFS) Lsetxattr(name, attr string, value []byte) error {
+	xattrFS, ok := c.fileSystem.(XattrFS)
+	if !ok {
+		return unsupportedError("Lsetxattr", name)
+	}
+	return xattrFS.Lsetxattr(c.abs(name), attr, value)
+}
+
+// Lstat implements os.Lstat.
+func (c *CwdFS) Lstat(name string) (fs.FileInfo, error) {
+	return c.fileSystem.Lstat(c.abs(name))
+}
+
+// Mkdir implements os.Mkdir.
+func (c *CwdFS) Mkdir(name string, perm fs.FileMode) error {
+	return c.fileSystem.Mkdir(c.abs(name), perm)
+}
+
+// Open implements os.Open.
+func (c *CwdFS) Open(name string) (fs.File, error) {
+	return c.fileSystem.Open(c.abs(name))
+}
+
+// OpenFile implements os.OpenFile.
+func (c *CwdFS) OpenFile(name string, flag int, perm fs.FileMode) (*os.File, error) {
+	return c.fileSystem.OpenFile(c.abs(name), flag, perm)
+}
+
+// PathSeparator implements PathSeparator.
+func (c *CwdFS) PathSeparator() rune {
+	return c.fileSystem.PathSeparator()
+}
+
+// RawPath implements RawPath.
+func (c *CwdFS) RawPath(path string) (string, error) {
+	return c.fileSystem.RawPath(c.abs(path))
+}
+
+// ReadDir implements os.ReadDir.
+func (c *CwdFS) ReadDir(dirname string) ([]fs.DirEntry, error) {
+	return c.fileSystem.ReadDir(c.abs(dirname))
+}
+
+// ReadFile implements os.ReadFile.
+func (c *CwdFS) ReadFile(filename string) ([]byte, error) {
+	return c.fileSystem.ReadFile(c.abs(filename))
+}
+
+// Readlink implements os.Readlink.
+func (c *CwdFS) Readlink(name string) (string, error) {
+	return c.fileSystem.Readlink(c.abs(name))
+}
+
+// Remove implements os.Remove.
+func (c *CwdFS) Remove(name string) error {
+	return c.fileSystem.Remove(c.abs(name))
+}
+
+// RemoveAll implements os.RemoveAll.
+func (c *CwdFS) RemoveAll(name string) error {
+	return c.fileSystem.RemoveAll(c.abs(name))
+}
+
+// Removexattr implements XattrFS.Removexattr if c's underlying FS implements
+// XattrFS.
+func (c *CwdFS) Removexattr(name, attr string) error {
+	xattrFS, ok := c.fileSystem.(XattrFS)
+	if !ok {
+		return unsupportedError("Removexattr", name)
+	}
+	return xattrFS.Removexattr(c.abs(name), attr)
+}
+
+// Rename implements os.Rename.
+func (c *CwdFS) Rename(oldpath, newpath string) error {
+	return c.fileSystem.Rename(c.abs(oldpath), c.abs(newpath))
+}
+
+// Setxattr implements XattrFS.Setxattr if c's underlying FS implements XattrFS.
+func (c *CwdFS) Setxattr(name, attr string, value []byte) error {
+	xattrFS, ok := c.fileSystem.(XattrFS)
+	if !ok {
+		return unsupportedError("Setxattr", name)
+	}
+	return xattrFS.Setxattr(c.abs(name), attr, value)
+}
+
+// Stat implements os.Stat.
+func (c *CwdFS) Stat(name string) (fs.FileInfo, error) {
+	return c.fileSystem.Stat(c.abs(name))
+}
+
+// Statfs implements Statfser.Statfs if c's underlying FS implements Statfser.
+func (c *CwdFS) Statfs(name string) (*FSStat, error) {
+	statfser, ok := c.fileSystem.(Statfser)
+	if !ok {
+		return nil, unsupportedError("Statfs", name)
+	}
+	return statfser.Statfs(c.abs(name))
+}
+
+// Symlink implements os.Symlink. oldname is not resolved against the current
+// working directory.
+func (c *CwdFS) Symlink(oldname, newname string) error {
+	return c.fileSystem.Symlink(oldname, c.abs(newname))
+}
+
+// Truncate implements os.Truncate.
+func (c *CwdFS) Truncate(name string, size int64) error {
+	return c.fileSystem.Truncate(c.abs(name), size)
+}
+
+// Watch implements Watcher.Watch if c's underlying FS implements Watcher. The
+// names of events are absolute.
+func (c *CwdFS) Watch(name string, recursive bool) (*Watch, error) {
+	watcher, ok := c.fileSystem.(Watcher)
+	if !ok {
+		return nil, unsupportedError("Watch", name)
+	}
+	return watcher.Watch(c.abs(name), recursive)
+}
+
+// WriteFile implements os.WriteFile.
+func (c *CwdFS) WriteFile(filename string, data []byte, perm fs.FileMode) error {
+	return c.fileSystem.WriteFile(c.abs(filename), data, perm)
+}
+
+// abs returns name resolved against c's current working directory.
+func (c *CwdFS) abs(name string) string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.absLocked(name)
+}
+
+// absLocked returns name resolved against c's current working directory. c.mu
+// must be held.
+func (c *CwdFS) absLocked(name string) string {
+	if name == "" || isAbs(name) {
+		return name
+	}
+	return filepath.Join(c.cwd, name)
+}
+
+// isAbs returns whether name is absolute or, on Windows, rooted.
+func isAbs(name string) bool {
+	return filepath.IsAbs(name) || path.IsAbs(filepath.ToSlash(name))
+}
@@ -0,0 +1,14 @@
+package vfs_test
+
+import "github.com/twpayne/go-vfs/v5"
+
+var (
+	_ vfs.FS               = &vfs.CwdFS{}
+	_ vfs.DefaultTempDirer = &vfs.CwdFS{}
+	_ vfs.Lchmoder         = &vfs.CwdFS{}
+	_ vfs.Lchtimeser       = &vfs.CwdFS{}
+	_ vfs.Locker           = &vfs.CwdFS{}
+	_ vfs.Statfser         = &vfs.CwdFS{}
+	_ vfs.Watcher          = &vfs.CwdFS{}
+	_ vfs.XattrFS          = &vfs.CwdFS{}
+)
@@ -0,0 +1,776 @@
+package vfs
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// Encrypted file format constants. An encrypted file consists of a header
+// containing a magic number and a random salt, followed by one or more chunks
+// of up to encryptedChunkSize bytes of plaintext, each sealed with AES-256-GCM
+// using a key derived from the salt. Chunk nonces contain the chunk index and a
+// flag marking the final chunk, so chunks cannot be reordered, removed, or
+// truncated without detection.
+const (
+	encryptedMagic      = "VFE1"
+	encryptedSaltSize   = 16
+	encryptedHeaderSize = len(encryptedMagic) + encryptedSaltSize
+	encryptedChunkSize  = 64 << 10
+	encryptedTagSize    = 16
+	encryptedNonceSize  = 12
+	encryptedMinKeySize = 16
+
+	// encryptedMaxNameSize is the maximum length of a plaintext name component
+	// whose encrypted form fits in a 255-byte name.
+	encryptedMaxNameSize = 255*3/4 - encryptedNonceSize - encryptedTagSize
+)
+
+var (
+	// ErrDecrypt is returned when encrypted data cannot be decrypted, for
+	// example because it was modified or encrypted with a different key.
+	ErrDecrypt = errors.New("decryption failed")
+
+	errKeyTooShort = errors.New("key too short")
+	errNameTooLong = fmt.Errorf("%w: names are limited to %d bytes when encrypted", syscall.ENAMETOOLONG, encryptedMaxNameSize)
+)
+
+// An EncryptedFSOption sets an option on an EncryptedFS.
+type EncryptedFSOption func(*EncryptedFS)
+
+// An EncryptedFS operates on an existing FS and encrypts the contents of
+// regular files, and optionally their names, with AES-256-GCM. Files are
+// written with WriteFile and WriteFileFrom and read with ReadFile and Open.
+// Create and OpenFile return errors wrapping errors.ErrUnsupported, since they
+// return *os.Files whose writes go directly to the operating system and so
+// cannot be encrypted. WriteFileFrom streams a file's contents from an
+// io.Reader instead. Stat, Lstat, and ReadDir report plaintext sizes.
+type EncryptedFS struct {
+	fileSystem   FS
+	contentKey   []byte
+	nameMACKey   []byte
+	nameAEAD     cipher.AEAD
+	encryptNames bool
+}
+
+// EncryptedFSEncryptNames sets whether an EncryptedFS encrypts names. Each path
+// component and each component of symlink targets is encrypted
+// deterministically and encoded with unpadded URL-safe base64, so encrypted
+// names are longer than their plaintext and are case-sensitive. Since most
+// filesystems limit names to 255 bytes, plaintext name components are limited
+// to 163 bytes, and longer names return an error wrapping
+// syscall.ENAMETOOLONG. Entries whose names cannot be decrypted are omitted
+// from ReadDir. The default is false.
+func EncryptedFSEncryptNames(encryptNames bool) EncryptedFSOption {
+	return func(e *EncryptedFS) {
+		e.encryptNames = encryptNames
+	}
+}
+
+// NewEncryptedFS returns a new *EncryptedFS operating on fileSystem with
+// encryption keys derived from key, which must be at least 16 bytes long, and
+// the given options set.
+func NewEncryptedFS(fileSystem FS, key []byte, options ...EncryptedFSOption) (*EncryptedFS, error) {
+	if len(key) < encryptedMinKeySize {
+		return nil, errKeyTooShort
+	}
+	nameAEAD, err := newAESGCM(deriveKey(key, "name"))
+	if err != nil {
+		return nil, err
+	}
+	e := &EncryptedFS{
+		fileSystem: fileSystem,
+		contentKey: deriveKey(key, "content"),
+		nameMACKey: deriveKey(key, "name MAC"),
+		nameAEAD:   nameAEAD,
+	}
+	for _, option := range options {
+		option(e)
+	}
+	return e, nil
+}
+
+// Chmod implements os.Chmod.
+func (e *EncryptedFS) Chmod(name string, mode fs.FileMode) error {
+	encryptedName, err := e.encryptPath("Chmod", name)
+	if err != nil {
+		return err
+	}
+	return e.fileSystem.Chmod(encryptedName, mode)
+}
+
+// Chown implements os.Chown.
+func (e *EncryptedFS) Chown(name string, uid, gid int) error {
+	encryptedName, err := e.encryptPath("Chown", name)
+	if err != nil {
+		return err
+	}
+	return e.fileSystem.Chown(encryptedName, uid, gid)
+}
+
+// Chtimes implements os.Chtimes.
+func (e *EncryptedFS) Chtimes(name string, atime, mtime time.Time) error {
+	encryptedName, err := e.encryptPath("Chtimes", name)
+	if err != nil {
+		return err
+	}
+	return e.fileSystem.Chtimes(encryptedName, atime, mtime)
+}
+
+// Create returns an error wrapping errors.ErrUnsupported. Use WriteFileFrom
+// instead.
+func (e *EncryptedFS) Create(name string) (*os.File, error) {
+	return nil, unsupportedError("Create", name)
+}
+
+// Glob implements filepath.Glob.
+func (e *EncryptedFS) Glob(pattern string) ([]string, error) {
+	if !e.encryptNames {
+		return e.fileSystem.Glob(pattern)
+	}
+	return globFS(e, pattern)
+}
+
+// Lchown implements os.Lchown.
+func (e *EncryptedFS) Lchown(name string, uid, gid int) error {
+	encryptedName, err := e.encryptPath("Lchown", name)
+	if err != nil {
+		return err
+	}
+	return e.fileSystem.Lchown(encryptedName, uid, gid)
+}
+
+// Link implements os.Link.
+func (e *EncryptedFS) Link(oldname, newname string) error {
+	encryptedOld, err := e.encryptPath("Link", oldname)
+	if err != nil {
+		return err
+	}
+	encryptedNew, err := e.encryptPath("Link", newname)
+	if err != nil {
+		return err
+	}
+	return e.fileSystem.Link(encryptedOld, encryptedNew)
+}
+
+// Lstat implements os.Lstat.
+func (e *EncryptedFS) Lstat(name string) (fs.FileInfo, error) {
+	encryptedName, err := e.encryptPath("Lstat", name)
+	if err != nil {
+		return nil, err
+	}
+	info, err := e.fileSystem.Lstat(encryptedName)
+	if err != nil {
+		return nil, err
+	}
+	return e.plaintextFileInfo(info, filepath.Base(name)), nil
+}
+
+// Mkdir implements os.Mkdir.
+func (e *EncryptedFS) Mkdir(name string, perm fs.FileMode) error {
+	encryptedName, err := e.encryptPath("Mkdir", name)
+	if err != nil {
+		return err
+	}
+	return e.fileSystem.Mkdir(encryptedName, perm)
+}
+
+// Open implements os.Open. Regular files are decrypted as they are read.
+// Directories are returned unchanged, so ReadDir should be used to list them.
+func (e *EncryptedFS) Open(name string) (fs.File, error) {
+	encryptedName, err := e.encryptPath("Open", name)
+	if err != nil {
+		return nil, err
+	}
+	file, err := e.fileSystem.Open(encryptedName)
+	if err != nil {
+		return nil, err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+	if !info.Mode().IsRegular() {
+		return file, nil
+	}
+	reader := bufio.NewReaderSize(file, encryptedChunkSize+encryptedTagSize)
+	header := make([]byte, encryptedHeaderSize)
+	if _, err := io.ReadFull(reader, header); err != nil {
+		file.Close()
+		return nil, decryptError("Open", name)
+	}
+	aead, err := e.fileAEAD(header)
+	if err != nil {
+		file.Close()
+		return nil, decryptError("Open", name)
+	}
+	return &encryptedFile{
+		file:   file,
+		name:   name,
+		info:   e.plaintextFileInfo(info, filepath.Base(name)),
+		reader: reader,
+		aead:   aead,
+	}, nil
+}
+
+// OpenFile returns an error wrapping errors.ErrUnsupported. Use Open to read
+// files and WriteFileFrom to write them.
+func (e *EncryptedFS) OpenFile(name string, flag int, perm fs.FileMode) (*os.File, error) {
+	return nil, unsupportedError("OpenFile", name)
+}
+
+// PathSeparator implements PathSeparator.
+func (e *EncryptedFS) PathSeparator() rune {
+	return e.fileSystem.PathSeparator()
+}
+
+// RawPath implements RawPath.
+func (e *EncryptedFS) RawPath(path string) (string, error) {
+	encryptedPath, err := e.encryptPath("RawPath", path)
+	if err != nil {
+		return "", err
+	}
+	return e.fileSystem.RawPath(encryptedPath)
+}
+
+// ReadDir implements os.ReadDir.
+func (e *EncryptedFS) ReadDir(dirname string) ([]fs.DirEntry, error) {
+	encryptedName, err := e.encryptPath("ReadDir", dirname)
+	if err != nil {
+		return nil, err
+	}
+	dirEntries, err := e.fileSystem.ReadDir(encryptedName)
+	if err != nil {
+		return nil, err
+	}
+	plaintextDirEntries := make([]fs.DirEntry, 0, len(dirEntries))
+	for _, dirEntry := range dirEntries {
+		name := dirEntry.Name()
+		if e.encryptNames {
+			name, err = e.decryptName(name)
+			if err != nil {
+				continue
+			}
+		}
+		plaintextDirEntries = append(plaintextDirEntries, &encryptedDirEntry{
+			DirEntry:    dirEntry,
+			encryptedFS: e,
+			name:        name,
+		})
+	}
+	sort.Sort(dirEntriesByName(plaintextDirEntries))
+	return plaintextDirEntries, nil
+}
+
+// ReadFile implements os.ReadFile.
+func (e *EncryptedFS) ReadFile(filename string) ([]byte, error) {
+	encryptedName, err := e.encryptPath("ReadFile", filename)
+	if err != nil {
+		return nil, err
+	}
+	ciphertext, err := e.fileSystem.ReadFile(encryptedName)
+	if err != nil {
+		return nil, err
+	}
+	plaintext, err := e.decrypt(ciphertext)
+	if err != nil {
+		return nil, decryptError("ReadFile", filename)
+	}
+	return plaintext, nil
+}
+
+// Readlink implements os.Readlink.
+func (e *EncryptedFS) Readlink(name string) (string, error) {
+	encryptedName, err := e.encryptPath("Readlink", name)
+	if err != nil {
+		return "", err
+	}
+	target, err := e.fileSystem.Readlink(encryptedName)
+	if err != nil {
+		return "", err
+	}
+	if !e.encryptNames {
+		return target, nil
+	}
+	plaintextTarget, err := e.decryptPath(target)
+	if err != nil {
+		return "", decryptError("Readlink", name)
+	}
+	return plaintextTarget, nil
+}
+
+// Remove implements os.Remove.
+func (e *EncryptedFS) Remove(name string) error {
+	encryptedName, err := e.encryptPath("Remove", name)
+	if err != nil {
+		return err
+	}
+	return e.fileSystem.Remove(encryptedName)
+}
+
+// RemoveAll implements os.RemoveAll.
+func (e *EncryptedFS) RemoveAll(name string) error {
+	encryptedName, err := e.encryptPath("RemoveAll", name)
+	if err != nil {
+		return err
+	}
+	return e.fileSystem.RemoveAll(encryptedName)
+}
+
+// Rename implements os.Rename.
+func (e *EncryptedFS) Rename(oldpath, newpath string) error {
+	encryptedOld, err := e.encryptPath("Rename", oldpath)
+	if err != nil {
+		return err
+	}
+	encryptedNew, err := e.encryptPath("Rename", newpath)
+	if err != nil {
+		return err
+	}
+	return e.fileSystem.Rename(encryptedOld, encryptedNew)
+}
+
+// Stat implements os.Stat.
+func (e *EncryptedFS) Stat(name string) (fs.FileInfo, error) {
+	encryptedName, err := e.encryptPath("Stat", name)
+	if err != nil {
+		return nil, err
+	}
+	info, err := e.fileSystem.Stat(encryptedName)
+	if err != nil {
+		return nil, err
+	}
+	return e.plaintextFileInfo(info, filepath.Base(name)), nil
+}
+
+// Symlink implements os.Symlink.
+func (e *EncryptedFS) Symlink(oldname, newname string) error {
+	encryptedOld, err := e.encryptPath("Symlink", oldname)
+	if err != nil {
+		return err
+	}
+	encryptedNew, err := e.encryptPath("Symlink", newname)
+	if err != nil {
+		return err
+	}
+	return e.fileSystem.Symlink(encryptedOld, encryptedNew)
+}
+
+// Truncate implements os.Truncate. The file is decrypted, truncated or
+// extended with zero bytes, and re-encrypted.
+func (e *EncryptedFS) Truncate(name string, size int64) error {
+	info, err := e.Stat(name)
+	if err != nil {
+		return err
+	}
+	plaintext, err := e.ReadFile(name)
+	if err != nil {
+		return err
+	}
+	if size < int64(len(plaintext)) {
+		plaintext = plaintext[:size]
+	} else {
+		plaintext = append(plaintext, make([]byte, size-int64(len(plaintext)))...)
+	}
+	return e.WriteFile(name, plaintext, info.Mode().Perm())
+}
+
+// WriteFile implements os.WriteFile.
+func (e *EncryptedFS) WriteFile(filename string, data []byte, perm fs.FileMode) error {
+	encryptedName, err := e.encryptPath("WriteFile", filename)
+	if err != nil {
+		return err
+	}
+	ciphertext, err := e.encrypt(data)
+	if err != nil {
+		return err
+	}
+	return e.fileSystem.WriteFile(encryptedName, ciphertext, perm)
+}
+
+// WriteFileFrom writes the contents of r to filename, creating it with perm if
+// needed, encrypting one chunk at a time so that the whole contents are never
+// held in memory. If reading r fails then filename is left with contents that
+// cannot be decrypted.
+func (e *EncryptedFS) WriteFileFrom(filename string, r io.Reader, perm fs.FileMode) error {
+	encryptedName, err := e.encryptPath("WriteFileFrom", filename)
+	if err != nil {
+		return err
+	}
+	header, aead, err := e.newFileHeader()
+	if err != nil {
+		return err
+	}
+	file, err := e.fileSystem.OpenFile(encryptedName, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, perm)
+	if err != nil {
+		return err
+	}
+	err = writeEncryptedChunks(file, header, aead, r)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+// decrypt returns the plaintext of ciphertext.
+func (e *EncryptedFS) decrypt(ciphertext []byte) ([]byte, error) {
+	if len(ciphertext) < encryptedHeaderSize {
+		return nil, ErrDecrypt
+	}
+	aead, err := e.fileAEAD(ciphertext[:encryptedHeaderSize])
+	if err != nil {
+		return nil, err
+	}
+	body := ciphertext[encryptedHeaderSize:]
+	plaintext := make([]byte, 0, encryptedPlaintextSize(int64(len(ciphertext))))
+	for index := uint64(0); ; index++ {
+		if len(body) == 0 {
+			return nil, ErrDecrypt
+		}
+		n := min(len(body), encryptedChunkSize+encryptedTagSize)
+		last := n == len(body)
+		plaintext, err = aead.Open(plaintext, encryptedChunkNonce(index, last), body[:n], nil)
+		if err != nil {
+			return nil, ErrDecrypt
+		}
+		if last {
+			return plaintext, nil
+		}
+		body = body[n:]
+	}
+}
+
+// decryptName returns the plaintext of the encrypted name component name.
+func (e *EncryptedFS) decryptName(name string) (string, error) {
+	data, err := base64.RawURLEncoding.DecodeString(name)
+	if err != nil || len(data) < encryptedNonceSize+encryptedTagSize {
+		return "", ErrDecrypt
+	}
+	plaintext, err := e.nameAEAD.Open(nil, data[:encryptedNonceSize], data[encryptedNonceSize:], nil)
+	if err != nil {
+		return "", ErrDecrypt
+	}
+	return string(plaintext), nil
+}
+
+// decryptPath returns path with all components decrypted.
+func (e *EncryptedFS) decryptPath(path string) (string, error) {
+	var err error
+	plaintextPath := mapPathComponents(path, func(component string) string {
+		plaintextComponent, decryptErr := e.decryptName(component)
+		if decryptErr != nil {
+			err = decryptErr
+		}
+		return plaintextComponent
+	})
+	return plaintextPath, err
+}
+
+// encrypt returns the ciphertext of plaintext.
+func (e *EncryptedFS) encrypt(plaintext []byte) ([]byte, error) {
+	header, aead, err := e.newFileHeader()
+	if err != nil {
+		return nil, err
+	}
+	chunks := max(1, (len(plaintext)+encryptedChunkSize-1)/encryptedChunkSize)
+	ciphertext := make([]byte, 0, encryptedHeaderSize+len(plaintext)+chunks*encryptedTagSize)
+	ciphertext = append(ciphertext, header...)
+	for index := uint64(0); ; index++ {
+		n := min(len(plaintext), encryptedChunkSize)
+		last := n == len(plaintext)
+		ciphertext = aead.Seal(ciphertext, encryptedChunkNonce(index, last), plaintext[:n], nil)
+		if last {
+			return ciphertext, nil
+		}
+		plaintext = plaintext[n:]
+	}
+}
+
+// encryptName returns the encrypted name component name. The nonce is derived
+// from name so that the same name always encrypts to the same value.
+func (e *EncryptedFS) encryptName(name string) string {
+	mac := hmac.New(sha256.New, e.nameMACKey)
+	mac.Write([]byte(name))
+	nonce := mac.Sum(nil)[:encryptedNonceSize:encryptedNonceSize]
+	return base64.RawURLEncoding.EncodeToString(e.nameAEAD.Seal(nonce, nonce, []byte(name), nil))
+}
+
+// encryptPath returns path with all components encrypted, if e encrypts names.
+// It returns an error if any component is too long to be encrypted.
+func (e *EncryptedFS) encryptPath(op, path string) (string, error) {
+	if !e.encryptNames {
+		return path, nil
+	}
+	var err error
+	encryptedPath := mapPathComponents(path, func(component string) string {
+		if len(component) > encryptedMaxNameSize {
+			err = &os.PathError{
+				Op:   op,
+				Path: path,
+				Err:  errNameTooLong,
+			}
+		}
+		return e.encryptName(component)
+	})
+	return encryptedPath, err
+}
+
+// fileAEAD returns the cipher.AEAD for the file with header.
+func (e *EncryptedFS) fileAEAD(header []byte) (cipher.AEAD, error) {
+	if !bytes.HasPrefix(header, []byte(encryptedMagic)) {
+		return nil, ErrDecrypt
+	}
+	mac := hmac.New(sha256.New, e.contentKey)
+	mac.Write(header[len(encryptedMagic):encryptedHeaderSize])
+	return newAESGCM(mac.Sum(nil))
+}
+
+// newFileHeader returns a new random header for an encrypted file and its
+// cipher.AEAD.
+func (e *EncryptedFS) newFileHeader() ([]byte, cipher.AEAD, error) {
+	header := make([]byte, encryptedHeaderSize)
+	copy(header, encryptedMagic)
+	if _, err := rand.Read(header[len(encryptedMagic):]); err != nil {
+		return nil, nil, err
+	}
+	aead, err := e.fileAEAD(header)
+	if err != nil {
+		return nil, nil, err
+	}
+	return header, aead, nil
+}
+
+// plaintextFileInfo returns info with its name replaced by name and, if it is
+// a regular file, its size replaced by the plaintext size.
+func (e *EncryptedFS) plaintextFileInfo(info fs.FileInfo, name string) fs.FileInfo {
+	size := info.Size()
+	if info.Mode().IsRegular() {
+		size = encryptedPlaintextSize(size)
+	}
+	return &encryptedFileInfo{
+		FileInfo: info,
+		name:     name,
+		size:     size,
+	}
+}
+
+// An encryptedDirEntry is an fs.DirEntry in an EncryptedFS.
+type encryptedDirEntry struct {
+	fs.DirEntry
+	encryptedFS *EncryptedFS
+	name        string
+}
+
+// Info implements fs.DirEntry.Info.
+func (d *encryptedDirEntry) Info() (fs.FileInfo, error) {
+	info, err := d.DirEntry.Info()
+	if err != nil {
+		return nil, err
+	}
+	return d.encryptedFS.plaintextFileInfo(info, d.name), nil
+}
+
+// Name implements fs.DirEntry.Name.
+func (d *encryptedDirEntry) Name() string {
+	return d.name
+}
+
+// An encryptedFile is a regular file in an EncryptedFS open for reading.
+type encryptedFile struct {
+	file       fs.File
+	name       string
+	info       fs.FileInfo
+	reader     *bufio.Reader
+	aead       cipher.AEAD
+	index      uint64
+	plaintext  []byte
+	ciphertext []byte
+	done       bool
+}
+
+// Close implements fs.File.Close.
+func (f *encryptedFile) Close() error {
+	return f.file.Close()
+}
+
+// Read implements fs.File.Read.
+func (f *encryptedFile) Read(p []byte) (int, error) {
+	for len(f.plaintext) == 0 {
+		if f.done {
+			return 0, io.EOF
+		}
+		if err := f.readChunk(); err != nil {
+			return 0, err
+		}
+	}
+	n := copy(p, f.plaintext)
+	f.plaintext = f.plaintext[n:]
+	return n, nil
+}
+
+// Stat implements fs.File.Stat.
+func (f *encryptedFile) Stat() (fs.FileInfo, error) {
+	return f.info, nil
+}
+
+// readChunk reads and decrypts the next chunk into f.plaintext.
+func (f *encryptedFile) readChunk() error {
+	if f.ciphertext == nil {
+		f.ciphertext = make([]byte, encryptedChunkSize+encryptedTagSize)
+	}
+	n, err := io.ReadFull(f.reader, f.ciphertext)
+	var last bool
+	switch {
+	case errors.Is(err, io.EOF):
+		return decryptError("Read", f.name)
+	case errors.Is(err, io.ErrUnexpectedEOF):
+		last = true
+	case err != nil:
+		return err
+	default:
+		switch _, err := f.reader.Peek(1); {
+		case errors.Is(err, io.EOF):
+			last = true
+		case err != nil:
+			return err
+		}
+	}
+	plaintext, err := f.aead.Open(f.ciphertext[:0], encryptedChunkNonce(f.index, last), f.ciphertext[:n], nil)
+	if err != nil {
+		return decryptError("Read", f.name)
+	}
+	f.plaintext = plaintext
+	f.index++
+	f.done = last
+	return nil
+}
+
+// An encryptedFileInfo is an fs.FileInfo in an EncryptedFS.
+type encryptedFileInfo struct {
+	fs.FileInfo
+	name string
+	size int64
+}
+
+// Name implements fs.FileInfo.Name.
+func (i *encryptedFileInfo) Name() string {
+	return i.name
+}
+
+// Size implements fs.FileInfo.Size.
+func (i *encryptedFileInfo) Size() int64 {
+	return i.size
+}
+
+// decryptError returns an *os.PathError wrapping ErrDecrypt.
+func decryptError(op, path string) error {
+	return &os.PathError{
+		Op:   op,
+		Path: path,
+		Err:  ErrDecrypt,
+	}
+}
+
+// deriveKey returns a 256-bit key for label derived from key.
+func deriveKey(key []byte, label string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(label))
+	return mac.Sum(nil)
+}
+
+// encryptedChunkNonce returns the nonce for the chunk at index.
+func encryptedChunkNonce(index uint64, last bool) []byte {
+	nonce := make([]byte, encryptedNonceSize)
+	binary.BigEndian.PutUint64(nonce, index)
+	if last {
+		nonce[encryptedNonceSize-1] = 1
+	}
+	return nonce
+}
+
+// encryptedPlaintextSize returns the plaintext size of an encrypted file of
+// size bytes.
+func encryptedPlaintextSize(size int64) int64 {
+	body := size - int64(encryptedHeaderSize)
+	if body < encryptedTagSize {
+		return 0
+	}
+	chunks := (body + encryptedChunkSize + encryptedTagSize - 1) / (encryptedChunkSize + encryptedTagSize)
+	return body - chunks*encryptedTagSize
+}
+
+// mapPathComponents returns path with f applied to each component other than
+// the volume name, ".", and "..".
+func mapPathComponents(path string, f func(string) string) string {
+	volumeName := filepath.VolumeName(path)
+	components := strings.Split(filepath.ToSlash(path[len(volumeName):]), "/")
+	for i, component := range components {
+		switch component {
+		case "", ".", "..":
+		default:
+			components[i] = f(component)
+		}
+	}
+	return volumeName + filepath.FromSlash(strings.Join(components, "/"))
+}
+
+// newAESGCM returns a new AES-GCM cipher.AEAD with key.
+func newAESGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// writeEncryptedChunks writes header followed by the contents of r encrypted
+// with aead to w.
+func writeEncryptedChunks(w io.Writer, header []byte, aead cipher.AEAD, r io.Reader) error {
+	if _, err := w.Write(header); err != nil {
+		return err
+	}
+	reader := bufio.NewReaderSize(r, encryptedChunkSize)
+	plaintext := make([]byte, encryptedChunkSize)
+	ciphertext := make([]byte, 0, encryptedChunkSize+encryptedTagSize)
+	for index := uint64(0); ; index++ {
+		n, err := io.ReadFull(reader, plaintext)
+		var last bool
+		switch {
+		case errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF):
+			last = true
+		case err != nil:
+			return err
+		default:
+			switch _, err := reader.Peek(1); {
+			case errors.Is(err, io.EOF):
+				last = true
+			case err != nil:
+				return err
+			}
+		}
+		ciphertext = aead.Seal(ciphertext[:0], encryptedChunkNonce(index, last), plaintext[:n], nil)
+		if _, err := w.Write(ciphertext); err != nil {
+			return err
+		}
+		if last {
+			return nil
+		}
+	}
+}
@@ -0,0 +1,100 @@
+package vfs
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+)
+
+// globFS is the equivalent of filepath.Glob but operates on fileSystem. It is
+// used by FSs that cannot pass patterns to an underlying FS's Glob.
+func globFS(fileSystem LstatReadDirer, pattern string) ([]string, error) {
+	return globFSWithLimit(fileSystem, pattern, 0)
+}
+
+// globFSWithLimit implements globFS, limiting the recursion depth.
+func globFSWithLimit(fileSystem LstatReadDirer, pattern string, depth int) ([]string, error) {
+	const pathSeparatorsLimit = 10000
+	if depth == pathSeparatorsLimit {
+		return nil, filepath.ErrBadPattern
+	}
+	if _, err := filepath.Match(pattern, ""); err != nil {
+		return nil, err
+	}
+	if !hasGlobMeta(pattern) {
+		if _, err := fileSystem.Lstat(pattern); err != nil {
+			return nil, nil //nolint:nilerr
+		}
+		return []string{pattern}, nil
+	}
+
+	dir, file := filepath.Split(pattern)
+	volumeLen := len(filepath.VolumeName(dir))
+	dir = cleanGlobPath(dir, volumeLen)
+	if !hasGlobMeta(dir[volumeLen:]) {
+		return globFSDir(fileSystem, dir, file, nil)
+	}
+	if dir == pattern {
+		return nil, filepath.ErrBadPattern
+	}
+
+	dirs, err := globFSWithLimit(fileSystem, dir, depth+1)
+	if err != nil {
+		return nil, err
+	}
+	var matches []string
+	for _, dir := range dirs {
+		matches, err = globFSDir(fileSystem, dir, file, matches)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return matches, nil
+}
+
+// globFSDir appends the names in dir that match pattern to matches.
+func globFSDir(fileSystem LstatReadDirer, dir, pattern string, matches []string) ([]string, error) {
+	dirEntries, err := fileSystem.ReadDir(dir)
+	if err != nil {
+		return matches, nil //nolint:nilerr
+	}
+	names := make([]string, 0, len(dirEntries))
+	for _, dirEntry := range dirEntries {
+		names = append(names, dirEntry.Name())
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		matched, err := filepath.Match(pattern, name)
+		if err != nil {
+			return matches, err
+		}
+		if matched {
+			matches = append(matches, filepath.Join(dir, name))
+		}
+	}
+	return matches, nil
+}
+
+// cleanGlobPath prepares dir for glob matching.
+func cleanGlobPath(dir string, volumeLen int) string {
+	switch rest := dir[volumeLen:]; {
+	case rest == "":
+		return dir + "."
+	case len(rest) == 1 && os.IsPathSeparator(rest[0]):
+		return dir
+	default:
+		return dir[:len(dir)-1]
+	}
+}
+
+// hasGlobMeta reports whether path contains any of the magic characters
+// recognized by filepath.Match.
+func hasGlobMeta(path string) bool {
+	magicChars := `*?[\`
+	if runtime.GOOS == "windows" {
+		magicChars = `*?[`
+	}
+	return strings.ContainsAny(path, magicChars)
+}
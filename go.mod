@@ -4,10 +4,9 @@ go 1.22
 
 require (
 	github.com/alecthomas/assert/v2 v2.6.0
-	golang.org/x/sys v0.17.0
+	github.com/hexops/gotextdiff v1.0.3
+	golang.org/x/net v0.34.0
+	golang.org/x/sys v0.29.0
 )
 
-require (
-	github.com/alecthomas/repr v0.4.0 // indirect
-	github.com/hexops/gotextdiff v1.0.3 // indirect
-)
+require github.com/alecthomas/repr v0.4.0 // indirect
@@ -0,0 +1,295 @@
+package vfs
+
+import (
+	"bytes"
+	"io"
+	"io/fs"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"syscall"
+)
+
+// maxSymlinks is the maximum number of symlinks followed when resolving a
+// path.
+const maxSymlinks = 40
+
+// An HTTPFSOption sets an option on an HTTPFS.
+type HTTPFSOption func(*HTTPFS)
+
+// An HTTPFS implements http.FileSystem on an existing FS, serving the files
+// below a root directory. Files are served with their modification times from
+// Stat, so http.FileServer honors Range and If-Modified-Since requests. Files
+// that cannot seek are read into memory.
+type HTTPFS struct {
+	fileSystem     FS
+	root           string
+	hideDotfiles   bool
+	listDirs       bool
+	confineSymlink bool
+}
+
+// An httpFile is an http.File in an HTTPFS.
+type httpFile struct {
+	io.ReadSeeker
+	closer     io.Closer
+	info       fs.FileInfo
+	httpFS     *HTTPFS
+	name       string
+	dirEntries []fs.FileInfo
+	dirRead    bool
+}
+
+// HTTPFSConfineSymlinks sets whether an HTTPFS refuses to serve files whose
+// paths, after following symlinks, are not below its root. Refused files are
+// reported as fs.ErrPermission and omitted from directory listings. If the
+// root is relative then symlinks with absolute targets are always refused. The
+// default is false.
+func HTTPFSConfineSymlinks(confineSymlinks bool) HTTPFSOption {
+	return func(h *HTTPFS) {
+		h.confineSymlink = confineSymlinks
+	}
+}
+
+// HTTPFSHideDotfiles sets whether an HTTPFS hides files and directories whose
+// names begin with a dot. Hidden files are reported as fs.ErrNotExist and
+// omitted from directory listings. The default is false.
+func HTTPFSHideDotfiles(hideDotfiles bool) HTTPFSOption {
+	return func(h *HTTPFS) {
+		h.hideDotfiles = hideDotfiles
+	}
+}
+
+// HTTPFSListDirectories sets whether an HTTPFS allows directories to be
+// listed. If not, directories without an index.html file are reported as
+// fs.ErrNotExist. The default is true.
+func HTTPFSListDirectories(listDirectories bool) HTTPFSOption {
+	return func(h *HTTPFS) {
+		h.listDirs = listDirectories
+	}
+}
+
+// NewHTTPFS returns a new *HTTPFS serving the files below root in fileSystem
+// with the given options set.
+func NewHTTPFS(fileSystem FS, root string, options ...HTTPFSOption) *HTTPFS {
+	h := &HTTPFS{
+		fileSystem: fileSystem,
+		root:       filepath.Clean(root),
+		listDirs:   true,
+	}
+	for _, option := range options {
+		option(h)
+	}
+	return h
+}
+
+// NewHTTPHandler returns an http.Handler that serves the files below root in
+// fileSystem with http.FileServer and the given options set.
+func NewHTTPHandler(fileSystem FS, root string, options ...HTTPFSOption) http.Handler {
+	return http.FileServer(NewHTTPFS(fileSystem, root, options...))
+}
+
+// Open implements http.FileSystem.Open.
+func (h *HTTPFS) Open(name string) (http.File, error) {
+	name = path.Clean("/" + name)
+	if h.hideDotfiles && hasDotfileComponent(name) {
+		return nil, &os.PathError{Op: "open", Path: name, Err: fs.ErrNotExist}
+	}
+	fsName := filepath.Join(h.root, filepath.FromSlash(name))
+	if err := h.checkSymlinks(fsName); err != nil {
+		return nil, err
+	}
+	info, err := h.fileSystem.Stat(fsName)
+	if err != nil {
+		return nil, err
+	}
+
+	if info.IsDir() {
+		if !h.listDirs {
+			indexName := filepath.Join(fsName, "index.html")
+			if _, err := h.fileSystem.Stat(indexName); err != nil {
+				return nil, &os.PathError{Op: "open", Path: name, Err: fs.ErrNotExist}
+			}
+		}
+		return &httpFile{
+			ReadSeeker: bytes.NewReader(nil),
+			info:       info,
+			httpFS:     h,
+			name:       fsName,
+		}, nil
+	}
+
+	file, err := h.fileSystem.Open(fsName)
+	if err != nil {
+		return nil, err
+	}
+	if readSeeker, ok := file.(io.ReadSeeker); ok {
+		return &httpFile{
+			ReadSeeker: readSeeker,
+			closer:     file,
+			info:       info,
+		}, nil
+	}
+	data, err := io.ReadAll(file)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &httpFile{
+		ReadSeeker: bytes.NewReader(data),
+		info:       info,
+	}, nil
+}
+
+// checkSymlinks returns fs.ErrPermission if h confines symlinks and name is
+// not below h's root after following symlinks.
+func (h *HTTPFS) checkSymlinks(name string) error {
+	if !h.confineSymlink {
+		return nil
+	}
+	realRoot, err := realPath(h.fileSystem, h.root)
+	if err != nil {
+		return err
+	}
+	realName, err := realPath(h.fileSystem, name)
+	if err != nil {
+		return err
+	}
+	if rel, err := filepath.Rel(realRoot, realName); err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return &os.PathError{Op: "open", Path: name, Err: fs.ErrPermission}
+	}
+	return nil
+}
+
+// Close implements http.File.Close.
+func (f *httpFile) Close() error {
+	if f.closer == nil {
+		return nil
+	}
+	return f.closer.Close()
+}
+
+// Readdir implements http.File.Readdir.
+func (f *httpFile) Readdir(count int) ([]fs.FileInfo, error) {
+	if f.httpFS == nil {
+		return nil, &os.PathError{Op: "readdir", Path: f.info.Name(), Err: syscall.ENOTDIR}
+	}
+	if !f.dirRead {
+		if err := f.readDir(); err != nil {
+			return nil, err
+		}
+	}
+	if count <= 0 {
+		infos := f.dirEntries
+		f.dirEntries = nil
+		return infos, nil
+	}
+	if len(f.dirEntries) == 0 {
+		return nil, io.EOF
+	}
+	n := min(count, len(f.dirEntries))
+	infos := f.dirEntries[:n]
+	f.dirEntries = f.dirEntries[n:]
+	return infos, nil
+}
+
+// Stat implements http.File.Stat.
+func (f *httpFile) Stat() (fs.FileInfo, error) {
+	return f.info, nil
+}
+
+// readDir reads the entries of f, omitting entries hidden by f's HTTPFS.
+func (f *httpFile) readDir() error {
+	f.dirRead = true
+	dirEntries, err := f.httpFS.fileSystem.ReadDir(f.name)
+	if err != nil {
+		return err
+	}
+	sort.Sort(dirEntriesByName(dirEntries))
+	for _, dirEntry := range dirEntries {
+		if f.httpFS.hideDotfiles && strings.HasPrefix(dirEntry.Name(), ".") {
+			continue
+		}
+		name := filepath.Join(f.name, dirEntry.Name())
+		if dirEntry.Type()&fs.ModeSymlink != 0 {
+			if err := f.httpFS.checkSymlinks(name); err != nil {
+				continue
+			}
+		}
+		info, err := dirEntry.Info()
+		if err != nil {
+			return err
+		}
+		f.dirEntries = append(f.dirEntries, info)
+	}
+	return nil
+}
+
+// hasDotfileComponent returns whether any component of the /-separated name
+// begins with a dot.
+func hasDotfileComponent(name string) bool {
+	for _, component := range strings.Split(name, "/") {
+		if strings.HasPrefix(component, ".") {
+			return true
+		}
+	}
+	return false
+}
+
+// realPath returns name in fileSystem with all symlinks followed. If name is
+// relative then the result is relative to the current directory.
+func realPath(fileSystem FS, name string) (string, error) {
+	volumeName := filepath.VolumeName(name)
+	components := strings.Split(filepath.ToSlash(name[len(volumeName):]), "/")
+	resolved := "."
+	if volumeName != "" || path.IsAbs(filepath.ToSlash(name)) {
+		resolved = volumeName + string(filepath.Separator)
+	}
+	symlinks := 0
+	for len(components) > 0 {
+		component := components[0]
+		components = components[1:]
+		switch component {
+		case "", ".":
+			continue
+		case "..":
+			if resolved == "." || filepath.Base(resolved) == ".." {
+				resolved = filepath.Join(resolved, "..")
+			} else {
+				resolved = filepath.Dir(resolved)
+			}
+			continue
+		}
+		next := filepath.Join(resolved, component)
+		info, err := fileSystem.Lstat(next)
+		if err != nil {
+			return "", err
+		}
+		if info.Mode()&fs.ModeSymlink == 0 {
+			resolved = next
+			continue
+		}
+		symlinks++
+		if symlinks > maxSymlinks {
+			return "", syscall.ELOOP
+		}
+		target, err := fileSystem.Readlink(next)
+		if err != nil {
+			return "", err
+		}
+		if filepath.IsAbs(target) || path.IsAbs(filepath.ToSlash(target)) {
+			if targetVolumeName := filepath.VolumeName(target); targetVolumeName != "" {
+				volumeName = targetVolumeName
+				target = target[len(targetVolumeName):]
+			}
+			resolved = volumeName + string(filepath.Separator)
+		}
+		components = append(strings.Split(filepath.ToSlash(target), "/"), components...)
+	}
+	return resolved, nil
+}
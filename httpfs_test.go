@@ -0,0 +1,9 @@
+package vfs_test
+
+import (
+	"net/http"
+
+	"github.com/twpayne/go-vfs/v5"
+)
+
+var _ http.FileSystem = &vfs.HTTPFS{}
@@ -0,0 +1,611 @@
+package vfs
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ChecksumXattr is the extended attribute in which an IntegrityFS created with
+// NewXattrIntegrityFS stores checksums.
+const ChecksumXattr = "user.vfs.sha256"
+
+// A ChecksumMismatchError is returned when the contents of a file do not match
+// its recorded checksum.
+type ChecksumMismatchError struct {
+	Path string
+	Want string
+	Got  string
+}
+
+// An IntegrityFS operates on an existing FS and records a SHA-256 checksum of
+// every file written through it with WriteFile or Truncate. ReadFile and Open
+// verify the contents of files with recorded checksums and return a
+// *ChecksumMismatchError if they do not match. Files without recorded
+// checksums, for example those written by other means, are not verified.
+// Opening a file for writing with Create or OpenFile removes its checksum,
+// since writes to *os.Files cannot be intercepted. OpenFile does not verify
+// files opened for reading.
+type IntegrityFS struct {
+	fileSystem FS
+	store      checksumStore
+}
+
+// A checksumStore stores checksums for an IntegrityFS.
+type checksumStore interface {
+	get(name string) (string, bool, error)
+	remove(name string, recursive bool) error
+	rename(oldpath, newpath string) error
+	set(name, checksum string) error
+}
+
+// NewIntegrityFS returns a new *IntegrityFS operating on fileSystem that stores
+// checksums in a JSON manifest at manifestPath in fileSystem. The manifest is
+// rewritten after every modification, so this is only suitable for small
+// numbers of files. Checksums are recorded by name, so files read through
+// symlinks are not verified. Hard links are not supported, since writes
+// through one name would not update the checksums of the others, so Link
+// returns an error wrapping errors.ErrUnsupported.
+func NewIntegrityFS(fileSystem FS, manifestPath string) (*IntegrityFS, error) {
+	store := &manifestChecksumStore{
+		fileSystem:   fileSystem,
+		manifestPath: manifestPath,
+		checksums:    make(map[string]string),
+	}
+	switch data, err := fileSystem.ReadFile(manifestPath); {
+	case errors.Is(err, fs.ErrNotExist):
+	case err != nil:
+		return nil, err
+	default:
+		if err := json.Unmarshal(data, &store.checksums); err != nil {
+			return nil, &os.PathError{
+				Op:   "NewIntegrityFS",
+				Path: manifestPath,
+				Err:  err,
+			}
+		}
+	}
+	return &IntegrityFS{
+		fileSystem: fileSystem,
+		store:      store,
+	}, nil
+}
+
+// NewXattrIntegrityFS returns a new *IntegrityFS operating on fileSystem that
+// stores checksums in the ChecksumXattr extended attribute of each file.
+// fileSystem must implement XattrFS. Files on filesystems that do not support
+// extended attributes are not verified, and writing them with WriteFile or
+// Truncate returns an error wrapping errors.ErrUnsupported.
+func NewXattrIntegrityFS(fileSystem FS) (*IntegrityFS, error) {
+	xattrFS, ok := fileSystem.(XattrFS)
+	if !ok {
+		return nil, errors.ErrUnsupported
+	}
+	return &IntegrityFS{
+		fileSystem: fileSystem,
+		store: &xattrChecksumStore{
+			xattrFS: xattrFS,
+		},
+	}, nil
+}
+
+// Error implements error.Error.
+func (e *ChecksumMismatchError) Error() string {
+	return fmt.Sprintf("%s: checksum mismatch: got sha256:%s, want sha256:%s", e.Path, e.Got, e.Want)
+}
+
+// Chmod implements os.Chmod.
+func (i *IntegrityFS) Chmod(name string, mode fs.FileMode) error {
+	return i.fileSystem.Chmod(name, mode)
+}
+
+// Chown implements os.Chown.
+func (i *IntegrityFS) Chown(name string, uid, gid int) error {
+	return i.fileSystem.Chown(name, uid, gid)
+}
+
+// Chtimes implements os.Chtimes.
+func (i *IntegrityFS) Chtimes(name string, atime, mtime time.Time) error {
+	return i.fileSystem.Chtimes(name, atime, mtime)
+}
+
+// Create implements os.Create.
+func (i *IntegrityFS) Create(name string) (*os.File, error) {
+	f, err := i.fileSystem.Create(name)
+	if err != nil {
+		return nil, err
+	}
+	if err := i.store.remove(name, false); err != nil {
+		f.Close()
+		return nil, err
+	}
+	return f, nil
+}
+
+// DefaultTempDir implements DefaultTempDirer.DefaultTempDir by returning
+// TempDir(i's underlying FS).
+func (i *IntegrityFS) DefaultTempDir() string {
+	return TempDir(i.fileSystem)
+}
+
+// Getxattr implements XattrFS.Getxattr if i's underlying FS implements XattrFS.
+func (i *IntegrityFS) Getxattr(name, attr string) ([]byte, error) {
+	xattrFS, ok := i.fileSystem.(XattrFS)
+	if !ok {
+		return nil, unsupportedError("Getxattr", name)
+	}
+	return xattrFS.Getxattr(name, attr)
+}
+
+// Glob implements filepath.Glob.
+func (i *IntegrityFS) Glob(pattern string) ([]string, error) {
+	return i.fileSystem.Glob(pattern)
+}
+
+// Lchown implements os.Lchown.
+func (i *IntegrityFS) Lchown(name string, uid, gid int) error {
+	return i.fileSystem.Lchown(name, uid, gid)
+}
+
+// Lgetxattr implements XattrFS.Lgetxattr if i's underlying FS implements
+// XattrFS.
+func (i *IntegrityFS) Lgetxattr(name, attr string) ([]byte, error) {
+	xattrFS, ok := i.fileSystem.(XattrFS)
+	if !ok {
+		return nil, unsupportedError("Lgetxattr", name)
+	}
+	return xattrFS.Lgetxattr(name, attr)
+}
+
+// Link implements os.Link. It is not supported by IntegrityFSs created with
+// NewIntegrityFS.
+func (i *IntegrityFS) Link(oldname, newname string) error {
+	if _, ok := i.store.(*manifestChecksumStore); ok {
+		return &os.LinkError{
+			Op:  "Link",
+			Old: oldname,
+			New: newname,
+			Err: errors.ErrUnsupported,
+		}
+	}
+	return i.fileSystem.Link(oldname, newname)
+}
+
+// Listxattr implements XattrFS.Listxattr if i's underlying FS implements
+// XattrFS.
+func (i *IntegrityFS) Listxattr(name string) ([]string, error) {
+	xattrFS, ok := i.fileSystem.(XattrFS)
+	if !ok {
+		return nil, unsupportedError("Listxattr", name)
+	}
+	return xattrFS.Listxattr(name)
+}
+
+// Llistxattr implements XattrFS.Llistxattr if i's underlying FS implements
+// XattrFS.
+func (i *IntegrityFS) Llistxattr(name string) ([]string, error) {
+	xattrFS, ok := i.fileSystem.(XattrFS)
+	if !ok {
+		return nil, unsupportedError("Llistxattr", name)
+	}
+	return xattrFS.Llistxattr(name)
+}
+
+// Lremovexattr implements XattrFS.Lremovexattr if i's underlying FS implements
+// XattrFS.
+func (i *IntegrityFS) Lremovexattr(name, attr string) error {
+	xattrFS, ok := i.fileSystem.(XattrFS)
+	if !ok {
+		return unsupportedError("Lremovexattr", name)
+	}
+	return xattrFS.Lremovexattr(name, attr)
+}
+
+// Lsetxattr implements XattrFS.Lsetxattr if i's underlying FS implements
+// XattrFS.
+func (i *IntegrityFS) Lsetxattr(name, attr string, value []byte) error {
+	xattrFS, ok := i.fileSystem.(XattrFS)
+	if !ok {
+		return unsupportedError("Lsetxattr", name)
+	}
+	return xattrFS.Lsetxattr(name, attr, value)
+}
+
+// Lstat implements os.Lstat.
+func (i *IntegrityFS) Lstat(name string) (fs.FileInfo, error) {
+	return i.fileSystem.Lstat(name)
+}
+
+// Mkdir implements os.Mkdir.
+func (i *IntegrityFS) Mkdir(name string, perm fs.FileMode) error {
+	return i.fileSystem.Mkdir(name, perm)
+}
+
+// Open implements os.Open. If name has a recorded checksum then its contents
+// are verified as they are read, and the final Read returns a
+// *ChecksumMismatchError instead of io.EOF if they do not match.
+func (i *IntegrityFS) Open(name string) (fs.File, error) {
+	want, ok, err := i.store.get(name)
+	if err != nil {
+		return nil, err
+	}
+	file, err := i.fileSystem.Open(name)
+	if err != nil || !ok {
+		return file, err
+	}
+	return &integrityFile{
+		File: file,
+		name: name,
+		want: want,
+		hash: sha256.New(),
+	}, nil
+}
+
+// OpenFile implements os.OpenFile.
+func (i *IntegrityFS) OpenFile(name string, flag int, perm fs.FileMode) (*os.File, error) {
+	f, err := i.fileSystem.OpenFile(name, flag, perm)
+	if err != nil {
+		return nil, err
+	}
+	if flag&(os.O_WRONLY|os.O_RDWR|os.O_APPEND|os.O_TRUNC) != 0 {
+		if err := i.store.remove(name, false); err != nil {
+			f.Close()
+			return nil, err
+		}
+	}
+	return f, nil
+}
+
+// PathSeparator implements PathSeparator.
+func (i *IntegrityFS) PathSeparator() rune {
+	return i.fileSystem.PathSeparator()
+}
+
+// RawPath implements RawPath.
+func (i *IntegrityFS) RawPath(path string) (string, error) {
+	return i.fileSystem.RawPath(path)
+}
+
+// ReadDir implements os.ReadDir.
+func (i *IntegrityFS) ReadDir(dirname string) ([]fs.DirEntry, error) {
+	return i.fileSystem.ReadDir(dirname)
+}
+
+// ReadFile implements os.ReadFile.
+func (i *IntegrityFS) ReadFile(filename string) ([]byte, error) {
+	data, err := i.fileSystem.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	if err := i.verify(filename, data); err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
+// Readlink implements os.Readlink.
+func (i *IntegrityFS) Readlink(name string) (string, error) {
+	return i.fileSystem.Readlink(name)
+}
+
+// Remove implements os.Remove.
+func (i *IntegrityFS) Remove(name string) error {
+	if err := i.fileSystem.Remove(name); err != nil {
+		return err
+	}
+	return i.store.remove(name, false)
+}
+
+// RemoveAll implements os.RemoveAll.
+func (i *IntegrityFS) RemoveAll(name string) error {
+	if err := i.fileSystem.RemoveAll(name); err != nil {
+		return err
+	}
+	return i.store.remove(name, true)
+}
+
+// Removexattr implements XattrFS.Removexattr if i's underlying FS implements
+// XattrFS.
+func (i *IntegrityFS) Removexattr(name, attr string) error {
+	xattrFS, ok := i.fileSystem.(XattrFS)
+	if !ok {
+		return unsupportedError("Removexattr", name)
+	}
+	return xattrFS.Removexattr(name, attr)
+}
+
+// Rename implements os.Rename.
+func (i *IntegrityFS) Rename(oldpath, newpath string) error {
+	if err := i.fileSystem.Rename(oldpath, newpath); err != nil {
+		return err
+	}
+	return i.store.rename(oldpath, newpath)
+}
+
+// Scrub verifies the contents of all regular files with recorded checksums in
+// the tree rooted at root and returns a *ChecksumMismatchError for each file
+// whose contents do not match. Errors reading individual files or directories
+// do not stop the scrub; they are collected and returned joined with
+// errors.Join after the whole tree has been walked.
+func (i *IntegrityFS) Scrub(root string) ([]*ChecksumMismatchError, error) {
+	var mismatches []*ChecksumMismatchError
+	var errs []error
+	if err := Walk(i.fileSystem, root, func(path string, info fs.FileInfo, err error) error {
+		switch {
+		case err != nil && path == root:
+			return err
+		case err != nil:
+			errs = append(errs, err)
+			if info == nil || info.IsDir() {
+				return fs.SkipDir
+			}
+			return nil
+		case !info.Mode().IsRegular():
+			return nil
+		}
+		var checksumMismatchError *ChecksumMismatchError
+		switch err := i.verifyFile(path); {
+		case errors.As(err, &checksumMismatchError):
+			mismatches = append(mismatches, checksumMismatchError)
+		case err != nil:
+			errs = append(errs, err)
+		}
+		return nil
+	}); err != nil {
+		return mismatches, err
+	}
+	return mismatches, errors.Join(errs...)
+}
+
+// Setxattr implements XattrFS.Setxattr if i's underlying FS implements XattrFS.
+func (i *IntegrityFS) Setxattr(name, attr string, value []byte) error {
+	xattrFS, ok := i.fileSystem.(XattrFS)
+	if !ok {
+		return unsupportedError("Setxattr", name)
+	}
+	return xattrFS.Setxattr(name, attr, value)
+}
+
+// Stat implements os.Stat.
+func (i *IntegrityFS) Stat(name string) (fs.FileInfo, error) {
+	return i.fileSystem.Stat(name)
+}
+
+// Statfs implements Statfser.Statfs if i's underlying FS implements Statfser.
+func (i *IntegrityFS) Statfs(name string) (*FSStat, error) {
+	statfser, ok := i.fileSystem.(Statfser)
+	if !ok {
+		return nil, unsupportedError("Statfs", name)
+	}
+	return statfser.Statfs(name)
+}
+
+// Symlink implements os.Symlink.
+func (i *IntegrityFS) Symlink(oldname, newname string) error {
+	return i.fileSystem.Symlink(oldname, newname)
+}
+
+// Truncate implements os.Truncate. The current contents of name are verified
+// before it is truncated. Its checksum is recorded as for WriteFile.
+func (i *IntegrityFS) Truncate(name string, size int64) error {
+	data, err := i.ReadFile(name)
+	if err != nil {
+		return err
+	}
+	if size < int64(len(data)) {
+		data = data[:size]
+	} else {
+		data = append(data, make([]byte, size-int64(len(data)))...)
+	}
+	if err := i.store.remove(name, false); err != nil {
+		return err
+	}
+	if err := i.fileSystem.Truncate(name, size); err != nil {
+		return err
+	}
+	return i.store.set(name, checksum(data))
+}
+
+// WriteFile implements os.WriteFile. The checksum of filename is removed
+// before it is written and the new checksum is recorded afterwards, so if
+// recording the new checksum fails then filename is left without a checksum,
+// rather than with a checksum that does not match its contents.
+func (i *IntegrityFS) WriteFile(filename string, data []byte, perm fs.FileMode) error {
+	if err := i.store.remove(filename, false); err != nil {
+		return err
+	}
+	if err := i.fileSystem.WriteFile(filename, data, perm); err != nil {
+		return err
+	}
+	return i.store.set(filename, checksum(data))
+}
+
+// verify returns a *ChecksumMismatchError if name has a recorded checksum and
+// data does not match it.
+func (i *IntegrityFS) verify(name string, data []byte) error {
+	want, ok, err := i.store.get(name)
+	if err != nil || !ok {
+		return err
+	}
+	if got := checksum(data); got != want {
+		return &ChecksumMismatchError{
+			Path: name,
+			Want: want,
+			Got:  got,
+		}
+	}
+	return nil
+}
+
+// verifyFile verifies the contents of name.
+func (i *IntegrityFS) verifyFile(name string) error {
+	file, err := i.Open(name)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	_, err = io.Copy(io.Discard, file)
+	return err
+}
+
+// An integrityFile is a file in an IntegrityFS with a recorded checksum open
+// for reading.
+type integrityFile struct {
+	fs.File
+	name string
+	want string
+	hash hash.Hash
+}
+
+// Read implements fs.File.Read.
+func (f *integrityFile) Read(p []byte) (int, error) {
+	n, err := f.File.Read(p)
+	f.hash.Write(p[:n])
+	if errors.Is(err, io.EOF) {
+		if got := hex.EncodeToString(f.hash.Sum(nil)); got != f.want {
+			return n, &ChecksumMismatchError{
+				Path: f.name,
+				Want: f.want,
+				Got:  got,
+			}
+		}
+	}
+	return n, err
+}
+
+// A manifestChecksumStore stores checksums in a JSON manifest.
+type manifestChecksumStore struct {
+	fileSystem   FS
+	manifestPath string
+	mu           sync.Mutex
+	checksums    map[string]string
+}
+
+func (s *manifestChecksumStore) get(name string) (string, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	checksum, ok := s.checksums[manifestKey(name)]
+	return checksum, ok, nil
+}
+
+func (s *manifestChecksumStore) remove(name string, recursive bool) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	key := manifestKey(name)
+	delete(s.checksums, key)
+	if recursive {
+		for k := range s.checksums {
+			if manifestKeyHasPrefix(k, key) {
+				delete(s.checksums, k)
+			}
+		}
+	}
+	return s.saveLocked()
+}
+
+func (s *manifestChecksumStore) rename(oldpath, newpath string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	oldKey, newKey := manifestKey(oldpath), manifestKey(newpath)
+	checksums := make(map[string]string, len(s.checksums))
+	for k, checksum := range s.checksums {
+		switch {
+		case k == oldKey:
+			checksums[newKey] = checksum
+		case manifestKeyHasPrefix(k, oldKey):
+			checksums[newKey+k[len(oldKey):]] = checksum
+		case k == newKey || manifestKeyHasPrefix(k, newKey):
+			// Replaced by the rename.
+		default:
+			checksums[k] = checksum
+		}
+	}
+	s.checksums = checksums
+	return s.saveLocked()
+}
+
+func (s *manifestChecksumStore) set(name, checksum string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.checksums[manifestKey(name)] = checksum
+	return s.saveLocked()
+}
+
+// saveLocked writes s's manifest. s.mu must be held.
+func (s *manifestChecksumStore) saveLocked() error {
+	data, err := json.MarshalIndent(s.checksums, "", "  ")
+	if err != nil {
+		return err
+	}
+	tempPath := s.manifestPath + ".tmp"
+	if err := s.fileSystem.WriteFile(tempPath, append(data, '\n'), 0o644); err != nil {
+		return err
+	}
+	return s.fileSystem.Rename(tempPath, s.manifestPath)
+}
+
+// An xattrChecksumStore stores checksums in the ChecksumXattr extended
+// attribute of each file. Files on filesystems that do not support extended
+// attributes are treated as having no recorded checksum, so they can be read
+// but not written.
+type xattrChecksumStore struct {
+	xattrFS XattrFS
+}
+
+func (s *xattrChecksumStore) get(name string) (string, bool, error) {
+	value, err := s.xattrFS.Getxattr(name, ChecksumXattr)
+	switch {
+	case errors.Is(err, ErrNoXattr), errors.Is(err, errors.ErrUnsupported):
+		return "", false, nil
+	case err != nil:
+		return "", false, err
+	default:
+		return string(value), true, nil
+	}
+}
+
+func (s *xattrChecksumStore) remove(name string, recursive bool) error {
+	switch err := s.xattrFS.Removexattr(name, ChecksumXattr); {
+	case errors.Is(err, ErrNoXattr), errors.Is(err, errors.ErrUnsupported), errors.Is(err, fs.ErrNotExist):
+		return nil
+	default:
+		return err
+	}
+}
+
+func (s *xattrChecksumStore) rename(oldpath, newpath string) error {
+	return nil
+}
+
+func (s *xattrChecksumStore) set(name, checksum string) error {
+	return s.xattrFS.Setxattr(name, ChecksumXattr, []byte(checksum))
+}
+
+// checksum returns the hex-encoded SHA-256 checksum of data.
+func checksum(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// manifestKey returns the manifest key for name.
+func manifestKey(name string) string {
+	return filepath.ToSlash(filepath.Clean(name))
+}
+
+// manifestKeyHasPrefix returns whether key is a descendant of prefix.
+func manifestKeyHasPrefix(key, prefix string) bool {
+	if !strings.HasSuffix(prefix, "/") {
+		prefix += "/"
+	}
+	return strings.HasPrefix(key, prefix)
+}
@@ -0,0 +1,11 @@
+package vfs_test
+
+import "github.com/twpayne/go-vfs/v5"
+
+var _ vfs.FS = &vfs.IntegrityFS{}
+
+var _ vfs.XattrFS = &vfs.IntegrityFS{}
+
+var _ vfs.Statfser = &vfs.IntegrityFS{}
+
+var _ vfs.DefaultTempDirer = &vfs.IntegrityFS{}
@@ -0,0 +1,212 @@
+package vfs
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"syscall"
+	"time"
+)
+
+// An IOFS adapts an io/fs.FS, for example an embed.FS or an fstest.MapFS, to a
+// read-only FS, so that it can be mounted in a MountFS. Names must be absolute
+// and are mapped to io/fs names by removing the leading slash. Methods that
+// modify the FS return fs.ErrPermission. io/fs.FS files are not *os.Files, so
+// OpenFile returns errors.ErrUnsupported and Open should be used instead.
+// Symlinks are not supported: Lstat is equivalent to Stat and Readlink returns
+// EINVAL.
+type IOFS struct {
+	fileSystem fs.FS
+}
+
+// NewIOFS returns a new *IOFS operating on fileSystem.
+func NewIOFS(fileSystem fs.FS) *IOFS {
+	return &IOFS{
+		fileSystem: fileSystem,
+	}
+}
+
+// Chmod implements os.Chmod.
+func (i *IOFS) Chmod(name string, mode fs.FileMode) error {
+	return permError("Chmod", name)
+}
+
+// Chown implements os.Chown.
+func (i *IOFS) Chown(name string, uid, gid int) error {
+	return permError("Chown", name)
+}
+
+// Chtimes implements os.Chtimes.
+func (i *IOFS) Chtimes(name string, atime, mtime time.Time) error {
+	return permError("Chtimes", name)
+}
+
+// Create implements os.Create.
+func (i *IOFS) Create(name string) (*os.File, error) {
+	return nil, permError("Create", name)
+}
+
+// Glob implements filepath.Glob.
+func (i *IOFS) Glob(pattern string) ([]string, error) {
+	return globFS(i, pattern)
+}
+
+// Lchown implements os.Lchown.
+func (i *IOFS) Lchown(name string, uid, gid int) error {
+	return permError("Lchown", name)
+}
+
+// Link implements os.Link.
+func (i *IOFS) Link(oldname, newname string) error {
+	return permError("Link", newname)
+}
+
+// Lstat implements os.Lstat. It is equivalent to Stat.
+func (i *IOFS) Lstat(name string) (fs.FileInfo, error) {
+	ioName, err := i.ioName("Lstat", name)
+	if err != nil {
+		return nil, err
+	}
+	info, err := fs.Stat(i.fileSystem, ioName)
+	return info, ioPathError(err, name)
+}
+
+// Mkdir implements os.Mkdir.
+func (i *IOFS) Mkdir(name string, perm fs.FileMode) error {
+	return permError("Mkdir", name)
+}
+
+// Open implements os.Open.
+func (i *IOFS) Open(name string) (fs.File, error) {
+	ioName, err := i.ioName("Open", name)
+	if err != nil {
+		return nil, err
+	}
+	file, err := i.fileSystem.Open(ioName)
+	return file, ioPathError(err, name)
+}
+
+// OpenFile implements os.OpenFile. It returns fs.ErrPermission if flag would
+// modify the file and errors.ErrUnsupported otherwise.
+func (i *IOFS) OpenFile(name string, flag int, perm fs.FileMode) (*os.File, error) {
+	if flag&(os.O_WRONLY|os.O_RDWR|os.O_APPEND|os.O_CREATE|os.O_TRUNC) != 0 {
+		return nil, permError("OpenFile", name)
+	}
+	return nil, unsupportedError("OpenFile", name)
+}
+
+// PathSeparator implements PathSeparator.
+func (i *IOFS) PathSeparator() rune {
+	return '/'
+}
+
+// RawPath implements RawPath. io/fs.FS names do not correspond to paths on the
+// underlying filesystem, so it always returns errors.ErrUnsupported.
+func (i *IOFS) RawPath(name string) (string, error) {
+	return "", unsupportedError("RawPath", name)
+}
+
+// ReadDir implements os.ReadDir.
+func (i *IOFS) ReadDir(dirname string) ([]fs.DirEntry, error) {
+	ioName, err := i.ioName("ReadDir", dirname)
+	if err != nil {
+		return nil, err
+	}
+	dirEntries, err := fs.ReadDir(i.fileSystem, ioName)
+	return dirEntries, ioPathError(err, dirname)
+}
+
+// ReadFile implements os.ReadFile.
+func (i *IOFS) ReadFile(filename string) ([]byte, error) {
+	ioName, err := i.ioName("ReadFile", filename)
+	if err != nil {
+		return nil, err
+	}
+	data, err := fs.ReadFile(i.fileSystem, ioName)
+	return data, ioPathError(err, filename)
+}
+
+// Readlink implements os.Readlink. As symlinks are not supported, it returns
+// EINVAL if name exists.
+func (i *IOFS) Readlink(name string) (string, error) {
+	if _, err := i.Lstat(name); err != nil {
+		return "", err
+	}
+	return "", &os.PathError{
+		Op:   "Readlink",
+		Path: name,
+		Err:  syscall.EINVAL,
+	}
+}
+
+// Remove implements os.Remove.
+func (i *IOFS) Remove(name string) error {
+	return permError("Remove", name)
+}
+
+// RemoveAll implements os.RemoveAll.
+func (i *IOFS) RemoveAll(name string) error {
+	return permError("RemoveAll", name)
+}
+
+// Rename implements os.Rename.
+func (i *IOFS) Rename(oldpath, newpath string) error {
+	return permError("Rename", newpath)
+}
+
+// Stat implements os.Stat.
+func (i *IOFS) Stat(name string) (fs.FileInfo, error) {
+	ioName, err := i.ioName("Stat", name)
+	if err != nil {
+		return nil, err
+	}
+	info, err := fs.Stat(i.fileSystem, ioName)
+	return info, ioPathError(err, name)
+}
+
+// Symlink implements os.Symlink.
+func (i *IOFS) Symlink(oldname, newname string) error {
+	return permError("Symlink", newname)
+}
+
+// Truncate implements os.Truncate.
+func (i *IOFS) Truncate(name string, size int64) error {
+	return permError("Truncate", name)
+}
+
+// WriteFile implements os.WriteFile.
+func (i *IOFS) WriteFile(filename string, data []byte, perm fs.FileMode) error {
+	return permError("WriteFile", filename)
+}
+
+// ioName returns the io/fs name of name.
+func (i *IOFS) ioName(op, name string) (string, error) {
+	slashName := filepath.ToSlash(name)
+	if !path.IsAbs(slashName) {
+		return "", &os.PathError{
+			Op:   op,
+			Path: name,
+			Err:  errRelativePath,
+		}
+	}
+	ioName := path.Clean(slashName)[1:]
+	if ioName == "" {
+		return ".", nil
+	}
+	return ioName, nil
+}
+
+// ioPathError returns err with the path of any *fs.PathError replaced by name.
+func ioPathError(err error, name string) error {
+	var pathErr *fs.PathError
+	if !errors.As(err, &pathErr) {
+		return err
+	}
+	return &fs.PathError{
+		Op:   pathErr.Op,
+		Path: name,
+		Err:  pathErr.Err,
+	}
+}
@@ -0,0 +1,37 @@
+//go:build darwin || freebsd || netbsd
+
+package vfs
+
+import (
+	"io/fs"
+	"os"
+
+	"golang.org/x/sys/unix"
+)
+
+// Lchmod implements Lchmoder.Lchmod with fchmodat and AT_SYMLINK_NOFOLLOW.
+func (osfs) Lchmod(name string, mode fs.FileMode) error {
+	if err := unix.Fchmodat(unix.AT_FDCWD, name, syscallMode(mode), unix.AT_SYMLINK_NOFOLLOW); err != nil {
+		return &os.PathError{
+			Op:   "Lchmod",
+			Path: name,
+			Err:  err,
+		}
+	}
+	return nil
+}
+
+// syscallMode returns the syscall-specific mode bits for mode.
+func syscallMode(mode fs.FileMode) uint32 {
+	m := uint32(mode.Perm())
+	if mode&fs.ModeSetuid != 0 {
+		m |= unix.S_ISUID
+	}
+	if mode&fs.ModeSetgid != 0 {
+		m |= unix.S_ISGID
+	}
+	if mode&fs.ModeSticky != 0 {
+		m |= unix.S_ISVTX
+	}
+	return m
+}
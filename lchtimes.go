@@ -0,0 +1,61 @@
+package vfs
+
+import (
+	"errors"
+	"io/fs"
+	"time"
+)
+
+// An Lchmoder is an FS that can change the mode of a symbolic link itself,
+// rather than the file that it refers to.
+type Lchmoder interface {
+	Lchmod(name string, mode fs.FileMode) error
+}
+
+// An Lchtimeser is an FS that can change the access and modification times of a
+// symbolic link itself, rather than the file that it refers to.
+type Lchtimeser interface {
+	Lchtimes(name string, atime, mtime time.Time) error
+}
+
+// Lchmod changes the mode of name in fileSystem without following symbolic
+// links. If fileSystem implements Lchmoder then its Lchmod method is used.
+// Otherwise, if name is not a symbolic link then fileSystem.Chmod is used, and
+// if it is then an error wrapping errors.ErrUnsupported is returned.
+func Lchmod(fileSystem FS, name string, mode fs.FileMode) error {
+	if lchmoder, ok := fileSystem.(Lchmoder); ok {
+		if err := lchmoder.Lchmod(name, mode); !errors.Is(err, errors.ErrUnsupported) {
+			return err
+		}
+	}
+	switch info, err := fileSystem.Lstat(name); {
+	case err != nil:
+		return err
+	case info.Mode().Type() == fs.ModeSymlink:
+		return unsupportedError("Lchmod", name)
+	default:
+		return fileSystem.Chmod(name, mode)
+	}
+}
+
+// Lchtimes changes the access and modification times of name in fileSystem
+// without following symbolic links. If fileSystem implements Lchtimeser then its
+// Lchtimes method is used. Otherwise, if name is not a symbolic link then
+// fileSystem.Chtimes is used, and if it is then an error wrapping
+// errors.ErrUnsupported is returned. As with os.Chtimes, a zero atime or mtime
+// leaves the corresponding time unchanged.
+func Lchtimes(fileSystem FS, name string, atime, mtime time.Time) error {
+	if lchtimeser, ok := fileSystem.(Lchtimeser); ok {
+		if err := lchtimeser.Lchtimes(name, atime, mtime); !errors.Is(err, errors.ErrUnsupported) {
+			return err
+		}
+	}
+	switch info, err := fileSystem.Lstat(name); {
+	case err != nil:
+		return err
+	case info.Mode().Type() == fs.ModeSymlink:
+		return unsupportedError("Lchtimes", name)
+	default:
+		return fileSystem.Chtimes(name, atime, mtime)
+	}
+}
@@ -0,0 +1,5 @@
+package vfs
+
+// utimeOmit is the nanoseconds value that tells utimensat to leave a time
+// unchanged. It is not defined by golang.org/x/sys/unix on netbsd.
+const utimeOmit = (1 << 30) - 2
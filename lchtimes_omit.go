@@ -0,0 +1,9 @@
+//go:build dragonfly || freebsd || linux || openbsd || solaris
+
+package vfs
+
+import "golang.org/x/sys/unix"
+
+// utimeOmit is the nanoseconds value that tells utimensat to leave a time
+// unchanged.
+const utimeOmit = unix.UTIME_OMIT
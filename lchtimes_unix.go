@@ -0,0 +1,36 @@
+//go:build darwin || dragonfly || freebsd || linux || netbsd || openbsd || solaris
+
+package vfs
+
+import (
+	"os"
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+// Lchtimes implements Lchtimeser.Lchtimes with utimensat and
+// AT_SYMLINK_NOFOLLOW.
+func (osfs) Lchtimes(name string, atime, mtime time.Time) error {
+	ts := []unix.Timespec{
+		utimeTimespec(atime),
+		utimeTimespec(mtime),
+	}
+	if err := unix.UtimesNanoAt(unix.AT_FDCWD, name, ts, unix.AT_SYMLINK_NOFOLLOW); err != nil {
+		return &os.PathError{
+			Op:   "Lchtimes",
+			Path: name,
+			Err:  err,
+		}
+	}
+	return nil
+}
+
+// utimeTimespec returns the unix.Timespec for t to be passed to utimensat. A
+// zero t leaves the corresponding time unchanged.
+func utimeTimespec(t time.Time) unix.Timespec {
+	if t.IsZero() {
+		return unix.Timespec{Nsec: utimeOmit}
+	}
+	return unix.NsecToTimespec(t.UnixNano())
+}
@@ -0,0 +1,157 @@
+package vfs
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"strconv"
+	"sync"
+)
+
+// ErrLocked is returned when a lock cannot be acquired without waiting.
+var ErrLocked = errors.New("locked")
+
+// A LockMode is the mode of an advisory lock.
+type LockMode int
+
+// LockModes.
+const (
+	LockShared LockMode = iota
+	LockExclusive
+)
+
+// A Lock is an advisory lock held on a path, returned by a Locker.
+type Lock struct {
+	unlockFunc func() error
+	unlockOnce sync.Once
+	unlockErr  error
+}
+
+// A Locker is an FS that supports advisory locks on existing paths. If wait is
+// false and the lock is held elsewhere in an incompatible mode then Lock
+// returns an error wrapping ErrLocked.
+type Locker interface {
+	Lock(name string, mode LockMode, wait bool) (*Lock, error)
+}
+
+// NewLock returns a new *Lock that calls unlockFunc when unlocked. It is
+// intended for use by implementations of Locker.
+func NewLock(unlockFunc func() error) *Lock {
+	return &Lock{
+		unlockFunc: unlockFunc,
+	}
+}
+
+// Unlock releases l. It is safe to call Unlock multiple times.
+func (l *Lock) Unlock() error {
+	l.unlockOnce.Do(func() {
+		l.unlockErr = l.unlockFunc()
+	})
+	return l.unlockErr
+}
+
+// A LockFile is a file that contains the PID of the process that holds it.
+type LockFile struct {
+	fileSystem FS
+	name       string
+}
+
+// AcquireLockFile creates name in fileSystem containing the PID of the current
+// process. If name already exists and contains the PID of a process that no
+// longer exists then it is considered stale, removed, and acquisition is
+// retried. Otherwise, it returns an error wrapping ErrLocked.
+func AcquireLockFile(fileSystem FS, name string) (*LockFile, error) {
+	pid := os.Getpid()
+	tempName := name + "." + strconv.Itoa(pid) + ".tmp"
+	if err := fileSystem.WriteFile(tempName, []byte(strconv.Itoa(pid)+"\n"), 0o644); err != nil {
+		return nil, err
+	}
+	defer fileSystem.Remove(tempName) //nolint:errcheck
+
+	for range 3 {
+		// Link fails if name already exists, so the lock file always appears
+		// with its complete contents.
+		switch err := fileSystem.Link(tempName, name); {
+		case err == nil:
+			return &LockFile{
+				fileSystem: fileSystem,
+				name:       name,
+			}, nil
+		case !errors.Is(err, fs.ErrExist):
+			return nil, err
+		}
+
+		holderPID, err := readLockFilePID(fileSystem, name)
+		switch {
+		case errors.Is(err, fs.ErrNotExist):
+			continue
+		case err != nil:
+			return nil, err
+		case holderPID == pid || processExists(holderPID):
+			return nil, lockedError("AcquireLockFile", name, holderPID)
+		}
+
+		// name is stale. Move it aside before removing it so that we do not
+		// remove a lock file created by another process in the meantime.
+		staleName := name + "." + strconv.Itoa(pid) + ".stale"
+		if err := fileSystem.Rename(name, staleName); errors.Is(err, fs.ErrNotExist) {
+			continue
+		} else if err != nil {
+			return nil, err
+		}
+		if stalePID, err := readLockFilePID(fileSystem, staleName); err == nil && stalePID != holderPID && processExists(stalePID) {
+			// Another process replaced the stale lock file before we moved it.
+			// Try to put it back.
+			_ = fileSystem.Link(staleName, name)
+			_ = fileSystem.Remove(staleName)
+			return nil, lockedError("AcquireLockFile", name, stalePID)
+		}
+		if err := fileSystem.Remove(staleName); err != nil {
+			return nil, err
+		}
+	}
+	return nil, &os.PathError{
+		Op:   "AcquireLockFile",
+		Path: name,
+		Err:  ErrLocked,
+	}
+}
+
+// Name returns l's name.
+func (l *LockFile) Name() string {
+	return l.name
+}
+
+// Release removes l.
+func (l *LockFile) Release() error {
+	return l.fileSystem.Remove(l.name)
+}
+
+// lockedError returns an *os.PathError wrapping ErrLocked for a lock held by
+// pid.
+func lockedError(op, path string, pid int) error {
+	return &os.PathError{
+		Op:   op,
+		Path: path,
+		Err:  fmt.Errorf("%w by PID %d", ErrLocked, pid),
+	}
+}
+
+// readLockFilePID returns the PID contained in the lock file name.
+func readLockFilePID(fileSystem FS, name string) (int, error) {
+	data, err := fileSystem.ReadFile(name)
+	if err != nil {
+		return 0, err
+	}
+	pid, err := strconv.Atoi(string(bytes.TrimSpace(data)))
+	if err != nil {
+		return 0, &os.PathError{
+			Op:   "AcquireLockFile",
+			Path: name,
+			Err:  err,
+		}
+	}
+	return pid, nil
+}
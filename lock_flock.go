@@ -0,0 +1,60 @@
+//go:build darwin || dragonfly || freebsd || linux || netbsd || openbsd
+
+package vfs
+
+import (
+	"errors"
+	"os"
+
+	"golang.org/x/sys/unix"
+)
+
+// Lock implements Locker.Lock with flock(2).
+func (osfs) Lock(name string, mode LockMode, wait bool) (*Lock, error) {
+	file, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	how := unix.LOCK_SH
+	if mode == LockExclusive {
+		how = unix.LOCK_EX
+	}
+	if !wait {
+		how |= unix.LOCK_NB
+	}
+	rawConn, err := file.SyscallConn()
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+	var flockErr error
+	if err := rawConn.Control(func(fd uintptr) {
+		for {
+			flockErr = unix.Flock(int(fd), how) //nolint:gosec
+			if !errors.Is(flockErr, unix.EINTR) {
+				return
+			}
+		}
+	}); err != nil {
+		file.Close()
+		return nil, err
+	}
+	switch {
+	case errors.Is(flockErr, unix.EWOULDBLOCK):
+		file.Close()
+		return nil, &os.PathError{
+			Op:   "Lock",
+			Path: name,
+			Err:  ErrLocked,
+		}
+	case flockErr != nil:
+		file.Close()
+		return nil, &os.PathError{
+			Op:   "Lock",
+			Path: name,
+			Err:  flockErr,
+		}
+	}
+	// Closing the file releases the lock.
+	return NewLock(file.Close), nil
+}
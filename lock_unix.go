@@ -0,0 +1,14 @@
+//go:build unix
+
+package vfs
+
+import (
+	"errors"
+	"syscall"
+)
+
+// processExists returns whether a process with pid exists.
+func processExists(pid int) bool {
+	err := syscall.Kill(pid, 0)
+	return err == nil || errors.Is(err, syscall.EPERM)
+}
@@ -0,0 +1,62 @@
+package vfs
+
+import (
+	"errors"
+	"os"
+
+	"golang.org/x/sys/windows"
+)
+
+// Lock implements Locker.Lock with LockFileEx.
+func (osfs) Lock(name string, mode LockMode, wait bool) (*Lock, error) {
+	file, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	var flags uint32
+	if mode == LockExclusive {
+		flags |= windows.LOCKFILE_EXCLUSIVE_LOCK
+	}
+	if !wait {
+		flags |= windows.LOCKFILE_FAIL_IMMEDIATELY
+	}
+	handle := windows.Handle(file.Fd())
+	switch err := windows.LockFileEx(handle, flags, 0, ^uint32(0), ^uint32(0), &windows.Overlapped{}); {
+	case errors.Is(err, windows.ERROR_LOCK_VIOLATION):
+		file.Close()
+		return nil, &os.PathError{
+			Op:   "Lock",
+			Path: name,
+			Err:  ErrLocked,
+		}
+	case err != nil:
+		file.Close()
+		return nil, &os.PathError{
+			Op:   "Lock",
+			Path: name,
+			Err:  err,
+		}
+	}
+	return NewLock(func() error {
+		err := windows.UnlockFileEx(handle, 0, ^uint32(0), ^uint32(0), &windows.Overlapped{})
+		if closeErr := file.Close(); err == nil {
+			err = closeErr
+		}
+		return err
+	}), nil
+}
+
+// processExists returns whether a process with pid exists.
+func processExists(pid int) bool {
+	handle, err := windows.OpenProcess(windows.PROCESS_QUERY_LIMITED_INFORMATION, false, uint32(pid)) //nolint:gosec
+	if err != nil {
+		return errors.Is(err, windows.ERROR_ACCESS_DENIED)
+	}
+	defer windows.CloseHandle(handle) //nolint:errcheck
+	var exitCode uint32
+	if err := windows.GetExitCodeProcess(handle, &exitCode); err != nil {
+		return true
+	}
+	const stillActive = 259
+	return exitCode == stillActive
+}
@@ -0,0 +1,562 @@
+package vfs
+
+import (
+	"context"
+	"io/fs"
+	"log/slog"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// A MethodClass classifies FS methods for logging.
+type MethodClass int
+
+// MethodClasses.
+const (
+	// MethodClassRead is methods that read names, contents, or metadata.
+	MethodClassRead MethodClass = iota
+	// MethodClassMetadata is methods that modify metadata.
+	MethodClassMetadata
+	// MethodClassWrite is methods that create, modify, or remove names or
+	// contents.
+	MethodClassWrite
+	numMethodClasses
+)
+
+// A LoggingFSOption sets an option on a LoggingFS.
+type LoggingFSOption func(*LoggingFS)
+
+// A LoggingFS operates on an existing FS and logs every call made through it
+// to a *slog.Logger. Each call generates one record with the method name as
+// its message and attributes describing its arguments, duration, and result.
+// Calls made to *os.Files returned by Create and OpenFile are not logged.
+type LoggingFS struct {
+	fileSystem FS
+	logger     *slog.Logger
+	levels     [numMethodClasses]slog.Level
+	filter     func(string) bool
+}
+
+// LoggingFSFilter sets a LoggingFS's path filter. Only calls where filter
+// returns true for at least one path argument are logged. Glob patterns are
+// passed to filter unchanged.
+func LoggingFSFilter(filter func(path string) bool) LoggingFSOption {
+	return func(l *LoggingFS) {
+		l.filter = filter
+	}
+}
+
+// LoggingFSLevel sets the level at which a LoggingFS logs calls to methods in
+// class. By default, MethodClassRead and MethodClassMetadata are logged at
+// slog.LevelDebug and MethodClassWrite at slog.LevelInfo.
+func LoggingFSLevel(class MethodClass, level slog.Level) LoggingFSOption {
+	return func(l *LoggingFS) {
+		l.levels[class] = level
+	}
+}
+
+// NewLoggingFS returns a new *LoggingFS operating on fileSystem and logging to
+// logger with the given options set.
+func NewLoggingFS(fileSystem FS, logger *slog.Logger, options ...LoggingFSOption) *LoggingFS {
+	l := &LoggingFS{
+		fileSystem: fileSystem,
+		logger:     logger,
+		levels: [numMethodClasses]slog.Level{
+			MethodClassRead:     slog.LevelDebug,
+			MethodClassMetadata: slog.LevelDebug,
+			MethodClassWrite:    slog.LevelInfo,
+		},
+	}
+	for _, option := range options {
+		option(l)
+	}
+	return l
+}
+
+// Chmod implements os.Chmod.
+func (l *LoggingFS) Chmod(name string, mode fs.FileMode) error {
+	start := time.Now()
+	err := l.fileSystem.Chmod(name, mode)
+	l.log(MethodClassMetadata, "Chmod", []string{name}, start, err,
+		slog.Any("mode", mode),
+	)
+	return err
+}
+
+// Chown implements os.Chown.
+func (l *LoggingFS) Chown(name string, uid, gid int) error {
+	start := time.Now()
+	err := l.fileSystem.Chown(name, uid, gid)
+	l.log(MethodClassMetadata, "Chown", []string{name}, start, err,
+		slog.Int("uid", uid),
+		slog.Int("gid", gid),
+	)
+	return err
+}
+
+// Chtimes implements os.Chtimes.
+func (l *LoggingFS) Chtimes(name string, atime, mtime time.Time) error {
+	start := time.Now()
+	err := l.fileSystem.Chtimes(name, atime, mtime)
+	l.log(MethodClassMetadata, "Chtimes", []string{name}, start, err,
+		slog.Time("atime", atime),
+		slog.Time("mtime", mtime),
+	)
+	return err
+}
+
+// Create implements os.Create.
+func (l *LoggingFS) Create(name string) (*os.File, error) {
+	start := time.Now()
+	f, err := l.fileSystem.Create(name)
+	l.log(MethodClassWrite, "Create", []string{name}, start, err)
+	return f, err
+}
+
+// DefaultTempDir implements DefaultTempDirer.DefaultTempDir by returning
+// TempDir(l's underlying FS).
+func (l *LoggingFS) DefaultTempDir() string {
+	return TempDir(l.fileSystem)
+}
+
+// Getxattr implements XattrFS.Getxattr if l's underlying FS implements XattrFS.
+func (l *LoggingFS) Getxattr(name, attr string) ([]byte, error) {
+	xattrFS, ok := l.fileSystem.(XattrFS)
+	if !ok {
+		return nil, unsupportedError("Getxattr", name)
+	}
+	start := time.Now()
+	value, err := xattrFS.Getxattr(name, attr)
+	l.log(MethodClassRead, "Getxattr", []string{name}, start, err,
+		slog.String("attr", attr),
+	)
+	return value, err
+}
+
+// Glob implements filepath.Glob.
+func (l *LoggingFS) Glob(pattern string) ([]string, error) {
+	start := time.Now()
+	matches, err := l.fileSystem.Glob(pattern)
+	l.log(MethodClassRead, "Glob", []string{pattern}, start, err,
+		slog.Int("matches", len(matches)),
+	)
+	return matches, err
+}
+
+// Lchmod implements Lchmoder.Lchmod if l's underlying FS implements Lchmoder.
+func (l *LoggingFS) Lchmod(name string, mode fs.FileMode) error {
+	lchmoder, ok := l.fileSystem.(Lchmoder)
+	if !ok {
+		return unsupportedError("Lchmod", name)
+	}
+	start := time.Now()
+	err := lchmoder.Lchmod(name, mode)
+	l.log(MethodClassMetadata, "Lchmod", []string{name}, start, err,
+		slog.Any("mode", mode),
+	)
+	return err
+}
+
+// Lchown implements os.Lchown.
+func (l *LoggingFS) Lchown(name string, uid, gid int) error {
+	start := time.Now()
+	err := l.fileSystem.Lchown(name, uid, gid)
+	l.log(MethodClassMetadata, "Lchown", []string{name}, start, err,
+		slog.Int("uid", uid),
+		slog.Int("gid", gid),
+	)
+	return err
+}
+
+// Lchtimes implements Lchtimeser.Lchtimes if l's underlying FS implements
+// Lchtimeser.
+func (l *LoggingFS) Lchtimes(name string, atime, mtime time.Time) error {
+	lchtimeser, ok := l.fileSystem.(Lchtimeser)
+	if !ok {
+		return unsupportedError("Lchtimes", name)
+	}
+	start := time.Now()
+	err := lchtimeser.Lchtimes(name, atime, mtime)
+	l.log(MethodClassMetadata, "Lchtimes", []string{name}, start, err,
+		slog.Time("atime", atime),
+		slog.Time("mtime", mtime),
+	)
+	return err
+}
+
+// Lgetxattr implements XattrFS.Lgetxattr if l's underlying FS implements
+// XattrFS.
+func (l *LoggingFS) Lgetxattr(name, attr string) ([]byte, error) {
+	xattrFS, ok := l.fileSystem.(XattrFS)
+	if !ok {
+		return nil, unsupportedError("Lgetxattr", name)
+	}
+	start := time.Now()
+	value, err := xattrFS.Lgetxattr(name, attr)
+	l.log(MethodClassRead, "Lgetxattr", []string{name}, start, err,
+		slog.String("attr", attr),
+	)
+	return value, err
+}
+
+// Link implements os.Link.
+func (l *LoggingFS) Link(oldname, newname string) error {
+	start := time.Now()
+	err := l.fileSystem.Link(oldname, newname)
+	l.log(MethodClassWrite, "Link", []string{oldname, newname}, start, err)
+	return err
+}
+
+// Listxattr implements XattrFS.Listxattr if l's underlying FS implements
+// XattrFS.
+func (l *LoggingFS) Listxattr(name string) ([]string, error) {
+	xattrFS, ok := l.fileSystem.(XattrFS)
+	if !ok {
+		return nil, unsupportedError("Listxattr", name)
+	}
+	start := time.Now()
+	attrs, err := xattrFS.Listxattr(name)
+	l.log(MethodClassRead, "Listxattr", []string{name}, start, err,
+		slog.Int("attrs", len(attrs)),
+	)
+	return attrs, err
+}
+
+// Llistxattr implements XattrFS.Llistxattr if l's underlying FS implements
+// XattrFS.
+func (l *LoggingFS) Llistxattr(name string) ([]string, error) {
+	xattrFS, ok := l.fileSystem.(XattrFS)
+	if !ok {
+		return nil, unsupportedError("Llistxattr", name)
+	}
+	start := time.Now()
+	attrs, err := xattrFS.Llistxattr(name)
+	l.log(MethodClassRead, "Llistxattr", []string{name}, start, err,
+		slog.Int("attrs", len(attrs)),
+	)
+	return attrs, err
+}
+
+// Lock implements Locker.Lock if l's underlying FS implements Locker.
+func (l *LoggingFS) Lock(name string, mode LockMode, wait bool) (*Lock, error) {
+	locker, ok := l.fileSystem.(Locker)
+	if !ok {
+		return nil, unsupportedError("Lock", name)
+	}
+	start := time.Now()
+	lock, err := locker.Lock(name, mode, wait)
+	l.log(MethodClassRead, "Lock", []string{name}, start, err,
+		slog.Bool("exclusive", mode == LockExclusive),
+		slog.Bool("wait", wait),
+	)
+	return lock, err
+}
+
+// Lremovexattr implements XattrFS.Lremovexattr if l's underlying FS implements
+// XattrFS.
+func (l *LoggingFS) Lremovexattr(name, attr string) error {
+	xattrFS, ok := l.fileSystem.(XattrFS)
+	if !ok {
+		return unsupportedError("Lremovexattr", name)
+	}
+	start := time.Now()
+	err := xattrFS.Lremovexattr(name, attr)
+	l.log(MethodClassMetadata, "Lremovexattr", []string{name}, start, err,
+		slog.String("attr", attr),
+	)
+	return err
+}
+
+// Lsetxattr implements XattrFS.Lsetxattr if l's underlying FS implements
+// XattrFS.
+func (l *LoggingFS) Lsetxattr(name, attr string, value []byte) error {
+	xattrFS, ok := l.fileSystem.(XattrFS)
+	if !ok {
+		return unsupportedError("Lsetxattr", name)
+	}
+	start := time.Now()
+	err := xattrFS.Lsetxattr(name, attr, value)
+	l.log(MethodClassMetadata, "Lsetxattr", []string{name}, start, err,
+		slog.String("attr", attr),
+		slog.Int("size", len(value)),
+	)
+	return err
+}
+
+// Lstat implements os.Lstat.
+func (l *LoggingFS) Lstat(name string) (fs.FileInfo, error) {
+	start := time.Now()
+	info, err := l.fileSystem.Lstat(name)
+	l.log(MethodClassRead, "Lstat", []string{name}, start, err)
+	return info, err
+}
+
+// Mkdir implements os.Mkdir.
+func (l *LoggingFS) Mkdir(name string, perm fs.FileMode) error {
+	start := time.Now()
+	err := l.fileSystem.Mkdir(name, perm)
+	l.log(MethodClassWrite, "Mkdir", []string{name}, start, err,
+		slog.Any("perm", perm),
+	)
+	return err
+}
+
+// Open implements os.Open.
+func (l *LoggingFS) Open(name string) (fs.File, error) {
+	start := time.Now()
+	f, err := l.fileSystem.Open(name)
+	l.log(MethodClassRead, "Open", []string{name}, start, err)
+	return f, err
+}
+
+// OpenFile implements os.OpenFile. Calls that may modify name are logged as
+// MethodClassWrite, others as MethodClassRead.
+func (l *LoggingFS) OpenFile(name string, flag int, perm fs.FileMode) (*os.File, error) {
+	start := time.Now()
+	f, err := l.fileSystem.OpenFile(name, flag, perm)
+	class := MethodClassRead
+	if flag&(os.O_WRONLY|os.O_RDWR|os.O_APPEND|os.O_CREATE|os.O_TRUNC) != 0 {
+		class = MethodClassWrite
+	}
+	l.log(class, "OpenFile", []string{name}, start, err,
+		slog.String("flag", openFlagString(flag)),
+		slog.Any("perm", perm),
+	)
+	return f, err
+}
+
+// PathSeparator implements PathSeparator.
+func (l *LoggingFS) PathSeparator() rune {
+	return l.fileSystem.PathSeparator()
+}
+
+// RawPath implements RawPath.
+func (l *LoggingFS) RawPath(path string) (string, error) {
+	start := time.Now()
+	rawPath, err := l.fileSystem.RawPath(path)
+	l.log(MethodClassRead, "RawPath", []string{path}, start, err)
+	return rawPath, err
+}
+
+// ReadDir implements os.ReadDir.
+func (l *LoggingFS) ReadDir(dirname string) ([]fs.DirEntry, error) {
+	start := time.Now()
+	dirEntries, err := l.fileSystem.ReadDir(dirname)
+	l.log(MethodClassRead, "ReadDir", []string{dirname}, start, err,
+		slog.Int("entries", len(dirEntries)),
+	)
+	return dirEntries, err
+}
+
+// ReadFile implements os.ReadFile.
+func (l *LoggingFS) ReadFile(filename string) ([]byte, error) {
+	start := time.Now()
+	data, err := l.fileSystem.ReadFile(filename)
+	l.log(MethodClassRead, "ReadFile", []string{filename}, start, err,
+		slog.Int("bytes", len(data)),
+	)
+	return data, err
+}
+
+// Readlink implements os.Readlink.
+func (l *LoggingFS) Readlink(name string) (string, error) {
+	start := time.Now()
+	target, err := l.fileSystem.Readlink(name)
+	l.log(MethodClassRead, "Readlink", []string{name}, start, err)
+	return target, err
+}
+
+// Remove implements os.Remove.
+func (l *LoggingFS) Remove(name string) error {
+	start := time.Now()
+	err := l.fileSystem.Remove(name)
+	l.log(MethodClassWrite, "Remove", []string{name}, start, err)
+	return err
+}
+
+// RemoveAll implements os.RemoveAll.
+func (l *LoggingFS) RemoveAll(name string) error {
+	start := time.Now()
+	err := l.fileSystem.RemoveAll(name)
+	l.log(MethodClassWrite, "RemoveAll", []string{name}, start, err)
+	return err
+}
+
+// Removexattr implements XattrFS.Removexattr if l's underlying FS implements
+// XattrFS.
+func (l *LoggingFS) Removexattr(name, attr string) error {
+	xattrFS, ok := l.fileSystem.(XattrFS)
+	if !ok {
+		return unsupportedError("Removexattr", name)
+	}
+	start := time.Now()
+	err := xattrFS.Removexattr(name, attr)
+	l.log(MethodClassMetadata, "Removexattr", []string{name}, start, err,
+		slog.String("attr", attr),
+	)
+	return err
+}
+
+// Rename implements os.Rename.
+func (l *LoggingFS) Rename(oldpath, newpath string) error {
+	start := time.Now()
+	err := l.fileSystem.Rename(oldpath, newpath)
+	l.log(MethodClassWrite, "Rename", []string{oldpath, newpath}, start, err)
+	return err
+}
+
+// Setxattr implements XattrFS.Setxattr if l's underlying FS implements XattrFS.
+func (l *LoggingFS) Setxattr(name, attr string, value []byte) error {
+	xattrFS, ok := l.fileSystem.(XattrFS)
+	if !ok {
+		return unsupportedError("Setxattr", name)
+	}
+	start := time.Now()
+	err := xattrFS.Setxattr(name, attr, value)
+	l.log(MethodClassMetadata, "Setxattr", []string{name}, start, err,
+		slog.String("attr", attr),
+		slog.Int("size", len(value)),
+	)
+	return err
+}
+
+// Stat implements os.Stat.
+func (l *LoggingFS) Stat(name string) (fs.FileInfo, error) {
+	start := time.Now()
+	info, err := l.fileSystem.Stat(name)
+	l.log(MethodClassRead, "Stat", []string{name}, start, err)
+	return info, err
+}
+
+// Statfs implements Statfser.Statfs if l's underlying FS implements Statfser.
+func (l *LoggingFS) Statfs(name string) (*FSStat, error) {
+	statfser, ok := l.fileSystem.(Statfser)
+	if !ok {
+		return nil, unsupportedError("Statfs", name)
+	}
+	start := time.Now()
+	fsStat, err := statfser.Statfs(name)
+	l.log(MethodClassRead, "Statfs", []string{name}, start, err)
+	return fsStat, err
+}
+
+// Symlink implements os.Symlink.
+func (l *LoggingFS) Symlink(oldname, newname string) error {
+	start := time.Now()
+	err := l.fileSystem.Symlink(oldname, newname)
+	l.log(MethodClassWrite, "Symlink", []string{oldname, newname}, start, err)
+	return err
+}
+
+// Truncate implements os.Truncate.
+func (l *LoggingFS) Truncate(name string, size int64) error {
+	start := time.Now()
+	err := l.fileSystem.Truncate(name, size)
+	l.log(MethodClassWrite, "Truncate", []string{name}, start, err,
+		slog.Int64("size", size),
+	)
+	return err
+}
+
+// Watch implements Watcher.Watch if l's underlying FS implements Watcher.
+func (l *LoggingFS) Watch(name string, recursive bool) (*Watch, error) {
+	watcher, ok := l.fileSystem.(Watcher)
+	if !ok {
+		return nil, unsupportedError("Watch", name)
+	}
+	start := time.Now()
+	watch, err := watcher.Watch(name, recursive)
+	l.log(MethodClassRead, "Watch", []string{name}, start, err,
+		slog.Bool("recursive", recursive),
+	)
+	return watch, err
+}
+
+// WriteFile implements os.WriteFile.
+func (l *LoggingFS) WriteFile(filename string, data []byte, perm fs.FileMode) error {
+	start := time.Now()
+	err := l.fileSystem.WriteFile(filename, data, perm)
+	l.log(MethodClassWrite, "WriteFile", []string{filename}, start, err,
+		slog.Int("bytes", len(data)),
+		slog.Any("perm", perm),
+	)
+	return err
+}
+
+// log logs a call to method with paths that started at start and returned err.
+// A single path is logged with the key "path" and a pair of paths with the keys
+// "oldpath" and "newpath".
+func (l *LoggingFS) log(class MethodClass, method string, paths []string, start time.Time, err error, attrs ...slog.Attr) {
+	duration := time.Since(start)
+	level := l.levels[class]
+	ctx := context.Background()
+	if !l.logger.Enabled(ctx, level) {
+		return
+	}
+	if l.filter != nil && !anyPath(paths, l.filter) {
+		return
+	}
+	recordAttrs := make([]slog.Attr, 0, len(paths)+len(attrs)+3)
+	recordAttrs = append(recordAttrs, slog.String("method", method))
+	switch len(paths) {
+	case 1:
+		recordAttrs = append(recordAttrs, slog.String("path", paths[0]))
+	case 2:
+		recordAttrs = append(recordAttrs,
+			slog.String("oldpath", paths[0]),
+			slog.String("newpath", paths[1]),
+		)
+	}
+	recordAttrs = append(recordAttrs, attrs...)
+	recordAttrs = append(recordAttrs, slog.Duration("duration", duration))
+	if err != nil {
+		recordAttrs = append(recordAttrs, slog.Any("err", err))
+	}
+	l.logger.LogAttrs(ctx, level, method, recordAttrs...)
+}
+
+// anyPath returns whether filter returns true for any of paths.
+func anyPath(paths []string, filter func(string) bool) bool {
+	for _, path := range paths {
+		if filter(path) {
+			return true
+		}
+	}
+	return false
+}
+
+// openFlagString returns a human-readable representation of the os.OpenFile
+// flag.
+func openFlagString(flag int) string {
+	var names []string
+	switch flag & (os.O_RDONLY | os.O_WRONLY | os.O_RDWR) {
+	case os.O_RDONLY:
+		names = append(names, "O_RDONLY")
+	case os.O_WRONLY:
+		names = append(names, "O_WRONLY")
+	case os.O_RDWR:
+		names = append(names, "O_RDWR")
+	}
+	flag &^= os.O_RDONLY | os.O_WRONLY | os.O_RDWR
+	for _, f := range []struct {
+		flag int
+		name string
+	}{
+		{os.O_APPEND, "O_APPEND"},
+		{os.O_CREATE, "O_CREATE"},
+		{os.O_EXCL, "O_EXCL"},
+		{os.O_SYNC, "O_SYNC"},
+		{os.O_TRUNC, "O_TRUNC"},
+	} {
+		if flag&f.flag != 0 {
+			names = append(names, f.name)
+			flag &^= f.flag
+		}
+	}
+	if flag != 0 {
+		names = append(names, "0x"+strconv.FormatInt(int64(flag), 16))
+	}
+	return strings.Join(names, "|")
+}
@@ -0,0 +1,19 @@
+package vfs_test
+
+import "github.com/twpayne/go-vfs/v5"
+
+var _ vfs.FS = &vfs.LoggingFS{}
+
+var _ vfs.Watcher = &vfs.LoggingFS{}
+
+var _ vfs.Locker = &vfs.LoggingFS{}
+
+var _ vfs.XattrFS = &vfs.LoggingFS{}
+
+var _ vfs.Statfser = &vfs.LoggingFS{}
+
+var _ vfs.Lchmoder = &vfs.LoggingFS{}
+
+var _ vfs.Lchtimeser = &vfs.LoggingFS{}
+
+var _ vfs.DefaultTempDirer = &vfs.LoggingFS{}
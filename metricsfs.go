@@ -0,0 +1,543 @@
+package vfs
+
+import (
+	"expvar"
+	"io/fs"
+	"os"
+	"sync/atomic"
+	"time"
+)
+
+// defaultMetricsLatencyBuckets are the default upper bounds of the latency
+// histogram buckets recorded by MetricsFSs.
+var defaultMetricsLatencyBuckets = []time.Duration{
+	time.Microsecond,
+	10 * time.Microsecond,
+	100 * time.Microsecond,
+	time.Millisecond,
+	10 * time.Millisecond,
+	100 * time.Millisecond,
+	time.Second,
+}
+
+// metricsMethods are the methods for which a MetricsFS records metrics.
+var metricsMethods = []string{
+	"Chmod",
+	"Chown",
+	"Chtimes",
+	"Create",
+	"Getxattr",
+	"Glob",
+	"Lchmod",
+	"Lchown",
+	"Lchtimes",
+	"Lgetxattr",
+	"Link",
+	"Listxattr",
+	"Llistxattr",
+	"Lock",
+	"Lremovexattr",
+	"Lsetxattr",
+	"Lstat",
+	"Mkdir",
+	"Open",
+	"OpenFile",
+	"RawPath",
+	"ReadDir",
+	"ReadFile",
+	"Readlink",
+	"Remove",
+	"RemoveAll",
+	"Removexattr",
+	"Rename",
+	"Setxattr",
+	"Stat",
+	"Statfs",
+	"Symlink",
+	"Truncate",
+	"Watch",
+	"WriteFile",
+}
+
+// A MetricsFSOption sets an option on a MetricsFS.
+type MetricsFSOption func(*MetricsFS)
+
+// A MetricsFS operates on an existing FS and records the number of calls,
+// errors, bytes read or written, and latencies of each method called through
+// it. Reads and writes to *os.Files returned by Create and OpenFile are not
+// recorded.
+type MetricsFS struct {
+	fileSystem     FS
+	latencyBuckets []time.Duration
+	methods        map[string]*methodMetrics
+}
+
+// A MethodMetrics is a snapshot of the metrics for a single method.
+type MethodMetrics struct {
+	// Calls is the number of calls.
+	Calls uint64
+	// Errors is the number of calls that returned an error.
+	Errors uint64
+	// Bytes is the number of bytes read or written by ReadFile and WriteFile.
+	Bytes uint64
+	// LatencyCounts are the number of calls whose latency was less than or
+	// equal to the corresponding element of LatencyBuckets, and greater than
+	// the previous element. The final element is the number of calls whose
+	// latency was greater than the last element of LatencyBuckets.
+	LatencyCounts []uint64
+	// TotalLatency is the sum of the latencies of all calls.
+	TotalLatency time.Duration
+}
+
+// A MetricsSnapshot is a snapshot of the metrics recorded by a MetricsFS.
+type MetricsSnapshot struct {
+	// LatencyBuckets are the upper bounds of the latency histogram buckets.
+	LatencyBuckets []time.Duration
+	// Methods are the metrics for each method, keyed by method name. Methods
+	// that have not been called are omitted.
+	Methods map[string]MethodMetrics
+}
+
+// methodMetrics are the live metrics for a single method.
+type methodMetrics struct {
+	calls         atomic.Uint64
+	errors        atomic.Uint64
+	bytes         atomic.Uint64
+	latencyCounts []atomic.Uint64
+	totalLatency  atomic.Int64
+}
+
+// MetricsFSLatencyBuckets sets the upper bounds of the latency histogram
+// buckets recorded by a MetricsFS, which must be in increasing order.
+// Latencies greater than the last bound are counted in an additional overflow
+// bucket. The default is powers of ten from 1µs to 1s.
+func MetricsFSLatencyBuckets(latencyBuckets ...time.Duration) MetricsFSOption {
+	latencyBuckets = append([]time.Duration(nil), latencyBuckets...)
+	return func(m *MetricsFS) {
+		m.latencyBuckets = latencyBuckets
+	}
+}
+
+// NewMetricsFS returns a new *MetricsFS operating on fileSystem with the given
+// options set.
+func NewMetricsFS(fileSystem FS, options ...MetricsFSOption) *MetricsFS {
+	m := &MetricsFS{
+		fileSystem:     fileSystem,
+		latencyBuckets: defaultMetricsLatencyBuckets,
+		methods:        make(map[string]*methodMetrics, len(metricsMethods)),
+	}
+	for _, option := range options {
+		option(m)
+	}
+	for _, method := range metricsMethods {
+		m.methods[method] = &methodMetrics{
+			latencyCounts: make([]atomic.Uint64, len(m.latencyBuckets)+1),
+		}
+	}
+	return m
+}
+
+// Chmod implements os.Chmod.
+func (m *MetricsFS) Chmod(name string, mode fs.FileMode) error {
+	start := time.Now()
+	err := m.fileSystem.Chmod(name, mode)
+	m.record("Chmod", start, 0, err)
+	return err
+}
+
+// Chown implements os.Chown.
+func (m *MetricsFS) Chown(name string, uid, gid int) error {
+	start := time.Now()
+	err := m.fileSystem.Chown(name, uid, gid)
+	m.record("Chown", start, 0, err)
+	return err
+}
+
+// Chtimes implements os.Chtimes.
+func (m *MetricsFS) Chtimes(name string, atime, mtime time.Time) error {
+	start := time.Now()
+	err := m.fileSystem.Chtimes(name, atime, mtime)
+	m.record("Chtimes", start, 0, err)
+	return err
+}
+
+// Create implements os.Create.
+func (m *MetricsFS) Create(name string) (*os.File, error) {
+	start := time.Now()
+	f, err := m.fileSystem.Create(name)
+	m.record("Create", start, 0, err)
+	return f, err
+}
+
+// DefaultTempDir implements DefaultTempDirer.DefaultTempDir by returning
+// TempDir(m's underlying FS).
+func (m *MetricsFS) DefaultTempDir() string {
+	return TempDir(m.fileSystem)
+}
+
+// Getxattr implements XattrFS.Getxattr if m's underlying FS implements XattrFS.
+func (m *MetricsFS) Getxattr(name, attr string) ([]byte, error) {
+	xattrFS, ok := m.fileSystem.(XattrFS)
+	if !ok {
+		return nil, unsupportedError("Getxattr", name)
+	}
+	start := time.Now()
+	value, err := xattrFS.Getxattr(name, attr)
+	m.record("Getxattr", start, 0, err)
+	return value, err
+}
+
+// Glob implements filepath.Glob.
+func (m *MetricsFS) Glob(pattern string) ([]string, error) {
+	start := time.Now()
+	matches, err := m.fileSystem.Glob(pattern)
+	m.record("Glob", start, 0, err)
+	return matches, err
+}
+
+// Lchmod implements Lchmoder.Lchmod if m's underlying FS implements Lchmoder.
+func (m *MetricsFS) Lchmod(name string, mode fs.FileMode) error {
+	lchmoder, ok := m.fileSystem.(Lchmoder)
+	if !ok {
+		return unsupportedError("Lchmod", name)
+	}
+	start := time.Now()
+	err := lchmoder.Lchmod(name, mode)
+	m.record("Lchmod", start, 0, err)
+	return err
+}
+
+// Lchown implements os.Lchown.
+func (m *MetricsFS) Lchown(name string, uid, gid int) error {
+	start := time.Now()
+	err := m.fileSystem.Lchown(name, uid, gid)
+	m.record("Lchown", start, 0, err)
+	return err
+}
+
+// Lchtimes implements Lchtimeser.Lchtimes if m's underlying FS implements
+// Lchtimeser.
+func (m *MetricsFS) Lchtimes(name string, atime, mtime time.Time) error {
+	lchtimeser, ok := m.fileSystem.(Lchtimeser)
+	if !ok {
+		return unsupportedError("Lchtimes", name)
+	}
+	start := time.Now()
+	err := lchtimeser.Lchtimes(name, atime, mtime)
+	m.record("Lchtimes", start, 0, err)
+	return err
+}
+
+// Lgetxattr implements XattrFS.Lgetxattr if m's underlying FS implements
+// XattrFS.
+func (m *MetricsFS) Lgetxattr(name, attr string) ([]byte, error) {
+	xattrFS, ok := m.fileSystem.(XattrFS)
+	if !ok {
+		return nil, unsupportedError("Lgetxattr", name)
+	}
+	start := time.Now()
+	value, err := xattrFS.Lgetxattr(name, attr)
+	m.record("Lgetxattr", start, 0, err)
+	return value, err
+}
+
+// Link implements os.Link.
+func (m *MetricsFS) Link(oldname, newname string) error {
+	start := time.Now()
+	err := m.fileSystem.Link(oldname, newname)
+	m.record("Link", start, 0, err)
+	return err
+}
+
+// Listxattr implements XattrFS.Listxattr if m's underlying FS implements
+// XattrFS.
+func (m *MetricsFS) Listxattr(name string) ([]string, error) {
+	xattrFS, ok := m.fileSystem.(XattrFS)
+	if !ok {
+		return nil, unsupportedError("Listxattr", name)
+	}
+	start := time.Now()
+	attrs, err := xattrFS.Listxattr(name)
+	m.record("Listxattr", start, 0, err)
+	return attrs, err
+}
+
+// Llistxattr implements XattrFS.Llistxattr if m's underlying FS implements
+// XattrFS.
+func (m *MetricsFS) Llistxattr(name string) ([]string, error) {
+	xattrFS, ok := m.fileSystem.(XattrFS)
+	if !ok {
+		return nil, unsupportedError("Llistxattr", name)
+	}
+	start := time.Now()
+	attrs, err := xattrFS.Llistxattr(name)
+	m.record("Llistxattr", start, 0, err)
+	return attrs, err
+}
+
+// Lock implements Locker.Lock if m's underlying FS implements Locker.
+func (m *MetricsFS) Lock(name string, mode LockMode, wait bool) (*Lock, error) {
+	locker, ok := m.fileSystem.(Locker)
+	if !ok {
+		return nil, unsupportedError("Lock", name)
+	}
+	start := time.Now()
+	lock, err := locker.Lock(name, mode, wait)
+	m.record("Lock", start, 0, err)
+	return lock, err
+}
+
+// Lremovexattr implements XattrFS.Lremovexattr if m's underlying FS implements
+// XattrFS.
+func (m *MetricsFS) Lremovexattr(name, attr string) error {
+	xattrFS, ok := m.fileSystem.(XattrFS)
+	if !ok {
+		return unsupportedError("Lremovexattr", name)
+	}
+	start := time.Now()
+	err := xattrFS.Lremovexattr(name, attr)
+	m.record("Lremovexattr", start, 0, err)
+	return err
+}
+
+// Lsetxattr implements XattrFS.Lsetxattr if m's underlying FS implements
+// XattrFS.
+func (m *MetricsFS) Lsetxattr(name, attr string, value []byte) error {
+	xattrFS, ok := m.fileSystem.(XattrFS)
+	if !ok {
+		return unsupportedError("Lsetxattr", name)
+	}
+	start := time.Now()
+	err := xattrFS.Lsetxattr(name, attr, value)
+	m.record("Lsetxattr", start, 0, err)
+	return err
+}
+
+// Lstat implements os.Lstat.
+func (m *MetricsFS) Lstat(name string) (fs.FileInfo, error) {
+	start := time.Now()
+	info, err := m.fileSystem.Lstat(name)
+	m.record("Lstat", start, 0, err)
+	return info, err
+}
+
+// Mkdir implements os.Mkdir.
+func (m *MetricsFS) Mkdir(name string, perm fs.FileMode) error {
+	start := time.Now()
+	err := m.fileSystem.Mkdir(name, perm)
+	m.record("Mkdir", start, 0, err)
+	return err
+}
+
+// Open implements os.Open.
+func (m *MetricsFS) Open(name string) (fs.File, error) {
+	start := time.Now()
+	f, err := m.fileSystem.Open(name)
+	m.record("Open", start, 0, err)
+	return f, err
+}
+
+// OpenFile implements os.OpenFile.
+func (m *MetricsFS) OpenFile(name string, flag int, perm fs.FileMode) (*os.File, error) {
+	start := time.Now()
+	f, err := m.fileSystem.OpenFile(name, flag, perm)
+	m.record("OpenFile", start, 0, err)
+	return f, err
+}
+
+// PathSeparator implements PathSeparator.
+func (m *MetricsFS) PathSeparator() rune {
+	return m.fileSystem.PathSeparator()
+}
+
+// RawPath implements RawPath.
+func (m *MetricsFS) RawPath(path string) (string, error) {
+	start := time.Now()
+	rawPath, err := m.fileSystem.RawPath(path)
+	m.record("RawPath", start, 0, err)
+	return rawPath, err
+}
+
+// ReadDir implements os.ReadDir.
+func (m *MetricsFS) ReadDir(dirname string) ([]fs.DirEntry, error) {
+	start := time.Now()
+	dirEntries, err := m.fileSystem.ReadDir(dirname)
+	m.record("ReadDir", start, 0, err)
+	return dirEntries, err
+}
+
+// ReadFile implements os.ReadFile.
+func (m *MetricsFS) ReadFile(filename string) ([]byte, error) {
+	start := time.Now()
+	data, err := m.fileSystem.ReadFile(filename)
+	m.record("ReadFile", start, len(data), err)
+	return data, err
+}
+
+// Readlink implements os.Readlink.
+func (m *MetricsFS) Readlink(name string) (string, error) {
+	start := time.Now()
+	target, err := m.fileSystem.Readlink(name)
+	m.record("Readlink", start, 0, err)
+	return target, err
+}
+
+// Remove implements os.Remove.
+func (m *MetricsFS) Remove(name string) error {
+	start := time.Now()
+	err := m.fileSystem.Remove(name)
+	m.record("Remove", start, 0, err)
+	return err
+}
+
+// RemoveAll implements os.RemoveAll.
+func (m *MetricsFS) RemoveAll(name string) error {
+	start := time.Now()
+	err := m.fileSystem.RemoveAll(name)
+	m.record("RemoveAll", start, 0, err)
+	return err
+}
+
+// Removexattr implements XattrFS.Removexattr if m's underlying FS implements
+// XattrFS.
+func (m *MetricsFS) Removexattr(name, attr string) error {
+	xattrFS, ok := m.fileSystem.(XattrFS)
+	if !ok {
+		return unsupportedError("Removexattr", name)
+	}
+	start := time.Now()
+	err := xattrFS.Removexattr(name, attr)
+	m.record("Removexattr", start, 0, err)
+	return err
+}
+
+// Rename implements os.Rename.
+func (m *MetricsFS) Rename(oldpath, newpath string) error {
+	start := time.Now()
+	err := m.fileSystem.Rename(oldpath, newpath)
+	m.record("Rename", start, 0, err)
+	return err
+}
+
+// Setxattr implements XattrFS.Setxattr if m's underlying FS implements XattrFS.
+func (m *MetricsFS) Setxattr(name, attr string, value []byte) error {
+	xattrFS, ok := m.fileSystem.(XattrFS)
+	if !ok {
+		return unsupportedError("Setxattr", name)
+	}
+	start := time.Now()
+	err := xattrFS.Setxattr(name, attr, value)
+	m.record("Setxattr", start, 0, err)
+	return err
+}
+
+// Snapshot returns a snapshot of m's metrics.
+func (m *MetricsFS) Snapshot() *MetricsSnapshot {
+	snapshot := &MetricsSnapshot{
+		LatencyBuckets: append([]time.Duration(nil), m.latencyBuckets...),
+		Methods:        make(map[string]MethodMetrics),
+	}
+	for method, metrics := range m.methods {
+		calls := metrics.calls.Load()
+		if calls == 0 {
+			continue
+		}
+		latencyCounts := make([]uint64, len(metrics.latencyCounts))
+		for i := range metrics.latencyCounts {
+			latencyCounts[i] = metrics.latencyCounts[i].Load()
+		}
+		snapshot.Methods[method] = MethodMetrics{
+			Calls:         calls,
+			Errors:        metrics.errors.Load(),
+			Bytes:         metrics.bytes.Load(),
+			LatencyCounts: latencyCounts,
+			TotalLatency:  time.Duration(metrics.totalLatency.Load()),
+		}
+	}
+	return snapshot
+}
+
+// Stat implements os.Stat.
+func (m *MetricsFS) Stat(name string) (fs.FileInfo, error) {
+	start := time.Now()
+	info, err := m.fileSystem.Stat(name)
+	m.record("Stat", start, 0, err)
+	return info, err
+}
+
+// Statfs implements Statfser.Statfs if m's underlying FS implements Statfser.
+func (m *MetricsFS) Statfs(name string) (*FSStat, error) {
+	statfser, ok := m.fileSystem.(Statfser)
+	if !ok {
+		return nil, unsupportedError("Statfs", name)
+	}
+	start := time.Now()
+	fsStat, err := statfser.Statfs(name)
+	m.record("Statfs", start, 0, err)
+	return fsStat, err
+}
+
+// Symlink implements os.Symlink.
+func (m *MetricsFS) Symlink(oldname, newname string) error {
+	start := time.Now()
+	err := m.fileSystem.Symlink(oldname, newname)
+	m.record("Symlink", start, 0, err)
+	return err
+}
+
+// Truncate implements os.Truncate.
+func (m *MetricsFS) Truncate(name string, size int64) error {
+	start := time.Now()
+	err := m.fileSystem.Truncate(name, size)
+	m.record("Truncate", start, 0, err)
+	return err
+}
+
+// Var returns an expvar.Var whose value is a snapshot of m's metrics, for
+// publishing with expvar.Publish.
+func (m *MetricsFS) Var() expvar.Var {
+	return expvar.Func(func() any {
+		return m.Snapshot()
+	})
+}
+
+// Watch implements Watcher.Watch if m's underlying FS implements Watcher.
+func (m *MetricsFS) Watch(name string, recursive bool) (*Watch, error) {
+	watcher, ok := m.fileSystem.(Watcher)
+	if !ok {
+		return nil, unsupportedError("Watch", name)
+	}
+	start := time.Now()
+	watch, err := watcher.Watch(name, recursive)
+	m.record("Watch", start, 0, err)
+	return watch, err
+}
+
+// WriteFile implements os.WriteFile.
+func (m *MetricsFS) WriteFile(filename string, data []byte, perm fs.FileMode) error {
+	start := time.Now()
+	err := m.fileSystem.WriteFile(filename, data, perm)
+	m.record("WriteFile", start, len(data), err)
+	return err
+}
+
+// record records a call to method that started at start, read or wrote bytes
+// bytes, and returned err.
+func (m *MetricsFS) record(method string, start time.Time, bytes int, err error) {
+	latency := time.Since(start)
+	metrics := m.methods[method]
+	metrics.calls.Add(1)
+	if err != nil {
+		metrics.errors.Add(1)
+	}
+	metrics.bytes.Add(uint64(bytes)) //nolint:gosec
+	bucket := len(m.latencyBuckets)
+	for i, bound := range m.latencyBuckets {
+		if latency <= bound {
+			bucket = i
+			break
+		}
+	}
+	metrics.latencyCounts[bucket].Add(1)
+	metrics.totalLatency.Add(int64(latency))
+}
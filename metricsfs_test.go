@@ -0,0 +1,19 @@
+package vfs_test
+
+import "github.com/twpayne/go-vfs/v5"
+
+var _ vfs.FS = &vfs.MetricsFS{}
+
+var _ vfs.Watcher = &vfs.MetricsFS{}
+
+var _ vfs.Locker = &vfs.MetricsFS{}
+
+var _ vfs.XattrFS = &vfs.MetricsFS{}
+
+var _ vfs.Statfser = &vfs.MetricsFS{}
+
+var _ vfs.Lchmoder = &vfs.MetricsFS{}
+
+var _ vfs.Lchtimeser = &vfs.MetricsFS{}
+
+var _ vfs.DefaultTempDirer = &vfs.MetricsFS{}
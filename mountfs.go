@@ -0,0 +1,628 @@
+package vfs
+
+import (
+	"io/fs"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// A MountFS combines several FSs, each mounted at a path prefix. Each name is
+// routed to the FS mounted at the longest prefix of name, with the prefix
+// replaced by "/". All names must be absolute paths. Mount points, and the
+// directories above them, appear in ReadDir, Lstat, and Stat even if they do
+// not exist in the FS in which they are mounted. Renaming and linking between
+// FSs fails with EXDEV, and mount points cannot be removed or renamed. Symlink
+// targets are not translated unless they are absolute paths in the same FS as
+// the symlink, in which case Symlink removes the mount prefix and Readlink adds
+// it back. Absolute targets are always interpreted as paths in the FS that
+// contains the symlink.
+type MountFS struct {
+	mu     sync.RWMutex
+	mounts map[string]FS
+}
+
+// A mountDirInfo is the fs.FileInfo of a synthesized directory above a mount
+// point.
+type mountDirInfo struct {
+	name string
+}
+
+// A mountPointInfo is the fs.FileInfo of a mount point.
+type mountPointInfo struct {
+	fs.FileInfo
+	name string
+}
+
+// NewMountFS returns a new *MountFS with nothing mounted.
+func NewMountFS() *MountFS {
+	return &MountFS{
+		mounts: make(map[string]FS),
+	}
+}
+
+// Chmod implements os.Chmod.
+func (m *MountFS) Chmod(name string, mode fs.FileMode) error {
+	fileSystem, mountName, err := m.resolve("Chmod", name)
+	if err != nil {
+		return err
+	}
+	return fileSystem.Chmod(mountName, mode)
+}
+
+// Chown implements os.Chown.
+func (m *MountFS) Chown(name string, uid, gid int) error {
+	fileSystem, mountName, err := m.resolve("Chown", name)
+	if err != nil {
+		return err
+	}
+	return fileSystem.Chown(mountName, uid, gid)
+}
+
+// Chtimes implements os.Chtimes.
+func (m *MountFS) Chtimes(name string, atime, mtime time.Time) error {
+	fileSystem, mountName, err := m.resolve("Chtimes", name)
+	if err != nil {
+		return err
+	}
+	return fileSystem.Chtimes(mountName, atime, mtime)
+}
+
+// Create implements os.Create.
+func (m *MountFS) Create(name string) (*os.File, error) {
+	fileSystem, mountName, err := m.resolve("Create", name)
+	if err != nil {
+		return nil, err
+	}
+	return fileSystem.Create(mountName)
+}
+
+// DefaultTempDir implements DefaultTempDirer.DefaultTempDir. If an FS is
+// mounted at "/" then it returns TempDir of that FS, otherwise it returns
+// "/tmp", which must be within a mounted FS for temporary files to be created.
+func (m *MountFS) DefaultTempDir() string {
+	m.mu.RLock()
+	fileSystem, ok := m.mounts["/"]
+	m.mu.RUnlock()
+	if ok {
+		return TempDir(fileSystem)
+	}
+	return "/tmp"
+}
+
+// Getxattr implements XattrFS.Getxattr if the FS in which name is mounted
+// implements XattrFS.
+func (m *MountFS) Getxattr(name, attr string) ([]byte, error) {
+	fileSystem, mountName, err := m.resolve("Getxattr", name)
+	if err != nil {
+		return nil, err
+	}
+	xattrFS, ok := fileSystem.(XattrFS)
+	if !ok {
+		return nil, unsupportedError("Getxattr", name)
+	}
+	return xattrFS.Getxattr(mountName, attr)
+}
+
+// Glob implements filepath.Glob. Matches span mounts.
+func (m *MountFS) Glob(pattern string) ([]string, error) {
+	return globFS(m, pattern)
+}
+
+// Lchown implements os.Lchown.
+func (m *MountFS) Lchown(name string, uid, gid int) error {
+	fileSystem, mountName, err := m.resolve("Lchown", name)
+	if err != nil {
+		return err
+	}
+	return fileSystem.Lchown(mountName, uid, gid)
+}
+
+// Lgetxattr implements XattrFS.Lgetxattr if the FS in which name is mounted
+// implements XattrFS.
+func (m *MountFS) Lgetxattr(name, attr string) ([]byte, error) {
+	fileSystem, mountName, err := m.resolve("Lgetxattr", name)
+	if err != nil {
+		return nil, err
+	}
+	xattrFS, ok := fileSystem.(XattrFS)
+	if !ok {
+		return nil, unsupportedError("Lgetxattr", name)
+	}
+	return xattrFS.Lgetxattr(mountName, attr)
+}
+
+// Link implements os.Link.
+func (m *MountFS) Link(oldname, newname string) error {
+	fileSystem, mountOldname, mountNewname, err := m.resolve2("link", oldname, newname)
+	if err != nil {
+		return err
+	}
+	return fileSystem.Link(mountOldname, mountNewname)
+}
+
+// Listxattr implements XattrFS.Listxattr if the FS in which name is mounted
+// implements XattrFS.
+func (m *MountFS) Listxattr(name string) ([]string, error) {
+	fileSystem, mountName, err := m.resolve("Listxattr", name)
+	if err != nil {
+		return nil, err
+	}
+	xattrFS, ok := fileSystem.(XattrFS)
+	if !ok {
+		return nil, unsupportedError("Listxattr", name)
+	}
+	return xattrFS.Listxattr(mountName)
+}
+
+// Llistxattr implements XattrFS.Llistxattr if the FS in which name is mounted
+// implements XattrFS.
+func (m *MountFS) Llistxattr(name string) ([]string, error) {
+	fileSystem, mountName, err := m.resolve("Llistxattr", name)
+	if err != nil {
+		return nil, err
+	}
+	xattrFS, ok := fileSystem.(XattrFS)
+	if !ok {
+		return nil, unsupportedError("Llistxattr", name)
+	}
+	return xattrFS.Llistxattr(mountName)
+}
+
+// Lremovexattr implements XattrFS.Lremovexattr if the FS in which name is
+// mounted implements XattrFS.
+func (m *MountFS) Lremovexattr(name, attr string) error {
+	fileSystem, mountName, err := m.resolve("Lremovexattr", name)
+	if err != nil {
+		return err
+	}
+	xattrFS, ok := fileSystem.(XattrFS)
+	if !ok {
+		return unsupportedError("Lremovexattr", name)
+	}
+	return xattrFS.Lremovexattr(mountName, attr)
+}
+
+// Lsetxattr implements XattrFS.Lsetxattr if the FS in which name is mounted
+// implements XattrFS.
+func (m *MountFS) Lsetxattr(name, attr string, value []byte) error {
+	fileSystem, mountName, err := m.resolve("Lsetxattr", name)
+	if err != nil {
+		return err
+	}
+	xattrFS, ok := fileSystem.(XattrFS)
+	if !ok {
+		return unsupportedError("Lsetxattr", name)
+	}
+	return xattrFS.Lsetxattr(mountName, attr, value)
+}
+
+// Lstat implements os.Lstat.
+func (m *MountFS) Lstat(name string) (fs.FileInfo, error) {
+	return m.stat("Lstat", name, FS.Lstat)
+}
+
+// Mkdir implements os.Mkdir.
+func (m *MountFS) Mkdir(name string, perm fs.FileMode) error {
+	fileSystem, mountName, err := m.resolve("Mkdir", name)
+	if err != nil {
+		return err
+	}
+	return fileSystem.Mkdir(mountName, perm)
+}
+
+// Mount mounts fileSystem at prefix, which must be an absolute path. It
+// returns an error wrapping fs.ErrExist if an FS is already mounted at prefix.
+func (m *MountFS) Mount(prefix string, fileSystem FS) error {
+	prefix, err := m.clean("Mount", prefix)
+	if err != nil {
+		return err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.mounts[prefix]; ok {
+		return &os.PathError{
+			Op:   "Mount",
+			Path: prefix,
+			Err:  fs.ErrExist,
+		}
+	}
+	m.mounts[prefix] = fileSystem
+	return nil
+}
+
+// Open implements os.Open.
+func (m *MountFS) Open(name string) (fs.File, error) {
+	fileSystem, mountName, err := m.resolve("Open", name)
+	if err != nil {
+		return nil, err
+	}
+	return fileSystem.Open(mountName)
+}
+
+// OpenFile implements os.OpenFile.
+func (m *MountFS) OpenFile(name string, flag int, perm fs.FileMode) (*os.File, error) {
+	fileSystem, mountName, err := m.resolve("OpenFile", name)
+	if err != nil {
+		return nil, err
+	}
+	return fileSystem.OpenFile(mountName, flag, perm)
+}
+
+// PathSeparator implements PathSeparator.
+func (m *MountFS) PathSeparator() rune {
+	return '/'
+}
+
+// RawPath implements RawPath.
+func (m *MountFS) RawPath(name string) (string, error) {
+	fileSystem, mountName, err := m.resolve("RawPath", name)
+	if err != nil {
+		return "", err
+	}
+	return fileSystem.RawPath(mountName)
+}
+
+// ReadDir implements os.ReadDir. Mount points below dirname replace any
+// entries with the same names.
+func (m *MountFS) ReadDir(dirname string) ([]fs.DirEntry, error) {
+	fileSystem, mountName, err := m.resolve("ReadDir", dirname)
+	var dirEntries []fs.DirEntry
+	if err == nil {
+		dirEntries, err = fileSystem.ReadDir(mountName)
+	}
+	children := m.mountChildren(dirname)
+	switch {
+	case err != nil && len(children) == 0:
+		return nil, err
+	case len(children) == 0:
+		return dirEntries, nil
+	}
+
+	result := make([]fs.DirEntry, 0, len(dirEntries)+len(children))
+	for _, dirEntry := range dirEntries {
+		if _, ok := children[dirEntry.Name()]; !ok {
+			result = append(result, dirEntry)
+		}
+	}
+	for childName := range children {
+		info, err := m.Lstat(path.Join(path.Clean(relativizePath(dirname)), childName))
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, fs.FileInfoToDirEntry(info))
+	}
+	sort.Sort(dirEntriesByName(result))
+	return result, nil
+}
+
+// ReadFile implements os.ReadFile.
+func (m *MountFS) ReadFile(name string) ([]byte, error) {
+	fileSystem, mountName, err := m.resolve("ReadFile", name)
+	if err != nil {
+		return nil, err
+	}
+	return fileSystem.ReadFile(mountName)
+}
+
+// Readlink implements os.Readlink. Absolute targets are returned with the
+// prefix at which the symlink's FS is mounted.
+func (m *MountFS) Readlink(name string) (string, error) {
+	fileSystem, prefix, mountName, err := m.resolveMount("Readlink", name)
+	if err != nil {
+		return "", err
+	}
+	target, err := fileSystem.Readlink(mountName)
+	if err != nil {
+		return "", err
+	}
+	if prefix != "/" && path.IsAbs(relativizePath(target)) {
+		target = path.Join(prefix, relativizePath(target))
+	}
+	return target, nil
+}
+
+// Remove implements os.Remove. It returns EBUSY if name is a mount point or a
+// directory above one.
+func (m *MountFS) Remove(name string) error {
+	if err := m.checkNotMountPoint("remove", name); err != nil {
+		return err
+	}
+	fileSystem, mountName, err := m.resolve("Remove", name)
+	if err != nil {
+		return err
+	}
+	return fileSystem.Remove(mountName)
+}
+
+// RemoveAll implements os.RemoveAll. It returns EBUSY if name is a mount
+// point or a directory above one.
+func (m *MountFS) RemoveAll(name string) error {
+	if err := m.checkNotMountPoint("RemoveAll", name); err != nil {
+		return err
+	}
+	fileSystem, mountName, err := m.resolve("RemoveAll", name)
+	if err != nil {
+		return err
+	}
+	return fileSystem.RemoveAll(mountName)
+}
+
+// Removexattr implements XattrFS.Removexattr if the FS in which name is mounted
+// implements XattrFS.
+func (m *MountFS) Removexattr(name, attr string) error {
+	fileSystem, mountName, err := m.resolve("Removexattr", name)
+	if err != nil {
+		return err
+	}
+	xattrFS, ok := fileSystem.(XattrFS)
+	if !ok {
+		return unsupportedError("Removexattr", name)
+	}
+	return xattrFS.Removexattr(mountName, attr)
+}
+
+// Rename implements os.Rename.
+func (m *MountFS) Rename(oldpath, newpath string) error {
+	if err := m.checkNotMountPoint("rename", oldpath); err != nil {
+		return err
+	}
+	if err := m.checkNotMountPoint("rename", newpath); err != nil {
+		return err
+	}
+	fileSystem, mountOldpath, mountNewpath, err := m.resolve2("rename", oldpath, newpath)
+	if err != nil {
+		return err
+	}
+	return fileSystem.Rename(mountOldpath, mountNewpath)
+}
+
+// Setxattr implements XattrFS.Setxattr if the FS in which name is mounted
+// implements XattrFS.
+func (m *MountFS) Setxattr(name, attr string, value []byte) error {
+	fileSystem, mountName, err := m.resolve("Setxattr", name)
+	if err != nil {
+		return err
+	}
+	xattrFS, ok := fileSystem.(XattrFS)
+	if !ok {
+		return unsupportedError("Setxattr", name)
+	}
+	return xattrFS.Setxattr(mountName, attr, value)
+}
+
+// Stat implements os.Stat.
+func (m *MountFS) Stat(name string) (fs.FileInfo, error) {
+	return m.stat("Stat", name, FS.Stat)
+}
+
+// Statfs implements Statfser.Statfs if the FS in which name is mounted
+// implements Statfser.
+func (m *MountFS) Statfs(name string) (*FSStat, error) {
+	fileSystem, mountName, err := m.resolve("Statfs", name)
+	if err != nil {
+		return nil, err
+	}
+	statfser, ok := fileSystem.(Statfser)
+	if !ok {
+		return nil, unsupportedError("Statfs", name)
+	}
+	return statfser.Statfs(mountName)
+}
+
+// Symlink implements os.Symlink.
+func (m *MountFS) Symlink(oldname, newname string) error {
+	fileSystem, prefix, mountNewname, err := m.resolveMount("Symlink", newname)
+	if err != nil {
+		return err
+	}
+	if path.IsAbs(relativizePath(oldname)) {
+		_, oldPrefix, mountOldname, err := m.resolveMount("Symlink", oldname)
+		if err == nil && oldPrefix == prefix {
+			oldname = mountOldname
+		}
+	}
+	return fileSystem.Symlink(oldname, mountNewname)
+}
+
+// Truncate implements os.Truncate.
+func (m *MountFS) Truncate(name string, size int64) error {
+	fileSystem, mountName, err := m.resolve("Truncate", name)
+	if err != nil {
+		return err
+	}
+	return fileSystem.Truncate(mountName, size)
+}
+
+// Unmount unmounts the FS mounted at prefix. It returns an error wrapping
+// fs.ErrNotExist if no FS is mounted at prefix.
+func (m *MountFS) Unmount(prefix string) error {
+	prefix, err := m.clean("Unmount", prefix)
+	if err != nil {
+		return err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.mounts[prefix]; !ok {
+		return &os.PathError{
+			Op:   "Unmount",
+			Path: prefix,
+			Err:  fs.ErrNotExist,
+		}
+	}
+	delete(m.mounts, prefix)
+	return nil
+}
+
+// WriteFile implements os.WriteFile.
+func (m *MountFS) WriteFile(filename string, data []byte, perm fs.FileMode) error {
+	fileSystem, mountName, err := m.resolve("WriteFile", filename)
+	if err != nil {
+		return err
+	}
+	return fileSystem.WriteFile(mountName, data, perm)
+}
+
+// checkNotMountPoint returns EBUSY if name is a mount point or a directory
+// above one.
+func (m *MountFS) checkNotMountPoint(op, name string) error {
+	name, err := m.clean(op, name)
+	if err != nil {
+		return err
+	}
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	for prefix := range m.mounts {
+		if isMountPrefix(name, prefix) {
+			return &os.PathError{
+				Op:   op,
+				Path: name,
+				Err:  syscall.EBUSY,
+			}
+		}
+	}
+	return nil
+}
+
+// clean returns the cleaned /-separated form of name, which must be absolute.
+func (m *MountFS) clean(op, name string) (string, error) {
+	name = relativizePath(name)
+	if !path.IsAbs(name) {
+		return "", &os.PathError{
+			Op:   op,
+			Path: name,
+			Err:  syscall.EPERM,
+		}
+	}
+	return path.Clean(name), nil
+}
+
+// mountChildren returns the names of the entries in dirname that are mount
+// points or directories above mount points.
+func (m *MountFS) mountChildren(dirname string) map[string]struct{} {
+	dirname, err := m.clean("ReadDir", dirname)
+	if err != nil {
+		return nil
+	}
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	var children map[string]struct{}
+	for prefix := range m.mounts {
+		if prefix == dirname || !isMountPrefix(dirname, prefix) {
+			continue
+		}
+		childName, _, _ := strings.Cut(strings.TrimPrefix(prefix[len(dirname):], "/"), "/")
+		if children == nil {
+			children = make(map[string]struct{})
+		}
+		children[childName] = struct{}{}
+	}
+	return children
+}
+
+// resolve returns the FS mounted at the longest prefix of name, and name
+// relative to that FS.
+func (m *MountFS) resolve(op, name string) (FS, string, error) {
+	fileSystem, _, mountName, err := m.resolveMount(op, name)
+	return fileSystem, mountName, err
+}
+
+// resolve2 resolves oldname and newname, which must be in the same FS.
+func (m *MountFS) resolve2(op, oldname, newname string) (FS, string, string, error) {
+	fileSystem, prefix, mountOldname, err := m.resolveMount(op, oldname)
+	if err != nil {
+		return nil, "", "", err
+	}
+	_, newPrefix, mountNewname, err := m.resolveMount(op, newname)
+	if err != nil {
+		return nil, "", "", err
+	}
+	if newPrefix != prefix {
+		return nil, "", "", &os.LinkError{
+			Op:  op,
+			Old: oldname,
+			New: newname,
+			Err: syscall.EXDEV,
+		}
+	}
+	return fileSystem, mountOldname, mountNewname, nil
+}
+
+// resolveMount returns the FS mounted at the longest prefix of name, the
+// prefix, and name relative to the FS.
+func (m *MountFS) resolveMount(op, name string) (FS, string, string, error) {
+	name, err := m.clean(op, name)
+	if err != nil {
+		return nil, "", "", err
+	}
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	longestPrefix := ""
+	for prefix := range m.mounts {
+		if isMountPrefix(prefix, name) && len(prefix) > len(longestPrefix) {
+			longestPrefix = prefix
+		}
+	}
+	switch longestPrefix {
+	case "":
+		return nil, "", "", &os.PathError{
+			Op:   op,
+			Path: name,
+			Err:  fs.ErrNotExist,
+		}
+	case "/":
+		return m.mounts[longestPrefix], longestPrefix, name, nil
+	default:
+		mountName := "/" + strings.TrimPrefix(name[len(longestPrefix):], "/")
+		return m.mounts[longestPrefix], longestPrefix, mountName, nil
+	}
+}
+
+// stat implements Lstat and Stat.
+func (m *MountFS) stat(op, name string, statFunc func(FS, string) (fs.FileInfo, error)) (fs.FileInfo, error) {
+	cleanName, err := m.clean(op, name)
+	if err != nil {
+		return nil, err
+	}
+	fileSystem, mountName, err := m.resolve(op, cleanName)
+	if err == nil {
+		var info fs.FileInfo
+		info, err = statFunc(fileSystem, mountName)
+		switch {
+		case err != nil:
+		case mountName == "/" && cleanName != "/":
+			// Report mount points with their names in m, rather than the
+			// names of the roots of the mounted FSs.
+			return &mountPointInfo{
+				FileInfo: info,
+				name:     path.Base(cleanName),
+			}, nil
+		default:
+			return info, nil
+		}
+	}
+	if len(m.mountChildren(cleanName)) != 0 {
+		return &mountDirInfo{
+			name: path.Base(cleanName),
+		}, nil
+	}
+	return nil, err
+}
+
+// isMountPrefix returns whether prefix is name or a directory above it.
+func isMountPrefix(prefix, name string) bool {
+	return prefix == "/" || name == prefix || strings.HasPrefix(name, prefix+"/")
+}
+
+func (i *mountDirInfo) IsDir() bool        { return true }
+func (i *mountDirInfo) ModTime() time.Time { return time.Time{} }
+func (i *mountDirInfo) Mode() fs.FileMode  { return fs.ModeDir | 0o555 }
+func (i *mountDirInfo) Name() string       { return i.name }
+func (i *mountDirInfo) Size() int64        { return 0 }
+func (i *mountDirInfo) Sys() any           { return nil }
+
+func (i *mountPointInfo) Name() string { return i.name }
@@ -0,0 +1,11 @@
+package vfs_test
+
+import "github.com/twpayne/go-vfs/v5"
+
+var _ vfs.FS = &vfs.MountFS{}
+
+var _ vfs.XattrFS = &vfs.MountFS{}
+
+var _ vfs.Statfser = &vfs.MountFS{}
+
+var _ vfs.DefaultTempDirer = &vfs.MountFS{}
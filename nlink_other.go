@@ -0,0 +1,11 @@
+//go:build !unix
+
+package vfs
+
+import "io/fs"
+
+// linkCount returns the number of hard links to the file described by info,
+// or 1 if it is not known. On this platform it is never known.
+func linkCount(info fs.FileInfo) uint64 {
+	return 1
+}
@@ -0,0 +1,17 @@
+//go:build unix
+
+package vfs
+
+import (
+	"io/fs"
+	"syscall"
+)
+
+// linkCount returns the number of hard links to the file described by info,
+// or 1 if it is not known.
+func linkCount(info fs.FileInfo) uint64 {
+	if stat, ok := info.Sys().(*syscall.Stat_t); ok {
+		return uint64(stat.Nlink) //nolint:unconvert
+	}
+	return 1
+}
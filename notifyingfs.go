@@ -0,0 +1,288 @@
+package vfs
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"sync"
+	"time"
+)
+
+// A NotifyingFS operates on an existing FS and synthesizes Events for all
+// modifications made through it. Events are delivered in the order in which
+// the modifications were made, which makes it suitable for testing code that
+// uses a Watcher deterministically. Writes made to *os.Files returned by Create
+// and OpenFile, and modifications made to the underlying FS directly, are not
+// reported.
+type NotifyingFS struct {
+	fileSystem FS
+	mu         sync.Mutex
+	watches    map[*notifyingWatch]struct{}
+}
+
+// A notifyingWatch is an active watch on a NotifyingFS.
+type notifyingWatch struct {
+	name      string
+	recursive bool
+	queue     *eventQueue
+}
+
+// NewNotifyingFS returns a new *NotifyingFS operating on fileSystem.
+func NewNotifyingFS(fileSystem FS) *NotifyingFS {
+	return &NotifyingFS{
+		fileSystem: fileSystem,
+		watches:    make(map[*notifyingWatch]struct{}),
+	}
+}
+
+// Chmod implements os.Chmod.
+func (n *NotifyingFS) Chmod(name string, mode fs.FileMode) error {
+	if err := n.fileSystem.Chmod(name, mode); err != nil {
+		return err
+	}
+	n.notify(name, OpChmod)
+	return nil
+}
+
+// Chown implements os.Chown.
+func (n *NotifyingFS) Chown(name string, uid, gid int) error {
+	if err := n.fileSystem.Chown(name, uid, gid); err != nil {
+		return err
+	}
+	n.notify(name, OpChmod)
+	return nil
+}
+
+// Chtimes implements os.Chtimes.
+func (n *NotifyingFS) Chtimes(name string, atime, mtime time.Time) error {
+	if err := n.fileSystem.Chtimes(name, atime, mtime); err != nil {
+		return err
+	}
+	n.notify(name, OpChmod)
+	return nil
+}
+
+// Create implements os.Create.
+func (n *NotifyingFS) Create(name string) (*os.File, error) {
+	op := n.createOrWriteOp(name)
+	f, err := n.fileSystem.Create(name)
+	if err != nil {
+		return nil, err
+	}
+	n.notify(name, op)
+	return f, nil
+}
+
+// DefaultTempDir implements DefaultTempDirer.DefaultTempDir by returning
+// TempDir(n's underlying FS).
+func (n *NotifyingFS) DefaultTempDir() string {
+	return TempDir(n.fileSystem)
+}
+
+// Glob implements filepath.Glob.
+func (n *NotifyingFS) Glob(pattern string) ([]string, error) {
+	return n.fileSystem.Glob(pattern)
+}
+
+// Lchown implements os.Lchown.
+func (n *NotifyingFS) Lchown(name string, uid, gid int) error {
+	if err := n.fileSystem.Lchown(name, uid, gid); err != nil {
+		return err
+	}
+	n.notify(name, OpChmod)
+	return nil
+}
+
+// Link implements os.Link.
+func (n *NotifyingFS) Link(oldname, newname string) error {
+	if err := n.fileSystem.Link(oldname, newname); err != nil {
+		return err
+	}
+	n.notify(newname, OpCreate)
+	return nil
+}
+
+// Lstat implements os.Lstat.
+func (n *NotifyingFS) Lstat(name string) (fs.FileInfo, error) {
+	return n.fileSystem.Lstat(name)
+}
+
+// Mkdir implements os.Mkdir.
+func (n *NotifyingFS) Mkdir(name string, perm fs.FileMode) error {
+	if err := n.fileSystem.Mkdir(name, perm); err != nil {
+		return err
+	}
+	n.notify(name, OpCreate)
+	return nil
+}
+
+// Open implements os.Open.
+func (n *NotifyingFS) Open(name string) (fs.File, error) {
+	return n.fileSystem.Open(name)
+}
+
+// OpenFile implements os.OpenFile.
+func (n *NotifyingFS) OpenFile(name string, flag int, perm fs.FileMode) (*os.File, error) {
+	var op Op
+	switch {
+	case flag&os.O_CREATE != 0:
+		op = n.createOrWriteOp(name)
+		if op == OpWrite && flag&os.O_TRUNC == 0 {
+			op = 0
+		}
+	case flag&os.O_TRUNC != 0:
+		op = OpWrite
+	}
+	f, err := n.fileSystem.OpenFile(name, flag, perm)
+	if err != nil {
+		return nil, err
+	}
+	if op != 0 {
+		n.notify(name, op)
+	}
+	return f, nil
+}
+
+// PathSeparator implements PathSeparator.
+func (n *NotifyingFS) PathSeparator() rune {
+	return n.fileSystem.PathSeparator()
+}
+
+// RawPath implements RawPath.
+func (n *NotifyingFS) RawPath(path string) (string, error) {
+	return n.fileSystem.RawPath(path)
+}
+
+// ReadDir implements os.ReadDir.
+func (n *NotifyingFS) ReadDir(dirname string) ([]fs.DirEntry, error) {
+	return n.fileSystem.ReadDir(dirname)
+}
+
+// ReadFile implements os.ReadFile.
+func (n *NotifyingFS) ReadFile(filename string) ([]byte, error) {
+	return n.fileSystem.ReadFile(filename)
+}
+
+// Readlink implements os.Readlink.
+func (n *NotifyingFS) Readlink(name string) (string, error) {
+	return n.fileSystem.Readlink(name)
+}
+
+// Remove implements os.Remove.
+func (n *NotifyingFS) Remove(name string) error {
+	if err := n.fileSystem.Remove(name); err != nil {
+		return err
+	}
+	n.notify(name, OpRemove)
+	return nil
+}
+
+// RemoveAll implements os.RemoveAll. Events are generated for every removed
+// path, children before their parents.
+func (n *NotifyingFS) RemoveAll(name string) error {
+	var paths []string
+	if err := Walk(n.fileSystem, name, func(path string, info fs.FileInfo, err error) error {
+		switch {
+		case errors.Is(err, fs.ErrNotExist):
+			return nil
+		case err != nil:
+			return err
+		default:
+			paths = append(paths, path)
+			return nil
+		}
+	}); err != nil {
+		return err
+	}
+	if err := n.fileSystem.RemoveAll(name); err != nil {
+		return err
+	}
+	for i := len(paths) - 1; i >= 0; i-- {
+		n.notify(paths[i], OpRemove)
+	}
+	return nil
+}
+
+// Rename implements os.Rename.
+func (n *NotifyingFS) Rename(oldpath, newpath string) error {
+	if err := n.fileSystem.Rename(oldpath, newpath); err != nil {
+		return err
+	}
+	n.notify(oldpath, OpRename)
+	n.notify(newpath, OpCreate)
+	return nil
+}
+
+// Stat implements os.Stat.
+func (n *NotifyingFS) Stat(name string) (fs.FileInfo, error) {
+	return n.fileSystem.Stat(name)
+}
+
+// Symlink implements os.Symlink.
+func (n *NotifyingFS) Symlink(oldname, newname string) error {
+	if err := n.fileSystem.Symlink(oldname, newname); err != nil {
+		return err
+	}
+	n.notify(newname, OpCreate)
+	return nil
+}
+
+// Truncate implements os.Truncate.
+func (n *NotifyingFS) Truncate(name string, size int64) error {
+	if err := n.fileSystem.Truncate(name, size); err != nil {
+		return err
+	}
+	n.notify(name, OpWrite)
+	return nil
+}
+
+// Watch implements Watcher.Watch. Only modifications made through n are
+// reported.
+func (n *NotifyingFS) Watch(name string, recursive bool) (*Watch, error) {
+	w := &notifyingWatch{
+		name:      name,
+		recursive: recursive,
+		queue:     newEventQueue(),
+	}
+	n.mu.Lock()
+	n.watches[w] = struct{}{}
+	n.mu.Unlock()
+	return NewWatch(w.queue.out, func() error {
+		n.mu.Lock()
+		delete(n.watches, w)
+		n.mu.Unlock()
+		return w.queue.close()
+	}), nil
+}
+
+// WriteFile implements os.WriteFile.
+func (n *NotifyingFS) WriteFile(filename string, data []byte, perm fs.FileMode) error {
+	op := n.createOrWriteOp(filename)
+	if err := n.fileSystem.WriteFile(filename, data, perm); err != nil {
+		return err
+	}
+	n.notify(filename, op)
+	return nil
+}
+
+// createOrWriteOp returns OpWrite if name exists, or OpCreate otherwise.
+func (n *NotifyingFS) createOrWriteOp(name string) Op {
+	if _, err := n.fileSystem.Lstat(name); err == nil {
+		return OpWrite
+	}
+	return OpCreate
+}
+
+// notify delivers an event with name and op to all matching watches.
+func (n *NotifyingFS) notify(name string, op Op) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	for w := range n.watches {
+		if watchMatches(w.name, w.recursive, name) {
+			w.queue.push(Event{
+				Name: name,
+				Op:   op,
+			})
+		}
+	}
+}
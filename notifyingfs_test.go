@@ -0,0 +1,9 @@
+package vfs_test
+
+import "github.com/twpayne/go-vfs/v5"
+
+var (
+	_ vfs.FS               = &vfs.NotifyingFS{}
+	_ vfs.DefaultTempDirer = &vfs.NotifyingFS{}
+	_ vfs.Watcher          = &vfs.NotifyingFS{}
+)
@@ -32,6 +32,11 @@ func (osfs) Create(name string) (*os.File, error) {
 	return os.Create(name)
 }
 
+// DefaultTempDir implements DefaultTempDirer.DefaultTempDir with os.TempDir.
+func (osfs) DefaultTempDir() string {
+	return os.TempDir()
+}
+
 // Glob implements filepath.Glob.
 func (osfs) Glob(pattern string) ([]string, error) {
 	return filepath.Glob(pattern)
@@ -3,3 +3,5 @@ package vfs_test
 import "github.com/twpayne/go-vfs/v5"
 
 var _ vfs.FS = vfs.OSFS
+
+var _ vfs.DefaultTempDirer = vfs.OSFS
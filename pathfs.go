@@ -62,6 +62,26 @@ func (p *PathFS) Create(name string) (*os.File, error) {
 	return p.fileSystem.Create(realName)
 }
 
+// DefaultTempDir implements DefaultTempDirer.DefaultTempDir. It returns
+// "/tmp", so that temporary files are kept inside p. "/tmp" is not created
+// automatically.
+func (p *PathFS) DefaultTempDir() string {
+	return "/tmp"
+}
+
+// Getxattr implements XattrFS.Getxattr if p's underlying FS implements XattrFS.
+func (p *PathFS) Getxattr(name, attr string) ([]byte, error) {
+	realName, err := p.join("Getxattr", name)
+	if err != nil {
+		return nil, err
+	}
+	xattrFS, ok := p.fileSystem.(XattrFS)
+	if !ok {
+		return nil, unsupportedError("Getxattr", name)
+	}
+	return xattrFS.Getxattr(realName, attr)
+}
+
 // Glob implements filepath.Glob.
 func (p *PathFS) Glob(pattern string) ([]string, error) {
 	realPattern, err := p.join("Glob", pattern)
@@ -86,6 +106,19 @@ func (p *PathFS) Join(op, name string) (string, error) {
 	return p.join("Join", name)
 }
 
+// Lchmod implements Lchmoder.Lchmod if p's underlying FS implements Lchmoder.
+func (p *PathFS) Lchmod(name string, mode fs.FileMode) error {
+	realName, err := p.join("Lchmod", name)
+	if err != nil {
+		return err
+	}
+	lchmoder, ok := p.fileSystem.(Lchmoder)
+	if !ok {
+		return unsupportedError("Lchmod", name)
+	}
+	return lchmoder.Lchmod(realName, mode)
+}
+
 // Lchown implements os.Lchown.
 func (p *PathFS) Lchown(name string, uid, gid int) error {
 	realName, err := p.join("Lchown", name)
@@ -95,6 +128,33 @@ func (p *PathFS) Lchown(name string, uid, gid int) error {
 	return p.fileSystem.Lchown(realName, uid, gid)
 }
 
+// Lchtimes implements Lchtimeser.Lchtimes if p's underlying FS implements
+// Lchtimeser.
+func (p *PathFS) Lchtimes(name string, atime, mtime time.Time) error {
+	realName, err := p.join("Lchtimes", name)
+	if err != nil {
+		return err
+	}
+	lchtimeser, ok := p.fileSystem.(Lchtimeser)
+	if !ok {
+		return unsupportedError("Lchtimes", name)
+	}
+	return lchtimeser.Lchtimes(realName, atime, mtime)
+}
+
+// Lgetxattr implements XattrFS.Lgetxattr if p's underlying FS implements XattrFS.
+func (p *PathFS) Lgetxattr(name, attr string) ([]byte, error) {
+	realName, err := p.join("Lgetxattr", name)
+	if err != nil {
+		return nil, err
+	}
+	xattrFS, ok := p.fileSystem.(XattrFS)
+	if !ok {
+		return nil, unsupportedError("Lgetxattr", name)
+	}
+	return xattrFS.Lgetxattr(realName, attr)
+}
+
 // Link implements os.Link.
 func (p *PathFS) Link(oldname, newname string) error {
 	var realOldname string
@@ -114,6 +174,71 @@ func (p *PathFS) Link(oldname, newname string) error {
 	return p.fileSystem.Link(realOldname, realNewname)
 }
 
+// Listxattr implements XattrFS.Listxattr if p's underlying FS implements XattrFS.
+func (p *PathFS) Listxattr(name string) ([]string, error) {
+	realName, err := p.join("Listxattr", name)
+	if err != nil {
+		return nil, err
+	}
+	xattrFS, ok := p.fileSystem.(XattrFS)
+	if !ok {
+		return nil, unsupportedError("Listxattr", name)
+	}
+	return xattrFS.Listxattr(realName)
+}
+
+// Llistxattr implements XattrFS.Llistxattr if p's underlying FS implements XattrFS.
+func (p *PathFS) Llistxattr(name string) ([]string, error) {
+	realName, err := p.join("Llistxattr", name)
+	if err != nil {
+		return nil, err
+	}
+	xattrFS, ok := p.fileSystem.(XattrFS)
+	if !ok {
+		return nil, unsupportedError("Llistxattr", name)
+	}
+	return xattrFS.Llistxattr(realName)
+}
+
+// Lock implements Locker.Lock if p's underlying FS implements Locker.
+func (p *PathFS) Lock(name string, mode LockMode, wait bool) (*Lock, error) {
+	realName, err := p.join("Lock", name)
+	if err != nil {
+		return nil, err
+	}
+	locker, ok := p.fileSystem.(Locker)
+	if !ok {
+		return nil, unsupportedError("Lock", name)
+	}
+	return locker.Lock(realName, mode, wait)
+}
+
+// Lremovexattr implements XattrFS.Lremovexattr if p's underlying FS implements XattrFS.
+func (p *PathFS) Lremovexattr(name, attr string) error {
+	realName, err := p.join("Lremovexattr", name)
+	if err != nil {
+		return err
+	}
+	xattrFS, ok := p.fileSystem.(XattrFS)
+	if !ok {
+		return unsupportedError("Lremovexattr", name)
+	}
+	return xattrFS.Lremovexattr(realName, attr)
+}
+
+// Lsetxattr implements XattrFS.Lsetxattr if p's underlying FS implements XattrFS.
+func (p *PathFS) Lsetxattr(name, attr string, value []byte) error {
+	realName, err := p.join("Lsetxattr", name)
+	if err != nil {
+		return err
+	}
+	xattrFS, ok := p.fileSystem.(XattrFS)
+	if !ok {
+		return unsupportedError("Lsetxattr", name)
+	}
+	return xattrFS.Lsetxattr(realName, attr, value)
+}
+
 // Lstat implements os.Lstat.
 func (p *PathFS) Lstat(name string) (fs.FileInfo, error) {
 	realName, err := p.join("Lstat", name)
@@ -205,6 +330,19 @@ func (p *PathFS) RemoveAll(name string) error {
 	return p.fileSystem.RemoveAll(realName)
 }
 
+// Removexattr implements XattrFS.Removexattr if p's underlying FS implements XattrFS.
+func (p *PathFS) Removexattr(name, attr string) error {
+	realName, err := p.join("Removexattr", name)
+	if err != nil {
+		return err
+	}
+	xattrFS, ok := p.fileSystem.(XattrFS)
+	if !ok {
+		return unsupportedError("Removexattr", name)
+	}
+	return xattrFS.Removexattr(realName, attr)
+}
+
 // Rename implements os.Rename.
 func (p *PathFS) Rename(oldpath, newpath string) error {
 	realOldpath, err := p.join("Rename", oldpath)
@@ -227,6 +365,32 @@ func (p *PathFS) Stat(name string) (fs.FileInfo, error) {
 	return p.fileSystem.Stat(realName)
 }
 
+// Setxattr implements XattrFS.Setxattr if p's underlying FS implements XattrFS.
+func (p *PathFS) Setxattr(name, attr string, value []byte) error {
+	realName, err := p.join("Setxattr", name)
+	if err != nil {
+		return err
+	}
+	xattrFS, ok := p.fileSystem.(XattrFS)
+	if !ok {
+		return unsupportedError("Setxattr", name)
+	}
+	return xattrFS.Setxattr(realName, attr, value)
+}
+
+// Statfs implements Statfser.Statfs if p's underlying FS implements Statfser.
+func (p *PathFS) Statfs(name string) (*FSStat, error) {
+	realName, err := p.join("Statfs", name)
+	if err != nil {
+		return nil, err
+	}
+	statfser, ok := p.fileSystem.(Statfser)
+	if !ok {
+		return nil, unsupportedError("Statfs", name)
+	}
+	return statfser.Statfs(realName)
+}
+
 // Symlink implements os.Symlink.
 func (p *PathFS) Symlink(oldname, newname string) error {
 	var realOldname string
@@ -255,6 +419,43 @@ func (p *PathFS) Truncate(name string, size int64) error {
 	return p.fileSystem.Truncate(realName, size)
 }
 
+// Watch implements Watcher.Watch if p's underlying FS implements Watcher.
+// Event names are translated to be relative to p's path.
+func (p *PathFS) Watch(name string, recursive bool) (*Watch, error) {
+	realName, err := p.join("Watch", name)
+	if err != nil {
+		return nil, err
+	}
+	watcher, ok := p.fileSystem.(Watcher)
+	if !ok {
+		return nil, unsupportedError("Watch", name)
+	}
+	watch, err := watcher.Watch(realName, recursive)
+	if err != nil {
+		return nil, err
+	}
+	events := make(chan Event)
+	done := make(chan struct{})
+	go func() {
+		defer close(events)
+		for event := range watch.Events() {
+			eventName, err := trimPrefix(event.Name, p.path)
+			if err != nil {
+				continue
+			}
+			select {
+			case events <- Event{Name: eventName, Op: event.Op}:
+			case <-done:
+				return
+			}
+		}
+	}()
+	return NewWatch(events, func() error {
+		close(done)
+		return watch.Close()
+	}), nil
+}
+
 // WriteFile implements io.WriteFile.
 func (p *PathFS) WriteFile(filename string, data []byte, perm fs.FileMode) error {
 	realFilename, err := p.join("WriteFile", filename)
@@ -3,3 +3,17 @@ package vfs_test
 import "github.com/twpayne/go-vfs/v5"
 
 var _ vfs.FS = &vfs.PathFS{}
+
+var _ vfs.Watcher = &vfs.PathFS{}
+
+var _ vfs.Locker = &vfs.PathFS{}
+
+var _ vfs.XattrFS = &vfs.PathFS{}
+
+var _ vfs.Statfser = &vfs.PathFS{}
+
+var _ vfs.Lchmoder = &vfs.PathFS{}
+
+var _ vfs.Lchtimeser = &vfs.PathFS{}
+
+var _ vfs.DefaultTempDirer = &vfs.PathFS{}
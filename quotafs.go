@@ -0,0 +1,474 @@
+package vfs
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// A QuotaFS operates on an existing FS, but limits the total size of the
+// regular files and the total number of entries below a root directory, and
+// reports these limits from Statfs. It can be used to test how code behaves
+// when a filesystem is full. Usage is computed by walking the root directory
+// when it is first needed, and is then updated as entries are modified through
+// the QuotaFS. Changes made by other means, including writes to *os.Files
+// returned by Create and OpenFile, are neither limited nor counted. Names
+// outside the root directory are not limited. Each hard link counts as an
+// entry, but the size of a file is only counted once, however many hard links
+// it has below the root directory.
+type QuotaFS struct {
+	fileSystem FS
+	root       string
+	maxBytes   uint64
+	maxInodes  uint64
+	mu         sync.Mutex
+	used       quotaUsage
+	counted    bool
+}
+
+// A quotaUsage is the usage of a tree in a QuotaFS.
+type quotaUsage struct {
+	bytes  uint64
+	inodes uint64
+}
+
+// NewQuotaFS returns a new *QuotaFS operating on fileSystem that limits the
+// tree at root to maxBytes bytes and maxInodes entries.
+func NewQuotaFS(fileSystem FS, root string, maxBytes, maxInodes uint64) *QuotaFS {
+	return &QuotaFS{
+		fileSystem: fileSystem,
+		root:       filepath.Clean(root),
+		maxBytes:   maxBytes,
+		maxInodes:  maxInodes,
+	}
+}
+
+// Chmod implements os.Chmod.
+func (q *QuotaFS) Chmod(name string, mode fs.FileMode) error {
+	return q.fileSystem.Chmod(name, mode)
+}
+
+// Chown implements os.Chown.
+func (q *QuotaFS) Chown(name string, uid, gid int) error {
+	return q.fileSystem.Chown(name, uid, gid)
+}
+
+// Chtimes implements os.Chtimes.
+func (q *QuotaFS) Chtimes(name string, atime, mtime time.Time) error {
+	return q.fileSystem.Chtimes(name, atime, mtime)
+}
+
+// Create implements os.Create.
+func (q *QuotaFS) Create(name string) (*os.File, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	used, err := q.checkCreate("Create", name, 0)
+	if err != nil {
+		return nil, err
+	}
+	f, err := q.fileSystem.Create(name)
+	if err != nil {
+		return nil, err
+	}
+	q.used = used
+	return f, nil
+}
+
+// DefaultTempDir implements DefaultTempDirer.DefaultTempDir by returning
+// TempDir(q's underlying FS).
+func (q *QuotaFS) DefaultTempDir() string {
+	return TempDir(q.fileSystem)
+}
+
+// Glob implements filepath.Glob.
+func (q *QuotaFS) Glob(pattern string) ([]string, error) {
+	return q.fileSystem.Glob(pattern)
+}
+
+// Lchown implements os.Lchown.
+func (q *QuotaFS) Lchown(name string, uid, gid int) error {
+	return q.fileSystem.Lchown(name, uid, gid)
+}
+
+// Link implements os.Link.
+func (q *QuotaFS) Link(oldname, newname string) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	// The size of oldname is already counted if it is below q's root.
+	var size int64
+	if !q.isBelowRoot(oldname) {
+		if info, err := q.fileSystem.Lstat(oldname); err == nil && info.Mode().IsRegular() {
+			size = info.Size()
+		}
+	}
+	used, err := q.checkCreate("Link", newname, size)
+	if err != nil {
+		return err
+	}
+	if err := q.fileSystem.Link(oldname, newname); err != nil {
+		return err
+	}
+	q.used = used
+	return nil
+}
+
+// Lstat implements os.Lstat.
+func (q *QuotaFS) Lstat(name string) (fs.FileInfo, error) {
+	return q.fileSystem.Lstat(name)
+}
+
+// Mkdir implements os.Mkdir.
+func (q *QuotaFS) Mkdir(name string, perm fs.FileMode) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	used, err := q.checkCreate("Mkdir", name, 0)
+	if err != nil {
+		return err
+	}
+	if err := q.fileSystem.Mkdir(name, perm); err != nil {
+		return err
+	}
+	q.used = used
+	return nil
+}
+
+// Open implements os.Open.
+func (q *QuotaFS) Open(name string) (fs.File, error) {
+	return q.fileSystem.Open(name)
+}
+
+// OpenFile implements os.OpenFile.
+func (q *QuotaFS) OpenFile(name string, flag int, perm fs.FileMode) (*os.File, error) {
+	if flag&(os.O_CREATE|os.O_TRUNC) == 0 {
+		return q.fileSystem.OpenFile(name, flag, perm)
+	}
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	var size int64
+	if flag&os.O_TRUNC == 0 {
+		if info, err := q.fileSystem.Lstat(name); err == nil && info.Mode().IsRegular() {
+			size = info.Size()
+		}
+	}
+	used, err := q.checkCreate("OpenFile", name, size)
+	if err != nil {
+		return nil, err
+	}
+	f, err := q.fileSystem.OpenFile(name, flag, perm)
+	if err != nil {
+		return nil, err
+	}
+	q.used = used
+	return f, nil
+}
+
+// PathSeparator implements PathSeparator.
+func (q *QuotaFS) PathSeparator() rune {
+	return q.fileSystem.PathSeparator()
+}
+
+// RawPath implements RawPath.
+func (q *QuotaFS) RawPath(path string) (string, error) {
+	return q.fileSystem.RawPath(path)
+}
+
+// ReadDir implements os.ReadDir.
+func (q *QuotaFS) ReadDir(dirname string) ([]fs.DirEntry, error) {
+	return q.fileSystem.ReadDir(dirname)
+}
+
+// ReadFile implements os.ReadFile.
+func (q *QuotaFS) ReadFile(filename string) ([]byte, error) {
+	return q.fileSystem.ReadFile(filename)
+}
+
+// Readlink implements os.Readlink.
+func (q *QuotaFS) Readlink(name string) (string, error) {
+	return q.fileSystem.Readlink(name)
+}
+
+// Remove implements os.Remove.
+func (q *QuotaFS) Remove(name string) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return q.remove(name, q.fileSystem.Remove)
+}
+
+// RemoveAll implements os.RemoveAll.
+func (q *QuotaFS) RemoveAll(name string) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return q.remove(name, q.fileSystem.RemoveAll)
+}
+
+// Rename implements os.Rename. Renaming from outside q's root to inside it is
+// limited.
+func (q *QuotaFS) Rename(oldpath, newpath string) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	oldInside, newInside := q.isBelowRoot(oldpath), q.isBelowRoot(newpath)
+	if !oldInside && !newInside {
+		if q.isRootOrAbove(oldpath) || q.isRootOrAbove(newpath) {
+			q.counted = false
+		}
+		return q.fileSystem.Rename(oldpath, newpath)
+	}
+	used, err := q.usageLocked()
+	if err != nil {
+		return err
+	}
+	moved, movedLinked, err := q.treeUsage(oldpath)
+	if err != nil {
+		return err
+	}
+	replaced, replacedLinked, err := q.treeUsage(newpath)
+	switch {
+	case errors.Is(err, fs.ErrNotExist):
+	case err != nil:
+		return err
+	}
+	if oldInside {
+		used = used.sub(moved)
+	}
+	if newInside {
+		used = used.sub(replaced).add(moved)
+		if !oldInside && (used.bytes > q.maxBytes || used.inodes > q.maxInodes) {
+			return &os.LinkError{
+				Op:  "Rename",
+				Old: oldpath,
+				New: newpath,
+				Err: syscall.ENOSPC,
+			}
+		}
+	}
+	if err := q.fileSystem.Rename(oldpath, newpath); err != nil {
+		return err
+	}
+	if (movedLinked && oldInside != newInside) || replacedLinked {
+		// Other hard links may remain, so recount when next needed.
+		q.counted = false
+		return nil
+	}
+	q.used = used
+	return nil
+}
+
+// Stat implements os.Stat.
+func (q *QuotaFS) Stat(name string) (fs.FileInfo, error) {
+	return q.fileSystem.Stat(name)
+}
+
+// Statfs implements Statfser.Statfs. For q's root directory and names below
+// it, it reports q's limits and the current usage of q's root directory. Other
+// names are passed to q's underlying FS if it implements Statfser.
+func (q *QuotaFS) Statfs(name string) (*FSStat, error) {
+	if !q.isBelowRoot(name) && filepath.Clean(name) != q.root {
+		statfser, ok := q.fileSystem.(Statfser)
+		if !ok {
+			return nil, unsupportedError("Statfs", name)
+		}
+		return statfser.Statfs(name)
+	}
+	if _, err := q.fileSystem.Lstat(name); err != nil {
+		return nil, err
+	}
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	used, err := q.usageLocked()
+	if err != nil {
+		return nil, err
+	}
+	return &FSStat{
+		Type:           "quota",
+		BlockSize:      1,
+		TotalBytes:     q.maxBytes,
+		FreeBytes:      q.maxBytes - min(used.bytes, q.maxBytes),
+		AvailableBytes: q.maxBytes - min(used.bytes, q.maxBytes),
+		TotalInodes:    q.maxInodes,
+		FreeInodes:     q.maxInodes - min(used.inodes, q.maxInodes),
+	}, nil
+}
+
+// Symlink implements os.Symlink.
+func (q *QuotaFS) Symlink(oldname, newname string) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	used, err := q.checkCreate("Symlink", newname, 0)
+	if err != nil {
+		return err
+	}
+	if err := q.fileSystem.Symlink(oldname, newname); err != nil {
+		return err
+	}
+	q.used = used
+	return nil
+}
+
+// Truncate implements os.Truncate.
+func (q *QuotaFS) Truncate(name string, size int64) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	used, err := q.checkCreate("Truncate", name, size)
+	if err != nil {
+		return err
+	}
+	if err := q.fileSystem.Truncate(name, size); err != nil {
+		return err
+	}
+	q.used = used
+	return nil
+}
+
+// WriteFile implements os.WriteFile.
+func (q *QuotaFS) WriteFile(filename string, data []byte, perm fs.FileMode) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	used, err := q.checkCreate("WriteFile", filename, int64(len(data)))
+	if err != nil {
+		return err
+	}
+	if err := q.fileSystem.WriteFile(filename, data, perm); err != nil {
+		return err
+	}
+	q.used = used
+	return nil
+}
+
+// checkCreate returns q's usage after creating or replacing name with a file
+// of size bytes, or an error if this would exceed q's limits. If name is not
+// below q's root then q's usage is returned unchanged. q.mu must be held.
+func (q *QuotaFS) checkCreate(op, name string, size int64) (quotaUsage, error) {
+	used, err := q.usageLocked()
+	if err != nil {
+		return quotaUsage{}, err
+	}
+	if !q.isBelowRoot(name) {
+		return used, nil
+	}
+	switch info, err := q.fileSystem.Lstat(name); {
+	case errors.Is(err, fs.ErrNotExist):
+		used.inodes++
+	case err != nil:
+		return quotaUsage{}, err
+	case info.Mode().IsRegular():
+		used.bytes -= min(used.bytes, uint64(info.Size()))
+	}
+	used.bytes += uint64(size) //nolint:gosec
+	if used.bytes > q.maxBytes || used.inodes > q.maxInodes {
+		return quotaUsage{}, &os.PathError{
+			Op:   op,
+			Path: name,
+			Err:  syscall.ENOSPC,
+		}
+	}
+	return used, nil
+}
+
+// isBelowRoot returns whether name is below q's root.
+func (q *QuotaFS) isBelowRoot(name string) bool {
+	rel, err := filepath.Rel(q.root, filepath.Clean(name))
+	return err == nil && rel != "." && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+// isRootOrAbove returns whether name is q's root or one of its ancestors.
+func (q *QuotaFS) isRootOrAbove(name string) bool {
+	rel, err := filepath.Rel(filepath.Clean(name), q.root)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+// remove removes name with removeFunc and updates q's usage. q.mu must be
+// held.
+func (q *QuotaFS) remove(name string, removeFunc func(string) error) error {
+	if !q.isBelowRoot(name) {
+		if q.isRootOrAbove(name) {
+			q.counted = false
+		}
+		return removeFunc(name)
+	}
+	used, err := q.usageLocked()
+	if err != nil {
+		return err
+	}
+	removed, linked, err := q.treeUsage(name)
+	switch {
+	case errors.Is(err, fs.ErrNotExist):
+	case err != nil:
+		return err
+	}
+	if err := removeFunc(name); err != nil {
+		return err
+	}
+	if linked {
+		// Other hard links may remain, so recount when next needed.
+		q.counted = false
+		return nil
+	}
+	q.used = used.sub(removed)
+	return nil
+}
+
+// treeUsage returns the usage of the tree rooted at name, including name
+// itself, and whether the tree contains regular files with several hard links.
+// The size of each such file is only counted once.
+func (q *QuotaFS) treeUsage(name string) (quotaUsage, bool, error) {
+	var used quotaUsage
+	var linkedInfos []fs.FileInfo
+	err := Walk(q.fileSystem, name, func(path string, info fs.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		used.inodes++
+		if !info.Mode().IsRegular() {
+			return nil
+		}
+		if linkCount(info) > 1 {
+			for _, linkedInfo := range linkedInfos {
+				if os.SameFile(info, linkedInfo) {
+					return nil
+				}
+			}
+			linkedInfos = append(linkedInfos, info)
+		}
+		used.bytes += uint64(info.Size())
+		return nil
+	})
+	return used, len(linkedInfos) > 0, err
+}
+
+// usageLocked returns the total size of regular files and the number of
+// entries below q's root, walking q's root if they are not already known.
+// q.mu must be held.
+func (q *QuotaFS) usageLocked() (quotaUsage, error) {
+	if q.counted {
+		return q.used, nil
+	}
+	used, _, err := q.treeUsage(q.root)
+	if err != nil {
+		return quotaUsage{}, err
+	}
+	// The root itself is not counted.
+	q.used = used.sub(quotaUsage{inodes: 1})
+	q.counted = true
+	return q.used, nil
+}
+
+// add returns u plus v.
+func (u quotaUsage) add(v quotaUsage) quotaUsage {
+	return quotaUsage{
+		bytes:  u.bytes + v.bytes,
+		inodes: u.inodes + v.inodes,
+	}
+}
+
+// sub returns u minus v, without going below zero.
+func (u quotaUsage) sub(v quotaUsage) quotaUsage {
+	return quotaUsage{
+		bytes:  u.bytes - min(u.bytes, v.bytes),
+		inodes: u.inodes - min(u.inodes, v.inodes),
+	}
+}
@@ -0,0 +1,9 @@
+package vfs_test
+
+import "github.com/twpayne/go-vfs/v5"
+
+var (
+	_ vfs.FS               = &vfs.QuotaFS{}
+	_ vfs.DefaultTempDirer = &vfs.QuotaFS{}
+	_ vfs.Statfser         = &vfs.QuotaFS{}
+)
@@ -1,6 +1,7 @@
 package vfs
 
 import (
+	"errors"
 	"io/fs"
 	"os"
 	"syscall"
@@ -40,21 +41,96 @@ func (r *ReadOnlyFS) Create(name string) (*os.File, error) {
 	return nil, permError("Create", name)
 }
 
+// DefaultTempDir implements DefaultTempDirer.DefaultTempDir by returning
+// TempDir(r's underlying FS).
+func (r *ReadOnlyFS) DefaultTempDir() string {
+	return TempDir(r.fileSystem)
+}
+
+// Getxattr implements XattrFS.Getxattr if r's underlying FS implements XattrFS.
+func (r *ReadOnlyFS) Getxattr(name, attr string) ([]byte, error) {
+	xattrFS, ok := r.fileSystem.(XattrFS)
+	if !ok {
+		return nil, unsupportedError("Getxattr", name)
+	}
+	return xattrFS.Getxattr(name, attr)
+}
+
 // Glob implements filepath.Glob.
 func (r *ReadOnlyFS) Glob(pattern string) ([]string, error) {
 	return r.fileSystem.Glob(pattern)
 }
 
+// Lchmod implements Lchmoder.Lchmod.
+func (r *ReadOnlyFS) Lchmod(name string, mode fs.FileMode) error {
+	return permError("Lchmod", name)
+}
+
 // Lchown implements os.Lchown.
 func (r *ReadOnlyFS) Lchown(name string, uid, gid int) error {
 	return permError("Lchown", name)
 }
 
+// Lchtimes implements Lchtimeser.Lchtimes.
+func (r *ReadOnlyFS) Lchtimes(name string, atime, mtime time.Time) error {
+	return permError("Lchtimes", name)
+}
+
+// Lgetxattr implements XattrFS.Lgetxattr if r's underlying FS implements XattrFS.
+func (r *ReadOnlyFS) Lgetxattr(name, attr string) ([]byte, error) {
+	xattrFS, ok := r.fileSystem.(XattrFS)
+	if !ok {
+		return nil, unsupportedError("Lgetxattr", name)
+	}
+	return xattrFS.Lgetxattr(name, attr)
+}
+
 // Link implements os.Link.
 func (r *ReadOnlyFS) Link(oldname, newname string) error {
 	return permError("Link", newname)
 }
 
+// Listxattr implements XattrFS.Listxattr if r's underlying FS implements XattrFS.
+func (r *ReadOnlyFS) Listxattr(name string) ([]string, error) {
+	xattrFS, ok := r.fileSystem.(XattrFS)
+	if !ok {
+		return nil, unsupportedError("Listxattr", name)
+	}
+	return xattrFS.Listxattr(name)
+}
+
+// Llistxattr implements XattrFS.Llistxattr if r's underlying FS implements XattrFS.
+func (r *ReadOnlyFS) Llistxattr(name string) ([]string, error) {
+	xattrFS, ok := r.fileSystem.(XattrFS)
+	if !ok {
+		return nil, unsupportedError("Llistxattr", name)
+	}
+	return xattrFS.Llistxattr(name)
+}
+
+// Lock implements Locker.Lock if r's underlying FS implements Locker. Only
+// shared locks are permitted.
+func (r *ReadOnlyFS) Lock(name string, mode LockMode, wait bool) (*Lock, error) {
+	if mode != LockShared {
+		return nil, permError("Lock", name)
+	}
+	locker, ok := r.fileSystem.(Locker)
+	if !ok {
+		return nil, unsupportedError("Lock", name)
+	}
+	return locker.Lock(name, mode, wait)
+}
+
+// Lremovexattr implements XattrFS.Lremovexattr.
+func (r *ReadOnlyFS) Lremovexattr(name, attr string) error {
+	return permError("Lremovexattr", name)
+}
+
+// Lsetxattr implements XattrFS.Lsetxattr.
+func (r *ReadOnlyFS) Lsetxattr(name, attr string, value []byte) error {
+	return permError("Lsetxattr", name)
+}
+
 // Lstat implements os.Lstat.
 func (r *ReadOnlyFS) Lstat(name string) (fs.FileInfo, error) {
 	return r.fileSystem.Lstat(name)
@@ -108,6 +184,11 @@ func (r *ReadOnlyFS) RemoveAll(name string) error {
 	return permError("RemoveAll", name)
 }
 
+// Removexattr implements XattrFS.Removexattr.
+func (r *ReadOnlyFS) Removexattr(name, attr string) error {
+	return permError("Removexattr", name)
+}
+
 // Rename implements os.Rename.
 func (r *ReadOnlyFS) Rename(oldpath, newpath string) error {
 	return permError("Rename", oldpath)
@@ -123,6 +204,28 @@ func (r *ReadOnlyFS) Stat(name string) (fs.FileInfo, error) {
 	return r.fileSystem.Stat(name)
 }
 
+// Setxattr implements XattrFS.Setxattr.
+func (r *ReadOnlyFS) Setxattr(name, attr string, value []byte) error {
+	return permError("Setxattr", name)
+}
+
+// Statfs implements Statfser.Statfs if r's underlying FS implements Statfser.
+// The returned filesystem is always read-only with no available bytes.
+func (r *ReadOnlyFS) Statfs(name string) (*FSStat, error) {
+	statfser, ok := r.fileSystem.(Statfser)
+	if !ok {
+		return nil, unsupportedError("Statfs", name)
+	}
+	fsStat, err := statfser.Statfs(name)
+	if err != nil {
+		return nil, err
+	}
+	readOnlyFSStat := *fsStat
+	readOnlyFSStat.AvailableBytes = 0
+	readOnlyFSStat.ReadOnly = true
+	return &readOnlyFSStat, nil
+}
+
 // Symlink implements os.Symlink.
 func (r *ReadOnlyFS) Symlink(oldname, newname string) error {
 	return permError("Symlink", newname)
@@ -133,6 +236,15 @@ func (r *ReadOnlyFS) Truncate(name string, size int64) error {
 	return permError("Truncate", name)
 }
 
+// Watch implements Watcher.Watch if r's underlying FS implements Watcher.
+func (r *ReadOnlyFS) Watch(name string, recursive bool) (*Watch, error) {
+	watcher, ok := r.fileSystem.(Watcher)
+	if !ok {
+		return nil, unsupportedError("Watch", name)
+	}
+	return watcher.Watch(name, recursive)
+}
+
 // WriteFile implements os.WriteFile.
 func (r *ReadOnlyFS) WriteFile(filename string, data []byte, perm fs.FileMode) error {
 	return permError("WriteFile", filename)
@@ -146,3 +258,12 @@ func permError(op, path string) error {
 		Err:  syscall.EPERM,
 	}
 }
+
+// unsupportedError returns an *os.PathError with Err errors.ErrUnsupported.
+func unsupportedError(op, path string) error {
+	return &os.PathError{
+		Op:   op,
+		Path: path,
+		Err:  errors.ErrUnsupported,
+	}
+}
@@ -3,3 +3,17 @@ package vfs_test
 import "github.com/twpayne/go-vfs/v5"
 
 var _ vfs.FS = &vfs.ReadOnlyFS{}
+
+var _ vfs.Watcher = &vfs.ReadOnlyFS{}
+
+var _ vfs.Locker = &vfs.ReadOnlyFS{}
+
+var _ vfs.XattrFS = &vfs.ReadOnlyFS{}
+
+var _ vfs.Statfser = &vfs.ReadOnlyFS{}
+
+var _ vfs.Lchmoder = &vfs.ReadOnlyFS{}
+
+var _ vfs.Lchtimeser = &vfs.ReadOnlyFS{}
+
+var _ vfs.DefaultTempDirer = &vfs.ReadOnlyFS{}
@@ -0,0 +1,339 @@
+package remotefs
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// A ClientOption sets an option on a Client.
+type ClientOption func(*Client)
+
+// A Client implements github.com/twpayne/go-vfs.FS by making requests to a
+// Server. Create, OpenFile, and RawPath are not supported because they return
+// local files or paths. Errors wrap fs.ErrNotExist, fs.ErrPermission,
+// fs.ErrExist, fs.ErrInvalid, or errors.ErrUnsupported as they did on the
+// Server.
+type Client struct {
+	baseURL     string
+	httpClient  *http.Client
+	requestHook func(*http.Request) error
+}
+
+// A remoteFile is a file whose contents are streamed from a Server.
+type remoteFile struct {
+	body io.ReadCloser
+	info *fileInfo
+}
+
+// ClientHTTPClient sets the *http.Client used to make requests. The default
+// is http.DefaultClient.
+func ClientHTTPClient(httpClient *http.Client) ClientOption {
+	return func(c *Client) {
+		c.httpClient = httpClient
+	}
+}
+
+// ClientRequestHook sets a function that is called with each request before
+// it is sent, typically to add authentication headers. If it returns an error
+// then the request is not sent and the error is returned.
+func ClientRequestHook(requestHook func(*http.Request) error) ClientOption {
+	return func(c *Client) {
+		c.requestHook = requestHook
+	}
+}
+
+// NewClient returns a new *Client that makes requests to the Server at
+// baseURL with the given options set.
+func NewClient(baseURL string, options ...ClientOption) *Client {
+	c := &Client{
+		baseURL:    strings.TrimSuffix(baseURL, "/"),
+		httpClient: http.DefaultClient,
+	}
+	for _, option := range options {
+		option(c)
+	}
+	return c
+}
+
+// Chmod implements os.Chmod.
+func (c *Client) Chmod(name string, mode fs.FileMode) error {
+	_, err := c.call("Chmod", &request{Name: name, Mode: mode})
+	return err
+}
+
+// Chown implements os.Chown.
+func (c *Client) Chown(name string, uid, gid int) error {
+	_, err := c.call("Chown", &request{Name: name, UID: uid, GID: gid})
+	return err
+}
+
+// Chtimes implements os.Chtimes.
+func (c *Client) Chtimes(name string, atime, mtime time.Time) error {
+	_, err := c.call("Chtimes", &request{Name: name, Atime: atime, Mtime: mtime})
+	return err
+}
+
+// Create implements os.Create. It is not supported.
+func (c *Client) Create(name string) (*os.File, error) {
+	return nil, &os.PathError{Op: "Create", Path: name, Err: errors.ErrUnsupported}
+}
+
+// Glob implements filepath.Glob.
+func (c *Client) Glob(pattern string) ([]string, error) {
+	resp, err := c.call("Glob", &request{Pattern: pattern})
+	if err != nil {
+		return nil, err
+	}
+	return resp.Matches, nil
+}
+
+// Lchown implements os.Lchown.
+func (c *Client) Lchown(name string, uid, gid int) error {
+	_, err := c.call("Lchown", &request{Name: name, UID: uid, GID: gid})
+	return err
+}
+
+// Link implements os.Link.
+func (c *Client) Link(oldname, newname string) error {
+	_, err := c.call("Link", &request{Name: oldname, NewName: newname})
+	return err
+}
+
+// Lstat implements os.Lstat.
+func (c *Client) Lstat(name string) (fs.FileInfo, error) {
+	return c.stat("Lstat", name)
+}
+
+// Mkdir implements os.Mkdir.
+func (c *Client) Mkdir(name string, perm fs.FileMode) error {
+	_, err := c.call("Mkdir", &request{Name: name, Mode: perm})
+	return err
+}
+
+// Open implements os.Open. The returned file's contents are streamed from the
+// Server as they are read, so it must be closed. Directories cannot be opened.
+func (c *Client) Open(name string) (fs.File, error) {
+	req, err := c.newRequest(http.MethodGet, "ReadFile", url.Values{"name": {name}}, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := c.do(req)
+	if err != nil {
+		return nil, err
+	}
+	var info fileInfo
+	if err := json.Unmarshal([]byte(resp.Header.Get(fileInfoHeader)), &info); err != nil {
+		resp.Body.Close()
+		return nil, &os.PathError{Op: "open", Path: name, Err: err}
+	}
+	return &remoteFile{
+		body: resp.Body,
+		info: &info,
+	}, nil
+}
+
+// OpenFile implements os.OpenFile. It is not supported.
+func (c *Client) OpenFile(name string, flag int, perm fs.FileMode) (*os.File, error) {
+	return nil, &os.PathError{Op: "OpenFile", Path: name, Err: errors.ErrUnsupported}
+}
+
+// PathSeparator returns '/'.
+func (c *Client) PathSeparator() rune {
+	return '/'
+}
+
+// RawPath implements RawPath. It is not supported.
+func (c *Client) RawPath(path string) (string, error) {
+	return "", &os.PathError{Op: "RawPath", Path: path, Err: errors.ErrUnsupported}
+}
+
+// ReadDir implements os.ReadDir.
+func (c *Client) ReadDir(name string) ([]fs.DirEntry, error) {
+	resp, err := c.call("ReadDir", &request{Name: name})
+	if err != nil {
+		return nil, err
+	}
+	dirEntries := make([]fs.DirEntry, 0, len(resp.DirEntries))
+	for _, dirEntry := range resp.DirEntries {
+		dirEntries = append(dirEntries, dirEntry)
+	}
+	return dirEntries, nil
+}
+
+// ReadFile implements os.ReadFile.
+func (c *Client) ReadFile(name string) ([]byte, error) {
+	file, err := c.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	return io.ReadAll(file)
+}
+
+// Readlink implements os.Readlink.
+func (c *Client) Readlink(name string) (string, error) {
+	resp, err := c.call("Readlink", &request{Name: name})
+	if err != nil {
+		return "", err
+	}
+	return resp.Target, nil
+}
+
+// Remove implements os.Remove.
+func (c *Client) Remove(name string) error {
+	_, err := c.call("Remove", &request{Name: name})
+	return err
+}
+
+// RemoveAll implements os.RemoveAll.
+func (c *Client) RemoveAll(name string) error {
+	_, err := c.call("RemoveAll", &request{Name: name})
+	return err
+}
+
+// Rename implements os.Rename.
+func (c *Client) Rename(oldpath, newpath string) error {
+	_, err := c.call("Rename", &request{Name: oldpath, NewName: newpath})
+	return err
+}
+
+// Stat implements os.Stat.
+func (c *Client) Stat(name string) (fs.FileInfo, error) {
+	return c.stat("Stat", name)
+}
+
+// Symlink implements os.Symlink.
+func (c *Client) Symlink(oldname, newname string) error {
+	_, err := c.call("Symlink", &request{Name: oldname, NewName: newname})
+	return err
+}
+
+// Truncate implements os.Truncate.
+func (c *Client) Truncate(name string, size int64) error {
+	_, err := c.call("Truncate", &request{Name: name, Size: size})
+	return err
+}
+
+// WriteFile implements os.WriteFile.
+func (c *Client) WriteFile(name string, data []byte, perm fs.FileMode) error {
+	return c.WriteFileFrom(name, bytes.NewReader(data), perm)
+}
+
+// WriteFileFrom writes the contents of r to name, creating it with perm if
+// necessary. The contents are streamed to the Server.
+func (c *Client) WriteFileFrom(name string, r io.Reader, perm fs.FileMode) error {
+	query := url.Values{
+		"name": {name},
+		"perm": {strconv.FormatUint(uint64(perm.Perm()), 8)},
+	}
+	req, err := c.newRequest(http.MethodPut, "WriteFile", query, r)
+	if err != nil {
+		return err
+	}
+	resp, err := c.do(req)
+	if err != nil {
+		return err
+	}
+	return resp.Body.Close()
+}
+
+// call calls method on the Server with req and returns its response.
+func (c *Client) call(method string, req *request) (*response, error) {
+	body, err := json.Marshal(req)
+	if err != nil {
+		return nil, err
+	}
+	httpRequest, err := c.newRequest(http.MethodPost, method, nil, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	httpRequest.Header.Set("Content-Type", "application/json")
+	httpResponse, err := c.do(httpRequest)
+	if err != nil {
+		return nil, err
+	}
+	defer httpResponse.Body.Close()
+	var resp response
+	if err := json.NewDecoder(httpResponse.Body).Decode(&resp); err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
+
+// do sends req and returns its response if it was successful. Otherwise, it
+// returns the error from the response.
+func (c *Client) do(req *http.Request) (*http.Response, error) {
+	if c.requestHook != nil {
+		if err := c.requestHook(req); err != nil {
+			return nil, err
+		}
+	}
+	httpResponse, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if httpResponse.StatusCode == http.StatusOK {
+		return httpResponse, nil
+	}
+	defer httpResponse.Body.Close()
+	var resp response
+	if err := json.NewDecoder(httpResponse.Body).Decode(&resp); err == nil && resp.Error != nil {
+		return nil, resp.Error.err()
+	}
+	statusError := &remoteError{
+		message: httpResponse.Status,
+	}
+	if httpResponse.StatusCode == http.StatusUnauthorized {
+		statusError.kind = fs.ErrPermission
+	}
+	for _, errorKind := range errorKinds {
+		if httpResponse.StatusCode == errorKind.statusCode {
+			statusError.kind = errorKind.err
+			break
+		}
+	}
+	return nil, fmt.Errorf("%s: %w", req.URL.Path, statusError)
+}
+
+// newRequest returns a new request for method.
+func (c *Client) newRequest(httpMethod, method string, query url.Values, body io.Reader) (*http.Request, error) {
+	rawURL := c.baseURL + "/" + method
+	if query != nil {
+		rawURL += "?" + query.Encode()
+	}
+	return http.NewRequest(httpMethod, rawURL, body)
+}
+
+// stat calls method, which is either Lstat or Stat, on name.
+func (c *Client) stat(method, name string) (fs.FileInfo, error) {
+	resp, err := c.call(method, &request{Name: name})
+	if err != nil {
+		return nil, err
+	}
+	return resp.FileInfo, nil
+}
+
+// Close implements fs.File.Close.
+func (f *remoteFile) Close() error {
+	return f.body.Close()
+}
+
+// Read implements fs.File.Read.
+func (f *remoteFile) Read(p []byte) (int, error) {
+	return f.body.Read(p)
+}
+
+// Stat implements fs.File.Stat.
+func (f *remoteFile) Stat() (fs.FileInfo, error) {
+	return f.info, nil
+}
@@ -0,0 +1,183 @@
+// Package remotefs exports a github.com/twpayne/go-vfs FS over HTTP with a
+// Server, and accesses it from another process with a Client.
+//
+// File contents are streamed in the bodies of GET and PUT requests to
+// /ReadFile and /WriteFile. All other methods are POST requests to /Method
+// with JSON request and response bodies. Errors are returned as JSON with an
+// HTTP status code reflecting their kind, and are mapped back to errors that
+// wrap fs.ErrNotExist, fs.ErrPermission, fs.ErrExist, fs.ErrInvalid, or
+// errors.ErrUnsupported.
+package remotefs
+
+import (
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"net/http"
+	"os"
+	"time"
+)
+
+// fileInfoHeader is the HTTP header containing the JSON-encoded fileInfo of a
+// file returned by ReadFile.
+const fileInfoHeader = "X-Remotefs-File-Info"
+
+// Error kinds.
+const (
+	errorKindExist       = "exist"
+	errorKindInvalid     = "invalid"
+	errorKindNotExist    = "notexist"
+	errorKindPermission  = "permission"
+	errorKindUnsupported = "unsupported"
+)
+
+// errorKinds maps error kinds to their errors and HTTP status codes.
+var errorKinds = []struct {
+	kind       string
+	err        error
+	statusCode int
+}{
+	{errorKindNotExist, fs.ErrNotExist, http.StatusNotFound},
+	{errorKindPermission, fs.ErrPermission, http.StatusForbidden},
+	{errorKindExist, fs.ErrExist, http.StatusConflict},
+	{errorKindInvalid, fs.ErrInvalid, http.StatusBadRequest},
+	{errorKindUnsupported, errors.ErrUnsupported, http.StatusNotImplemented},
+}
+
+// A request is the JSON body of a request.
+type request struct {
+	Name    string      `json:"name,omitempty"`
+	NewName string      `json:"newName,omitempty"`
+	Pattern string      `json:"pattern,omitempty"`
+	Mode    fs.FileMode `json:"mode,omitempty"`
+	UID     int         `json:"uid,omitempty"`
+	GID     int         `json:"gid,omitempty"`
+	Atime   time.Time   `json:"atime,omitempty"`
+	Mtime   time.Time   `json:"mtime,omitempty"`
+	Size    int64       `json:"size,omitempty"`
+}
+
+// A response is the JSON body of a response.
+type response struct {
+	Error      *errorResponse `json:"error,omitempty"`
+	FileInfo   *fileInfo      `json:"fileInfo,omitempty"`
+	DirEntries []*fileInfo    `json:"dirEntries,omitempty"`
+	Matches    []string       `json:"matches,omitempty"`
+	Target     string         `json:"target,omitempty"`
+}
+
+// An errorResponse is an error in a response.
+type errorResponse struct {
+	Op      string `json:"op,omitempty"`
+	Path    string `json:"path,omitempty"`
+	NewPath string `json:"newPath,omitempty"`
+	Kind    string `json:"kind,omitempty"`
+	Message string `json:"message"`
+}
+
+// A fileInfo is a JSON-encodable fs.FileInfo, which also implements
+// fs.DirEntry.
+type fileInfo struct {
+	FileName    string      `json:"name"`
+	FileSize    int64       `json:"size"`
+	FileMode    fs.FileMode `json:"mode"`
+	FileModTime time.Time   `json:"modTime"`
+}
+
+// A remoteError is an error returned by a Server.
+type remoteError struct {
+	message string
+	kind    error
+}
+
+// newFileInfo returns a new *fileInfo from info.
+func newFileInfo(info fs.FileInfo) *fileInfo {
+	return &fileInfo{
+		FileName:    info.Name(),
+		FileSize:    info.Size(),
+		FileMode:    info.Mode(),
+		FileModTime: info.ModTime(),
+	}
+}
+
+func (i *fileInfo) Info() (fs.FileInfo, error) { return i, nil }
+func (i *fileInfo) IsDir() bool                { return i.FileMode.IsDir() }
+func (i *fileInfo) ModTime() time.Time         { return i.FileModTime }
+func (i *fileInfo) Mode() fs.FileMode          { return i.FileMode }
+func (i *fileInfo) Name() string               { return i.FileName }
+func (i *fileInfo) Size() int64                { return i.FileSize }
+func (i *fileInfo) Sys() any                   { return nil }
+func (i *fileInfo) Type() fs.FileMode          { return i.FileMode.Type() }
+
+// newErrorResponse returns the errorResponse and HTTP status code for err.
+// The paths in the errorResponse are name and newName, as requested by the
+// client, rather than the paths in err, which may reveal the Server's
+// filesystem layout.
+func newErrorResponse(op, name, newName string, err error) (*errorResponse, int) {
+	errorResponse := &errorResponse{
+		Op:      op,
+		Path:    name,
+		NewPath: newName,
+		Message: err.Error(),
+	}
+	var pathError *os.PathError
+	var linkError *os.LinkError
+	switch {
+	case errors.As(err, &pathError):
+		errorResponse.Op = pathError.Op
+		errorResponse.Message = pathError.Err.Error()
+	case errors.As(err, &linkError):
+		errorResponse.Op = linkError.Op
+		errorResponse.Message = linkError.Err.Error()
+	}
+	for _, errorKind := range errorKinds {
+		if errors.Is(err, errorKind.err) {
+			errorResponse.Kind = errorKind.kind
+			return errorResponse, errorKind.statusCode
+		}
+	}
+	return errorResponse, http.StatusInternalServerError
+}
+
+// err returns the error represented by e.
+func (e *errorResponse) err() error {
+	err := &remoteError{
+		message: e.Message,
+	}
+	for _, errorKind := range errorKinds {
+		if e.Kind == errorKind.kind {
+			err.kind = errorKind.err
+			break
+		}
+	}
+	if e.NewPath != "" {
+		return &os.LinkError{
+			Op:  e.Op,
+			Old: e.Path,
+			New: e.NewPath,
+			Err: err,
+		}
+	}
+	return &os.PathError{
+		Op:   e.Op,
+		Path: e.Path,
+		Err:  err,
+	}
+}
+
+// Error implements error.Error.
+func (e *remoteError) Error() string {
+	return e.message
+}
+
+// Unwrap returns the kind of e, if known.
+func (e *remoteError) Unwrap() error {
+	return e.kind
+}
+
+// writeJSON writes resp to w with statusCode.
+func writeJSON(w http.ResponseWriter, statusCode int, resp *response) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(statusCode)
+	_ = json.NewEncoder(w).Encode(resp)
+}
@@ -0,0 +1,267 @@
+package remotefs_test
+
+import (
+	"errors"
+	"io"
+	"io/fs"
+	"net/http"
+	"net/http/httptest"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alecthomas/assert/v2"
+
+	vfs "github.com/twpayne/go-vfs/v5"
+	"github.com/twpayne/go-vfs/v5/remotefs"
+	"github.com/twpayne/go-vfs/v5/vfst"
+)
+
+var (
+	_ vfs.FS       = &remotefs.Client{}
+	_ http.Handler = &remotefs.Server{}
+)
+
+func TestClient(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("symlinks are not supported on Windows")
+	}
+
+	fileSystem := vfst.NewTestFSWithT(t, map[string]any{
+		"/root": map[string]any{
+			"dir": map[string]any{
+				"file1": "one\n",
+				"file2": "two\n",
+			},
+			"file":    "contents\n",
+			"symlink": &vfst.Symlink{Target: "file"},
+		},
+		"/outside": "outside\n",
+	})
+	server := httptest.NewServer(remotefs.NewServer(vfs.NewPathFS(fileSystem, "/root")))
+	defer server.Close()
+	client := remotefs.NewClient(server.URL, remotefs.ClientHTTPClient(server.Client()))
+
+	t.Run("read", func(t *testing.T) {
+		data, err := client.ReadFile("/file")
+		assert.NoError(t, err)
+		assert.Equal(t, "contents\n", string(data))
+
+		file, err := client.Open("/dir/file1")
+		assert.NoError(t, err)
+		info, err := file.Stat()
+		assert.NoError(t, err)
+		assert.Equal(t, "file1", info.Name())
+		assert.Equal(t, int64(4), info.Size())
+		data, err = io.ReadAll(file)
+		assert.NoError(t, err)
+		assert.Equal(t, "one\n", string(data))
+		assert.NoError(t, file.Close())
+
+		info, err = client.Stat("/dir")
+		assert.NoError(t, err)
+		assert.True(t, info.IsDir())
+		info, err = client.Lstat("/symlink")
+		assert.NoError(t, err)
+		assert.Equal(t, fs.ModeSymlink, info.Mode().Type())
+		target, err := client.Readlink("/symlink")
+		assert.NoError(t, err)
+		assert.Equal(t, "file", target)
+
+		dirEntries, err := client.ReadDir("/dir")
+		assert.NoError(t, err)
+		names := make([]string, 0, len(dirEntries))
+		for _, dirEntry := range dirEntries {
+			names = append(names, dirEntry.Name())
+		}
+		assert.Equal(t, []string{"file1", "file2"}, names)
+
+		matches, err := client.Glob("/dir/*")
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"/dir/file1", "/dir/file2"}, matches)
+
+		_, err = client.ReadFile("/../outside")
+		assert.True(t, errors.Is(err, fs.ErrNotExist))
+	})
+
+	t.Run("write", func(t *testing.T) {
+		modTime := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+		assert.NoError(t, client.WriteFile("/new", []byte("new\n"), 0o644))
+		assert.NoError(t, client.WriteFileFrom("/streamed", strings.NewReader("streamed\n"), 0o600))
+		assert.NoError(t, client.Mkdir("/newdir", 0o755))
+		assert.NoError(t, client.Chmod("/newdir", 0o700))
+		assert.NoError(t, client.Chtimes("/new", modTime, modTime))
+		assert.NoError(t, client.Truncate("/file", 4))
+		assert.NoError(t, client.Rename("/dir/file2", "/newdir/file2"))
+		assert.NoError(t, client.Remove("/dir/file1"))
+		assert.NoError(t, client.Symlink("new", "/newsymlink"))
+		assert.NoError(t, client.Link("/new", "/newlink"))
+
+		info, err := client.Stat("/new")
+		assert.NoError(t, err)
+		assert.True(t, info.ModTime().Equal(modTime))
+
+		vfst.RunTests(t, fileSystem, "",
+			vfst.TestPath("/root/new",
+				vfst.TestModePerm(0o644),
+				vfst.TestContentsString("new\n"),
+			),
+			vfst.TestPath("/root/streamed",
+				vfst.TestModePerm(0o600),
+				vfst.TestContentsString("streamed\n"),
+			),
+			vfst.TestPath("/root/newdir",
+				vfst.TestIsDir(),
+				vfst.TestModePerm(0o700),
+			),
+			vfst.TestPath("/root/file",
+				vfst.TestContentsString("cont"),
+			),
+			vfst.TestPath("/root/newdir/file2",
+				vfst.TestContentsString("two\n"),
+			),
+			vfst.TestPath("/root/dir/file1",
+				vfst.TestDoesNotExist(),
+			),
+			vfst.TestPath("/root/newsymlink",
+				vfst.TestSymlinkTarget("new"),
+			),
+			vfst.TestPath("/root/newlink",
+				vfst.TestContentsString("new\n"),
+			),
+		)
+
+		assert.NoError(t, client.RemoveAll("/newdir"))
+		vfst.RunTests(t, fileSystem, "",
+			vfst.TestPath("/root/newdir",
+				vfst.TestDoesNotExist(),
+			),
+		)
+	})
+
+	t.Run("errors", func(t *testing.T) {
+		_, err := client.Stat("/missing")
+		assert.True(t, errors.Is(err, fs.ErrNotExist))
+		var pathError *fs.PathError
+		assert.True(t, errors.As(err, &pathError))
+		assert.Equal(t, "/missing", pathError.Path)
+
+		_, err = client.Open("/missing")
+		assert.True(t, errors.Is(err, fs.ErrNotExist))
+		assert.True(t, errors.Is(client.Mkdir("/dir", 0o755), fs.ErrExist))
+		assert.True(t, errors.Is(client.Rename("/missing", "/other"), fs.ErrNotExist))
+
+		_, err = client.Create("/created")
+		assert.True(t, errors.Is(err, errors.ErrUnsupported))
+		_, err = client.OpenFile("/created", 0, 0)
+		assert.True(t, errors.Is(err, errors.ErrUnsupported))
+		_, err = client.RawPath("/file")
+		assert.True(t, errors.Is(err, errors.ErrUnsupported))
+	})
+}
+
+func TestInterruptedWriteFile(t *testing.T) {
+	fileSystem := vfst.NewTestFSWithT(t, map[string]any{
+		"/root/file": "contents\n",
+	})
+	server := remotefs.NewServer(vfs.NewPathFS(fileSystem, "/root"))
+
+	errRead := errors.New("read error")
+	body := io.MultiReader(strings.NewReader("partial"), &errorReader{err: errRead})
+	req := httptest.NewRequest(http.MethodPut, "/WriteFile?name=%2Ffile&perm=644", body)
+	recorder := httptest.NewRecorder()
+	server.ServeHTTP(recorder, req)
+	assert.NotEqual(t, http.StatusOK, recorder.Code)
+
+	vfst.RunTests(t, fileSystem, "",
+		vfst.TestPath("/root/file",
+			vfst.TestContentsString("contents\n"),
+		),
+	)
+	dirEntries, err := fileSystem.ReadDir("/root")
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(dirEntries))
+}
+
+func TestClientReadOnly(t *testing.T) {
+	fileSystem := vfst.NewTestFSWithT(t, map[string]any{
+		"/root/file": "contents\n",
+	})
+	server := httptest.NewServer(remotefs.NewServer(vfs.NewReadOnlyFS(vfs.NewPathFS(fileSystem, "/root"))))
+	defer server.Close()
+	client := remotefs.NewClient(server.URL, remotefs.ClientHTTPClient(server.Client()))
+
+	data, err := client.ReadFile("/file")
+	assert.NoError(t, err)
+	assert.Equal(t, "contents\n", string(data))
+
+	assert.True(t, errors.Is(client.WriteFile("/file", []byte("new\n"), 0o644), fs.ErrPermission))
+	assert.True(t, errors.Is(client.Remove("/file"), fs.ErrPermission))
+	assert.True(t, errors.Is(client.Chmod("/file", 0o600), fs.ErrPermission))
+
+	vfst.RunTests(t, fileSystem, "",
+		vfst.TestPath("/root/file",
+			vfst.TestContentsString("contents\n"),
+		),
+	)
+}
+
+func TestAuthentication(t *testing.T) {
+	fileSystem := vfst.NewTestFSWithT(t, map[string]any{
+		"/root/file": "contents\n",
+	})
+	server := httptest.NewServer(http.StripPrefix("/fs", remotefs.NewServer(vfs.NewPathFS(fileSystem, "/root"),
+		remotefs.ServerAuthenticate(func(r *http.Request) error {
+			if r.Header.Get("Authorization") != "Bearer token" {
+				return errors.New("invalid token")
+			}
+			return nil
+		}),
+	)))
+	defer server.Close()
+
+	authenticatedClient := remotefs.NewClient(server.URL+"/fs/",
+		remotefs.ClientHTTPClient(server.Client()),
+		remotefs.ClientRequestHook(func(r *http.Request) error {
+			r.Header.Set("Authorization", "Bearer token")
+			return nil
+		}),
+	)
+	data, err := authenticatedClient.ReadFile("/file")
+	assert.NoError(t, err)
+	assert.Equal(t, "contents\n", string(data))
+	assert.NoError(t, authenticatedClient.WriteFile("/file", []byte("new\n"), 0o644))
+
+	unauthenticatedClient := remotefs.NewClient(server.URL+"/fs", remotefs.ClientHTTPClient(server.Client()))
+	_, err = unauthenticatedClient.ReadFile("/file")
+	assert.True(t, errors.Is(err, fs.ErrPermission))
+	_, err = unauthenticatedClient.Stat("/file")
+	assert.True(t, errors.Is(err, fs.ErrPermission))
+	assert.True(t, errors.Is(unauthenticatedClient.Remove("/file"), fs.ErrPermission))
+
+	hookErr := errors.New("hook")
+	failingClient := remotefs.NewClient(server.URL+"/fs",
+		remotefs.ClientHTTPClient(server.Client()),
+		remotefs.ClientRequestHook(func(r *http.Request) error {
+			return hookErr
+		}),
+	)
+	_, err = failingClient.Stat("/file")
+	assert.True(t, errors.Is(err, hookErr))
+
+	vfst.RunTests(t, fileSystem, "",
+		vfst.TestPath("/root/file",
+			vfst.TestContentsString("new\n"),
+		),
+	)
+}
+
+// An errorReader is an io.Reader that always returns err.
+type errorReader struct {
+	err error
+}
+
+func (r *errorReader) Read([]byte) (int, error) {
+	return 0, r.err
+}
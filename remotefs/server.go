@@ -0,0 +1,263 @@
+package remotefs
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"io/fs"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+
+	vfs "github.com/twpayne/go-vfs/v5"
+)
+
+// maxRequestSize is the maximum size of a JSON request body.
+const maxRequestSize = 1 << 20
+
+// A ServerOption sets an option on a Server.
+type ServerOption func(*Server)
+
+// A Server is an http.Handler that exports an existing vfs.FS. Names are
+// cleaned as absolute paths, so that they cannot refer to parents of the root,
+// and passed to the vfs.FS, so the vfs.FS is typically a *vfs.PathFS, possibly
+// wrapped in a *vfs.ReadOnlyFS.
+type Server struct {
+	fileSystem   vfs.FS
+	authenticate func(*http.Request) error
+}
+
+// ServerAuthenticate sets a function that authenticates each request before
+// it is handled. If it returns an error then the request is rejected with 401
+// Unauthorized, which Clients report as fs.ErrPermission.
+func ServerAuthenticate(authenticate func(*http.Request) error) ServerOption {
+	return func(s *Server) {
+		s.authenticate = authenticate
+	}
+}
+
+// NewServer returns a new *Server exporting fileSystem with the given options
+// set.
+func NewServer(fileSystem vfs.FS, options ...ServerOption) *Server {
+	s := &Server{
+		fileSystem: fileSystem,
+	}
+	for _, option := range options {
+		option(s)
+	}
+	return s
+}
+
+// ServeHTTP implements http.Handler.ServeHTTP. The method is taken from the
+// last element of the request's path, so s can be served at any prefix.
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	method := path.Base(r.URL.Path)
+	if s.authenticate != nil {
+		if err := s.authenticate(r); err != nil {
+			writeJSON(w, http.StatusUnauthorized, &response{
+				Error: &errorResponse{
+					Op:      method,
+					Kind:    errorKindPermission,
+					Message: err.Error(),
+				},
+			})
+			return
+		}
+	}
+
+	switch {
+	case method == "ReadFile" && r.Method == http.MethodGet:
+		s.serveReadFile(w, r)
+	case method == "WriteFile" && r.Method == http.MethodPut:
+		s.serveWriteFile(w, r)
+	case r.Method == http.MethodPost:
+		s.serveMethod(w, r, method)
+	default:
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+	}
+}
+
+// serveMethod serves a JSON request for method.
+func (s *Server) serveMethod(w http.ResponseWriter, r *http.Request, method string) {
+	var req request
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxRequestSize)).Decode(&req); err != nil {
+		s.writeError(w, method, "", "", &os.PathError{Op: method, Err: fs.ErrInvalid})
+		return
+	}
+	req.NewName = clean(req.NewName)
+	if method != "Symlink" {
+		req.Name = clean(req.Name)
+	}
+	req.Pattern = clean(req.Pattern)
+
+	var resp response
+	var err error
+	switch method {
+	case "Chmod":
+		err = s.fileSystem.Chmod(req.Name, req.Mode)
+	case "Chown":
+		err = s.fileSystem.Chown(req.Name, req.UID, req.GID)
+	case "Chtimes":
+		err = s.fileSystem.Chtimes(req.Name, req.Atime, req.Mtime)
+	case "Glob":
+		resp.Matches, err = s.fileSystem.Glob(req.Pattern)
+	case "Lchown":
+		err = s.fileSystem.Lchown(req.Name, req.UID, req.GID)
+	case "Link":
+		err = s.fileSystem.Link(req.Name, req.NewName)
+	case "Lstat", "Stat":
+		var info fs.FileInfo
+		if method == "Lstat" {
+			info, err = s.fileSystem.Lstat(req.Name)
+		} else {
+			info, err = s.fileSystem.Stat(req.Name)
+		}
+		if err == nil {
+			resp.FileInfo = newFileInfo(info)
+		}
+	case "Mkdir":
+		err = s.fileSystem.Mkdir(req.Name, req.Mode)
+	case "ReadDir":
+		var dirEntries []fs.DirEntry
+		dirEntries, err = s.fileSystem.ReadDir(req.Name)
+		for _, dirEntry := range dirEntries {
+			var info fs.FileInfo
+			if info, err = dirEntry.Info(); err != nil {
+				break
+			}
+			resp.DirEntries = append(resp.DirEntries, newFileInfo(info))
+		}
+	case "Readlink":
+		resp.Target, err = s.fileSystem.Readlink(req.Name)
+	case "Remove":
+		err = s.fileSystem.Remove(req.Name)
+	case "RemoveAll":
+		err = s.fileSystem.RemoveAll(req.Name)
+	case "Rename":
+		err = s.fileSystem.Rename(req.Name, req.NewName)
+	case "Symlink":
+		err = s.fileSystem.Symlink(req.Name, req.NewName)
+	case "Truncate":
+		err = s.fileSystem.Truncate(req.Name, req.Size)
+	default:
+		err = &os.PathError{Op: method, Path: req.Name, Err: errors.ErrUnsupported}
+	}
+	if err != nil {
+		s.writeError(w, method, req.Name, req.NewName, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, &resp)
+}
+
+// serveReadFile streams the contents of a file.
+func (s *Server) serveReadFile(w http.ResponseWriter, r *http.Request) {
+	name := clean(r.URL.Query().Get("name"))
+	file, err := s.fileSystem.Open(name)
+	if err != nil {
+		s.writeError(w, "open", name, "", err)
+		return
+	}
+	defer file.Close()
+	info, err := file.Stat()
+	if err != nil {
+		s.writeError(w, "stat", name, "", err)
+		return
+	}
+	if info.IsDir() {
+		s.writeError(w, "read", name, "", &os.PathError{Op: "read", Path: name, Err: fs.ErrInvalid})
+		return
+	}
+	fileInfoJSON, err := json.Marshal(newFileInfo(info))
+	if err != nil {
+		s.writeError(w, "stat", name, "", err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/octet-stream")
+	w.Header().Set(fileInfoHeader, string(fileInfoJSON))
+	if info.Mode().IsRegular() {
+		w.Header().Set("Content-Length", strconv.FormatInt(info.Size(), 10))
+	}
+	w.WriteHeader(http.StatusOK)
+	_, _ = io.Copy(w, file)
+}
+
+// serveWriteFile writes the streamed contents of a file. If the vfs.FS
+// supports OpenFile then the contents are streamed to a temporary file in the
+// same directory, which is renamed over the file only once all the contents
+// have been received, so an interrupted write leaves any existing file
+// unchanged. As with os.WriteFile, an existing file keeps its permissions.
+// Otherwise, the contents are read into memory and written with WriteFile.
+func (s *Server) serveWriteFile(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	name := clean(query.Get("name"))
+	perm, err := strconv.ParseUint(query.Get("perm"), 8, 32)
+	if err != nil {
+		s.writeError(w, "WriteFile", name, "", &os.PathError{Op: "WriteFile", Path: name, Err: fs.ErrInvalid})
+		return
+	}
+
+	tempFile, err := vfs.CreateTemp(s.fileSystem, path.Dir(name), "."+path.Base(name)+".*.tmp")
+	switch {
+	case errors.Is(err, errors.ErrUnsupported):
+		var data []byte
+		if data, err = io.ReadAll(r.Body); err == nil {
+			err = s.fileSystem.WriteFile(name, data, fs.FileMode(perm))
+		}
+	case err == nil:
+		err = s.writeTempFile(tempFile, name, r.Body, fs.FileMode(perm))
+	}
+	if err != nil {
+		s.writeError(w, "WriteFile", name, "", err)
+		return
+	}
+	writeJSON(w, http.StatusOK, &response{})
+}
+
+// writeError writes err to w.
+func (s *Server) writeError(w http.ResponseWriter, op, name, newName string, err error) {
+	errorResponse, statusCode := newErrorResponse(op, name, newName, err)
+	writeJSON(w, statusCode, &response{
+		Error: errorResponse,
+	})
+}
+
+// writeTempFile copies r to tempFile, a temporary file in the same directory
+// as name, and renames it to name. tempFile is removed if any step fails.
+func (s *Server) writeTempFile(tempFile *os.File, name string, r io.Reader, perm fs.FileMode) error {
+	// The Name method of tempFile returns the name in the underlying operating
+	// system filesystem, so use only its base name.
+	tempName := path.Join(path.Dir(name), filepath.Base(tempFile.Name()))
+	_, err := io.Copy(tempFile, r)
+	if closeErr := tempFile.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		switch info, statErr := s.fileSystem.Stat(name); {
+		case statErr == nil:
+			perm = info.Mode().Perm()
+		case !errors.Is(statErr, fs.ErrNotExist):
+			err = statErr
+		}
+	}
+	if err == nil {
+		err = s.fileSystem.Chmod(tempName, perm)
+	}
+	if err == nil {
+		err = s.fileSystem.Rename(tempName, name)
+	}
+	if err != nil {
+		_ = s.fileSystem.Remove(tempName)
+	}
+	return err
+}
+
+// clean returns name as a clean absolute path, or the empty string if name is
+// empty.
+func clean(name string) string {
+	if name == "" {
+		return ""
+	}
+	return path.Clean("/" + name)
+}
@@ -0,0 +1,32 @@
+package vfs
+
+// An FSStat contains statistics about a filesystem.
+type FSStat struct {
+	// Type is the name of the filesystem type, for example "ext4", or the
+	// empty string if it is not known.
+	Type string
+	// TypeID is the platform-specific identifier of the filesystem type.
+	TypeID uint64
+	// BlockSize is the fundamental block size in bytes.
+	BlockSize uint64
+	// TotalBytes is the total size of the filesystem in bytes.
+	TotalBytes uint64
+	// FreeBytes is the number of free bytes.
+	FreeBytes uint64
+	// AvailableBytes is the number of bytes available to unprivileged users.
+	AvailableBytes uint64
+	// TotalInodes is the total number of inodes.
+	TotalInodes uint64
+	// FreeInodes is the number of free inodes.
+	FreeInodes uint64
+	// Flags are the platform-specific mount flags.
+	Flags uint64
+	// ReadOnly is true if the filesystem is mounted read-only.
+	ReadOnly bool
+}
+
+// A Statfser is an FS that can return statistics about the filesystem
+// containing a path.
+type Statfser interface {
+	Statfs(name string) (*FSStat, error)
+}
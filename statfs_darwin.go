@@ -0,0 +1,32 @@
+package vfs
+
+import (
+	"os"
+
+	"golang.org/x/sys/unix"
+)
+
+// Statfs implements Statfser.Statfs with statfs(2).
+func (osfs) Statfs(name string) (*FSStat, error) {
+	var statfs unix.Statfs_t
+	if err := unix.Statfs(name, &statfs); err != nil {
+		return nil, &os.PathError{
+			Op:   "Statfs",
+			Path: name,
+			Err:  err,
+		}
+	}
+	blockSize := uint64(statfs.Bsize)
+	return &FSStat{
+		Type:           unix.ByteSliceToString(statfs.Fstypename[:]),
+		TypeID:         uint64(statfs.Type),
+		BlockSize:      blockSize,
+		TotalBytes:     statfs.Blocks * blockSize,
+		FreeBytes:      statfs.Bfree * blockSize,
+		AvailableBytes: statfs.Bavail * blockSize,
+		TotalInodes:    statfs.Files,
+		FreeInodes:     statfs.Ffree,
+		Flags:          uint64(statfs.Flags),
+		ReadOnly:       statfs.Flags&unix.MNT_RDONLY != 0,
+	}, nil
+}
@@ -0,0 +1,50 @@
+package vfs
+
+import (
+	"os"
+
+	"golang.org/x/sys/unix"
+)
+
+// stRdonly is the ST_RDONLY mount flag.
+const stRdonly = 0x1
+
+//nolint:gochecknoglobals
+var linuxFSTypes = map[uint64]string{
+	unix.BTRFS_SUPER_MAGIC:     "btrfs",
+	unix.EXT4_SUPER_MAGIC:      "ext4",
+	unix.NFS_SUPER_MAGIC:       "nfs",
+	unix.OVERLAYFS_SUPER_MAGIC: "overlay",
+	unix.PROC_SUPER_MAGIC:      "proc",
+	unix.TMPFS_MAGIC:           "tmpfs",
+	unix.XFS_SUPER_MAGIC:       "xfs",
+}
+
+// Statfs implements Statfser.Statfs with statfs(2).
+func (osfs) Statfs(name string) (*FSStat, error) {
+	var statfs unix.Statfs_t
+	if err := unix.Statfs(name, &statfs); err != nil {
+		return nil, &os.PathError{
+			Op:   "Statfs",
+			Path: name,
+			Err:  err,
+		}
+	}
+	blockSize := uint64(statfs.Frsize) //nolint:gosec
+	if blockSize == 0 {
+		blockSize = uint64(statfs.Bsize) //nolint:gosec
+	}
+	typeID := uint64(statfs.Type) & 0xffffffff //nolint:gosec
+	return &FSStat{
+		Type:           linuxFSTypes[typeID],
+		TypeID:         typeID,
+		BlockSize:      blockSize,
+		TotalBytes:     uint64(statfs.Blocks) * blockSize,
+		FreeBytes:      uint64(statfs.Bfree) * blockSize,
+		AvailableBytes: uint64(statfs.Bavail) * blockSize,
+		TotalInodes:    uint64(statfs.Files),
+		FreeInodes:     uint64(statfs.Ffree),
+		Flags:          uint64(statfs.Flags), //nolint:gosec
+		ReadOnly:       statfs.Flags&stRdonly != 0,
+	}, nil
+}
@@ -0,0 +1,126 @@
+package vfs
+
+import (
+	"errors"
+	"io/fs"
+	"math/rand/v2"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// errPatternHasSeparator is returned when a temporary file or directory name
+// pattern contains a path separator.
+var errPatternHasSeparator = errors.New("pattern contains path separator")
+
+// A DefaultTempDirer is an FS that provides its own default directory for
+// temporary files.
+type DefaultTempDirer interface {
+	DefaultTempDir() string
+}
+
+// CreateTemp is like os.CreateTemp but creates the new file in fileSystem. If
+// dir is the empty string then TempDir(fileSystem) is used, and is created if
+// it does not exist. As with
+// fileSystem.Create, the Name method of the returned *os.File returns the name
+// of the file in the underlying operating system filesystem.
+func CreateTemp(fileSystem FS, dir, pattern string) (*os.File, error) {
+	prefix, suffix, err := splitTempPattern("CreateTemp", pattern)
+	if err != nil {
+		return nil, err
+	}
+	if dir == "" {
+		dir, err = defaultTempDir(fileSystem)
+		if err != nil {
+			return nil, err
+		}
+	}
+	for range 10000 {
+		name := filepath.Join(dir, prefix+nextRandom()+suffix)
+		f, err := fileSystem.OpenFile(name, os.O_RDWR|os.O_CREATE|os.O_EXCL, 0o600)
+		if errors.Is(err, fs.ErrExist) {
+			continue
+		}
+		return f, err
+	}
+	return nil, &os.PathError{
+		Op:   "CreateTemp",
+		Path: filepath.Join(dir, prefix+"*"+suffix),
+		Err:  fs.ErrExist,
+	}
+}
+
+// MkdirTemp is like os.MkdirTemp but creates the new directory in fileSystem.
+// If dir is the empty string then TempDir(fileSystem) is used, and is created
+// if it does not exist.
+func MkdirTemp(fileSystem FS, dir, pattern string) (string, error) {
+	prefix, suffix, err := splitTempPattern("MkdirTemp", pattern)
+	if err != nil {
+		return "", err
+	}
+	if dir == "" {
+		dir, err = defaultTempDir(fileSystem)
+		if err != nil {
+			return "", err
+		}
+	}
+	for range 10000 {
+		name := filepath.Join(dir, prefix+nextRandom()+suffix)
+		err := fileSystem.Mkdir(name, 0o700)
+		if errors.Is(err, fs.ErrExist) {
+			continue
+		}
+		if err != nil {
+			return "", err
+		}
+		return name, nil
+	}
+	return "", &os.PathError{
+		Op:   "MkdirTemp",
+		Path: filepath.Join(dir, prefix+"*"+suffix),
+		Err:  fs.ErrExist,
+	}
+}
+
+// TempDir returns the directory to use for temporary files in fileSystem. If
+// fileSystem implements DefaultTempDirer then its DefaultTempDir method is
+// used, otherwise os.TempDir is used.
+func TempDir(fileSystem FS) string {
+	if defaultTempDirer, ok := fileSystem.(DefaultTempDirer); ok {
+		return defaultTempDirer.DefaultTempDir()
+	}
+	return os.TempDir()
+}
+
+// defaultTempDir returns TempDir(fileSystem), creating it if needed.
+func defaultTempDir(fileSystem FS) (string, error) {
+	dir := TempDir(fileSystem)
+	if err := MkdirAll(fileSystem, dir, 0o700); err != nil {
+		return "", err
+	}
+	return dir, nil
+}
+
+// nextRandom returns a random string for use in temporary file names.
+func nextRandom() string {
+	return strconv.FormatUint(uint64(rand.Uint32()), 10) //nolint:gosec
+}
+
+// splitTempPattern splits pattern into the prefix and suffix around its last
+// "*", or returns pattern as the prefix if it does not contain a "*".
+func splitTempPattern(op, pattern string) (string, string, error) {
+	for i := range len(pattern) {
+		if os.IsPathSeparator(pattern[i]) || pattern[i] == '/' {
+			return "", "", &os.PathError{
+				Op:   op,
+				Path: pattern,
+				Err:  errPatternHasSeparator,
+			}
+		}
+	}
+	if i := strings.LastIndexByte(pattern, '*'); i != -1 {
+		return pattern[:i], pattern[i+1:], nil
+	}
+	return pattern, "", nil
+}
@@ -0,0 +1,369 @@
+package vfs
+
+import (
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strconv"
+	"syscall"
+)
+
+// errTransactionDone is returned when a Transaction is used after it has been
+// committed or rolled back.
+var errTransactionDone = errors.New("transaction already committed or rolled back")
+
+// A Transaction stages modifications to an FS and applies them together with
+// Commit. Before each modification is applied, Commit records how to undo it
+// in a journal. If any modification fails then the modifications already
+// applied are undone, restoring the prior state. If the process is interrupted
+// while the modifications are being applied then the journal remains, and
+// RecoverTransaction undoes them on the next start.
+//
+// Removed and overwritten files are kept in a backup directory next to the
+// journal until the transaction is complete, so the journal must be on the
+// same filesystem as the modified files. The journal is not synced to stable
+// storage, so a transaction interrupted by a system crash, rather than a
+// process exit, might not be recoverable.
+type Transaction struct {
+	fileSystem  FS
+	journalPath string
+	ops         []transactionOp
+	done        bool
+}
+
+// A transactionOp is a modification staged in a Transaction.
+type transactionOp struct {
+	op      string
+	name    string
+	newname string
+	data    []byte
+	perm    fs.FileMode
+}
+
+// A transactionUndo records how to undo a modification applied by a
+// Transaction.
+type transactionUndo struct {
+	// Op is the undo operation, one of "chmod", "remove", "rename", or
+	// "restore".
+	Op string `json:"op"`
+	// Path is the path to restore.
+	Path string `json:"path"`
+	// From is the path to rename to Path, or to restore Path's contents from.
+	From string `json:"from,omitempty"`
+	// Mode is the mode to restore.
+	Mode fs.FileMode `json:"mode,omitempty"`
+}
+
+// A transactionJournal is the journal of a Transaction being committed.
+type transactionJournal struct {
+	fileSystem  FS
+	journalPath string
+	undos       []transactionUndo
+	backups     int
+}
+
+// NewTransaction returns a new *Transaction that modifies fileSystem and keeps
+// its journal at journalPath.
+func NewTransaction(fileSystem FS, journalPath string) *Transaction {
+	return &Transaction{
+		fileSystem:  fileSystem,
+		journalPath: journalPath,
+	}
+}
+
+// RecoverTransaction undoes the modifications made by a Transaction with the
+// journal at journalPath that was interrupted while being committed. It
+// returns whether there was a transaction to recover.
+func RecoverTransaction(fileSystem FS, journalPath string) (bool, error) {
+	j := &transactionJournal{
+		fileSystem:  fileSystem,
+		journalPath: journalPath,
+	}
+	data, err := fileSystem.ReadFile(journalPath)
+	switch {
+	case errors.Is(err, fs.ErrNotExist):
+		// The transaction was either never started or completed, so any
+		// remaining backups are no longer needed.
+		return false, fileSystem.RemoveAll(j.backupDir())
+	case err != nil:
+		return false, err
+	}
+	if err := json.Unmarshal(data, &j.undos); err != nil {
+		return false, &os.PathError{Op: "RecoverTransaction", Path: journalPath, Err: err}
+	}
+	if err := j.undo(); err != nil {
+		return true, err
+	}
+	return true, nil
+}
+
+// Chmod stages changing the mode of name to mode.
+func (t *Transaction) Chmod(name string, mode fs.FileMode) {
+	t.ops = append(t.ops, transactionOp{op: "chmod", name: name, perm: mode})
+}
+
+// Commit applies the staged modifications. If any modification fails then the
+// modifications already applied are undone and the error is returned.
+func (t *Transaction) Commit() error {
+	if t.done {
+		return errTransactionDone
+	}
+	t.done = true
+
+	switch _, err := t.fileSystem.Lstat(t.journalPath); {
+	case err == nil:
+		return &os.PathError{Op: "Commit", Path: t.journalPath, Err: fs.ErrExist}
+	case !errors.Is(err, fs.ErrNotExist):
+		return err
+	}
+	j := &transactionJournal{
+		fileSystem:  t.fileSystem,
+		journalPath: t.journalPath,
+	}
+	// Remove any backups left by a previous transaction that was interrupted
+	// after it completed.
+	if err := t.fileSystem.RemoveAll(j.backupDir()); err != nil {
+		return err
+	}
+	if err := t.fileSystem.Mkdir(j.backupDir(), 0o700); err != nil {
+		return err
+	}
+	if err := j.save(); err != nil {
+		return errors.Join(err, t.fileSystem.RemoveAll(j.backupDir()))
+	}
+	for _, op := range t.ops {
+		if err := j.apply(op); err != nil {
+			return errors.Join(err, j.undo())
+		}
+	}
+	return j.finish()
+}
+
+// Mkdir stages creating the directory name with permissions perm.
+func (t *Transaction) Mkdir(name string, perm fs.FileMode) {
+	t.ops = append(t.ops, transactionOp{op: "mkdir", name: name, perm: perm})
+}
+
+// Remove stages removing name, which must be a file or an empty directory.
+func (t *Transaction) Remove(name string) {
+	t.ops = append(t.ops, transactionOp{op: "remove", name: name})
+}
+
+// RemoveAll stages removing name and everything below it.
+func (t *Transaction) RemoveAll(name string) {
+	t.ops = append(t.ops, transactionOp{op: "removeall", name: name})
+}
+
+// Rename stages renaming oldpath to newpath.
+func (t *Transaction) Rename(oldpath, newpath string) {
+	t.ops = append(t.ops, transactionOp{op: "rename", name: oldpath, newname: newpath})
+}
+
+// Rollback discards the staged modifications without applying them.
+func (t *Transaction) Rollback() error {
+	if t.done {
+		return errTransactionDone
+	}
+	t.done = true
+	t.ops = nil
+	return nil
+}
+
+// Symlink stages creating newname as a symbolic link to oldname.
+func (t *Transaction) Symlink(oldname, newname string) {
+	t.ops = append(t.ops, transactionOp{op: "symlink", name: oldname, newname: newname})
+}
+
+// WriteFile stages writing data to filename.
+func (t *Transaction) WriteFile(filename string, data []byte, perm fs.FileMode) {
+	t.ops = append(t.ops, transactionOp{op: "writefile", name: filename, data: data, perm: perm})
+}
+
+// apply records how to undo op in j and then applies it.
+func (j *transactionJournal) apply(op transactionOp) error {
+	switch op.op {
+	case "chmod":
+		info, err := j.fileSystem.Stat(op.name)
+		if err != nil {
+			return err
+		}
+		if err := j.record(transactionUndo{Op: "chmod", Path: op.name, Mode: info.Mode()}); err != nil {
+			return err
+		}
+		return j.fileSystem.Chmod(op.name, op.perm)
+
+	case "mkdir":
+		if err := j.recordCreate("mkdir", op.name); err != nil {
+			return err
+		}
+		return j.fileSystem.Mkdir(op.name, op.perm)
+
+	case "remove", "removeall":
+		info, err := j.fileSystem.Lstat(op.name)
+		switch {
+		case op.op == "removeall" && errors.Is(err, fs.ErrNotExist):
+			return nil
+		case err != nil:
+			return err
+		}
+		if op.op == "remove" && info.IsDir() {
+			dirEntries, err := j.fileSystem.ReadDir(op.name)
+			if err != nil {
+				return err
+			}
+			if len(dirEntries) != 0 {
+				return &os.PathError{Op: "remove", Path: op.name, Err: syscall.ENOTEMPTY}
+			}
+		}
+		return j.moveToBackup(op.name)
+
+	case "rename":
+		switch _, err := j.fileSystem.Lstat(op.newname); {
+		case err == nil:
+			if err := j.moveToBackup(op.newname); err != nil {
+				return err
+			}
+		case !errors.Is(err, fs.ErrNotExist):
+			return err
+		}
+		if err := j.record(transactionUndo{Op: "rename", Path: op.name, From: op.newname}); err != nil {
+			return err
+		}
+		return j.fileSystem.Rename(op.name, op.newname)
+
+	case "symlink":
+		if err := j.recordCreate("symlink", op.newname); err != nil {
+			return err
+		}
+		return j.fileSystem.Symlink(op.name, op.newname)
+
+	case "writefile":
+		info, err := j.fileSystem.Stat(op.name)
+		switch {
+		case errors.Is(err, fs.ErrNotExist):
+			if err := j.record(transactionUndo{Op: "remove", Path: op.name}); err != nil {
+				return err
+			}
+		case err != nil:
+			return err
+		case info.Mode().IsRegular():
+			data, err := j.fileSystem.ReadFile(op.name)
+			if err != nil {
+				return err
+			}
+			backupPath := j.nextBackupPath()
+			if err := j.fileSystem.WriteFile(backupPath, data, 0o600); err != nil {
+				return err
+			}
+			if err := j.record(transactionUndo{Op: "restore", Path: op.name, From: backupPath}); err != nil {
+				return err
+			}
+		}
+		return j.fileSystem.WriteFile(op.name, op.data, op.perm)
+
+	default:
+		panic(op.op + ": unknown operation")
+	}
+}
+
+// backupDir returns the directory containing j's backups.
+func (j *transactionJournal) backupDir() string {
+	return j.journalPath + ".backup"
+}
+
+// finish removes j's journal and backups.
+func (j *transactionJournal) finish() error {
+	// Removing the journal completes the transaction, after which the backups
+	// are no longer needed. Failing to remove the backups is not an error, as
+	// they are removed by the next Commit or RecoverTransaction.
+	if err := j.fileSystem.Remove(j.journalPath); err != nil {
+		return err
+	}
+	_ = j.fileSystem.RemoveAll(j.backupDir())
+	return nil
+}
+
+// moveToBackup records how to undo moving name to a backup, and then moves it.
+func (j *transactionJournal) moveToBackup(name string) error {
+	backupPath := j.nextBackupPath()
+	if err := j.record(transactionUndo{Op: "rename", Path: name, From: backupPath}); err != nil {
+		return err
+	}
+	return j.fileSystem.Rename(name, backupPath)
+}
+
+// nextBackupPath returns an unused backup path.
+func (j *transactionJournal) nextBackupPath() string {
+	j.backups++
+	return filepath.Join(j.backupDir(), strconv.Itoa(j.backups))
+}
+
+// record appends undo to j and saves it.
+func (j *transactionJournal) record(undo transactionUndo) error {
+	j.undos = append(j.undos, undo)
+	return j.save()
+}
+
+// recordCreate records how to undo creating name with op, returning an error
+// if name already exists.
+func (j *transactionJournal) recordCreate(op, name string) error {
+	switch _, err := j.fileSystem.Lstat(name); {
+	case err == nil:
+		return &os.PathError{Op: op, Path: name, Err: fs.ErrExist}
+	case !errors.Is(err, fs.ErrNotExist):
+		return err
+	}
+	return j.record(transactionUndo{Op: "remove", Path: name})
+}
+
+// save writes j's journal.
+func (j *transactionJournal) save() error {
+	data, err := json.Marshal(j.undos)
+	if err != nil {
+		return err
+	}
+	tempPath := j.journalPath + ".tmp"
+	if err := j.fileSystem.WriteFile(tempPath, data, 0o600); err != nil {
+		return err
+	}
+	return j.fileSystem.Rename(tempPath, j.journalPath)
+}
+
+// undo undoes the modifications recorded in j, most recent first, and then
+// removes the journal. Each undo operation tolerates the corresponding
+// modification not having been applied.
+func (j *transactionJournal) undo() error {
+	for i := len(j.undos) - 1; i >= 0; i-- {
+		undo := j.undos[i]
+		var err error
+		switch undo.Op {
+		case "chmod":
+			err = j.fileSystem.Chmod(undo.Path, undo.Mode)
+		case "remove":
+			err = j.fileSystem.Remove(undo.Path)
+		case "rename":
+			if _, err = j.fileSystem.Lstat(undo.From); err == nil {
+				err = j.fileSystem.Rename(undo.From, undo.Path)
+			}
+		case "restore":
+			var data []byte
+			if data, err = j.fileSystem.ReadFile(undo.From); err == nil {
+				err = j.fileSystem.WriteFile(undo.Path, data, 0o666)
+			}
+		default:
+			err = &os.PathError{Op: "undo", Path: j.journalPath, Err: errors.New(undo.Op + ": unknown operation")}
+		}
+		if err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return err
+		}
+		// Save progress so that an interrupted undo does not undo anything
+		// twice.
+		j.undos = j.undos[:i]
+		if err := j.save(); err != nil {
+			return err
+		}
+	}
+	return j.finish()
+}
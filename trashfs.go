@@ -0,0 +1,528 @@
+package vfs
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// trashInfoTimeLayout is the layout of DeletionDate in .trashinfo files.
+const trashInfoTimeLayout = "2006-01-02T15:04:05"
+
+var (
+	errContainsTrashDir = fmt.Errorf("%w: contains trash directory", fs.ErrInvalid)
+	errRelativePath     = fmt.Errorf("%w: relative path", fs.ErrInvalid)
+)
+
+// A TrashEntry is an entry in a TrashFS's trash.
+type TrashEntry struct {
+	// Name is the name of the entry in the trash.
+	Name string
+	// Path is the original path of the entry.
+	Path string
+	// DeletionDate is the time at which the entry was moved to the trash.
+	DeletionDate time.Time
+}
+
+// A TrashFS operates on an existing FS and, instead of removing files and
+// directories with Remove and RemoveAll, moves them to a trash directory from
+// which they can be restored. The trash directory uses the layout of the
+// freedesktop.org Trash specification, with the trashed entries in files/ and
+// their original paths and deletion dates in info/*.trashinfo. Entries are
+// moved with Rename, so the trash directory must be on the same filesystem as
+// the entries removed. Entries below the trash directory are removed directly.
+// Only absolute names can be moved to the trash, and removing the trash
+// directory's ancestors returns an error wrapping fs.ErrInvalid.
+type TrashFS struct {
+	fileSystem FS
+	trashDir   string
+	mu         sync.Mutex
+}
+
+// NewTrashFS returns a new *TrashFS operating on fileSystem that moves removed
+// entries to trashDir, which is created if needed.
+func NewTrashFS(fileSystem FS, trashDir string) *TrashFS {
+	return &TrashFS{
+		fileSystem: fileSystem,
+		trashDir:   filepath.Clean(trashDir),
+	}
+}
+
+// Chmod implements os.Chmod.
+func (t *TrashFS) Chmod(name string, mode fs.FileMode) error {
+	return t.fileSystem.Chmod(name, mode)
+}
+
+// Chown implements os.Chown.
+func (t *TrashFS) Chown(name string, uid, gid int) error {
+	return t.fileSystem.Chown(name, uid, gid)
+}
+
+// Chtimes implements os.Chtimes.
+func (t *TrashFS) Chtimes(name string, atime, mtime time.Time) error {
+	return t.fileSystem.Chtimes(name, atime, mtime)
+}
+
+// Create implements os.Create.
+func (t *TrashFS) Create(name string) (*os.File, error) {
+	return t.fileSystem.Create(name)
+}
+
+// DefaultTempDir implements DefaultTempDirer.DefaultTempDir by returning
+// TempDir(t's underlying FS).
+func (t *TrashFS) DefaultTempDir() string {
+	return TempDir(t.fileSystem)
+}
+
+// EmptyTrash permanently removes all entries from the trash.
+func (t *TrashFS) EmptyTrash() error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	// Remove the files before their .trashinfo files, as required by the
+	// specification.
+	for _, dir := range []string{t.filesDir(), t.infoDir()} {
+		dirEntries, err := t.fileSystem.ReadDir(dir)
+		switch {
+		case errors.Is(err, fs.ErrNotExist):
+			continue
+		case err != nil:
+			return err
+		}
+		for _, dirEntry := range dirEntries {
+			if err := t.fileSystem.RemoveAll(filepath.Join(dir, dirEntry.Name())); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// Getxattr implements XattrFS.Getxattr if t's underlying FS implements XattrFS.
+func (t *TrashFS) Getxattr(name, attr string) ([]byte, error) {
+	xattrFS, ok := t.fileSystem.(XattrFS)
+	if !ok {
+		return nil, unsupportedError("Getxattr", name)
+	}
+	return xattrFS.Getxattr(name, attr)
+}
+
+// Glob implements filepath.Glob.
+func (t *TrashFS) Glob(pattern string) ([]string, error) {
+	return t.fileSystem.Glob(pattern)
+}
+
+// Lchown implements os.Lchown.
+func (t *TrashFS) Lchown(name string, uid, gid int) error {
+	return t.fileSystem.Lchown(name, uid, gid)
+}
+
+// Lgetxattr implements XattrFS.Lgetxattr if t's underlying FS implements
+// XattrFS.
+func (t *TrashFS) Lgetxattr(name, attr string) ([]byte, error) {
+	xattrFS, ok := t.fileSystem.(XattrFS)
+	if !ok {
+		return nil, unsupportedError("Lgetxattr", name)
+	}
+	return xattrFS.Lgetxattr(name, attr)
+}
+
+// Link implements os.Link.
+func (t *TrashFS) Link(oldname, newname string) error {
+	return t.fileSystem.Link(oldname, newname)
+}
+
+// ListTrash returns the entries in the trash, sorted by name. Entries with
+// missing or invalid .trashinfo files are ignored.
+func (t *TrashFS) ListTrash() ([]*TrashEntry, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	dirEntries, err := t.fileSystem.ReadDir(t.infoDir())
+	switch {
+	case errors.Is(err, fs.ErrNotExist):
+		return nil, nil
+	case err != nil:
+		return nil, err
+	}
+	var trashEntries []*TrashEntry
+	for _, dirEntry := range dirEntries {
+		name, ok := strings.CutSuffix(dirEntry.Name(), ".trashinfo")
+		if !ok {
+			continue
+		}
+		trashEntry, err := t.readTrashInfo(name)
+		if err != nil {
+			continue
+		}
+		if _, err := t.fileSystem.Lstat(filepath.Join(t.filesDir(), name)); err != nil {
+			continue
+		}
+		trashEntries = append(trashEntries, trashEntry)
+	}
+	sort.Slice(trashEntries, func(i, j int) bool {
+		return trashEntries[i].Name < trashEntries[j].Name
+	})
+	return trashEntries, nil
+}
+
+// Listxattr implements XattrFS.Listxattr if t's underlying FS implements
+// XattrFS.
+func (t *TrashFS) Listxattr(name string) ([]string, error) {
+	xattrFS, ok := t.fileSystem.(XattrFS)
+	if !ok {
+		return nil, unsupportedError("Listxattr", name)
+	}
+	return xattrFS.Listxattr(name)
+}
+
+// Llistxattr implements XattrFS.Llistxattr if t's underlying FS implements
+// XattrFS.
+func (t *TrashFS) Llistxattr(name string) ([]string, error) {
+	xattrFS, ok := t.fileSystem.(XattrFS)
+	if !ok {
+		return nil, unsupportedError("Llistxattr", name)
+	}
+	return xattrFS.Llistxattr(name)
+}
+
+// Lremovexattr implements XattrFS.Lremovexattr if t's underlying FS implements
+// XattrFS.
+func (t *TrashFS) Lremovexattr(name, attr string) error {
+	xattrFS, ok := t.fileSystem.(XattrFS)
+	if !ok {
+		return unsupportedError("Lremovexattr", name)
+	}
+	return xattrFS.Lremovexattr(name, attr)
+}
+
+// Lsetxattr implements XattrFS.Lsetxattr if t's underlying FS implements
+// XattrFS.
+func (t *TrashFS) Lsetxattr(name, attr string, value []byte) error {
+	xattrFS, ok := t.fileSystem.(XattrFS)
+	if !ok {
+		return unsupportedError("Lsetxattr", name)
+	}
+	return xattrFS.Lsetxattr(name, attr, value)
+}
+
+// Lstat implements os.Lstat.
+func (t *TrashFS) Lstat(name string) (fs.FileInfo, error) {
+	return t.fileSystem.Lstat(name)
+}
+
+// Mkdir implements os.Mkdir.
+func (t *TrashFS) Mkdir(name string, perm fs.FileMode) error {
+	return t.fileSystem.Mkdir(name, perm)
+}
+
+// Open implements os.Open.
+func (t *TrashFS) Open(name string) (fs.File, error) {
+	return t.fileSystem.Open(name)
+}
+
+// OpenFile implements os.OpenFile.
+func (t *TrashFS) OpenFile(name string, flag int, perm fs.FileMode) (*os.File, error) {
+	return t.fileSystem.OpenFile(name, flag, perm)
+}
+
+// PathSeparator implements PathSeparator.
+func (t *TrashFS) PathSeparator() rune {
+	return t.fileSystem.PathSeparator()
+}
+
+// RawPath implements RawPath.
+func (t *TrashFS) RawPath(path string) (string, error) {
+	return t.fileSystem.RawPath(path)
+}
+
+// ReadDir implements os.ReadDir.
+func (t *TrashFS) ReadDir(dirname string) ([]fs.DirEntry, error) {
+	return t.fileSystem.ReadDir(dirname)
+}
+
+// ReadFile implements os.ReadFile.
+func (t *TrashFS) ReadFile(filename string) ([]byte, error) {
+	return t.fileSystem.ReadFile(filename)
+}
+
+// Readlink implements os.Readlink.
+func (t *TrashFS) Readlink(name string) (string, error) {
+	return t.fileSystem.Readlink(name)
+}
+
+// Remove implements os.Remove by moving name to the trash. As with os.Remove,
+// non-empty directories cannot be removed. It returns an error wrapping
+// fs.ErrInvalid if name is relative or is an ancestor of the trash directory.
+func (t *TrashFS) Remove(name string) error {
+	if t.isTrashPath(name) {
+		return t.fileSystem.Remove(name)
+	}
+	if err := t.checkTrashable("remove", name); err != nil {
+		return err
+	}
+	info, err := t.fileSystem.Lstat(name)
+	if err != nil {
+		return err
+	}
+	if info.IsDir() {
+		dirEntries, err := t.fileSystem.ReadDir(name)
+		if err != nil {
+			return err
+		}
+		if len(dirEntries) != 0 {
+			return &os.PathError{Op: "remove", Path: name, Err: syscall.ENOTEMPTY}
+		}
+	}
+	return t.trash(name)
+}
+
+// RemoveAll implements os.RemoveAll by moving name to the trash. It returns an
+// error wrapping fs.ErrInvalid if name is relative or is an ancestor of the
+// trash directory.
+func (t *TrashFS) RemoveAll(name string) error {
+	if t.isTrashPath(name) {
+		return t.fileSystem.RemoveAll(name)
+	}
+	if err := t.checkTrashable("RemoveAll", name); err != nil {
+		return err
+	}
+	switch _, err := t.fileSystem.Lstat(name); {
+	case errors.Is(err, fs.ErrNotExist):
+		return nil
+	case err != nil:
+		return err
+	}
+	return t.trash(name)
+}
+
+// Removexattr implements XattrFS.Removexattr if t's underlying FS implements
+// XattrFS.
+func (t *TrashFS) Removexattr(name, attr string) error {
+	xattrFS, ok := t.fileSystem.(XattrFS)
+	if !ok {
+		return unsupportedError("Removexattr", name)
+	}
+	return xattrFS.Removexattr(name, attr)
+}
+
+// Rename implements os.Rename.
+func (t *TrashFS) Rename(oldpath, newpath string) error {
+	return t.fileSystem.Rename(oldpath, newpath)
+}
+
+// RestoreTrash moves the entry with the given name in the trash back to its
+// original path, creating the original path's parent directories if needed. It
+// returns an error wrapping fs.ErrExist if the original path already exists.
+func (t *TrashFS) RestoreTrash(name string) error {
+	if name == "" || name != filepath.Base(name) || name == "." || name == ".." {
+		return &os.PathError{Op: "RestoreTrash", Path: name, Err: fs.ErrNotExist}
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	trashEntry, err := t.readTrashInfo(name)
+	if err != nil {
+		return err
+	}
+	switch _, err := t.fileSystem.Lstat(trashEntry.Path); {
+	case err == nil:
+		return &os.PathError{Op: "RestoreTrash", Path: trashEntry.Path, Err: fs.ErrExist}
+	case !errors.Is(err, fs.ErrNotExist):
+		return err
+	}
+	if err := MkdirAll(t.fileSystem, filepath.Dir(trashEntry.Path), 0o777); err != nil {
+		return err
+	}
+	if err := t.fileSystem.Rename(filepath.Join(t.filesDir(), name), trashEntry.Path); err != nil {
+		return err
+	}
+	return t.fileSystem.Remove(t.infoPath(name))
+}
+
+// Setxattr implements XattrFS.Setxattr if t's underlying FS implements XattrFS.
+func (t *TrashFS) Setxattr(name, attr string, value []byte) error {
+	xattrFS, ok := t.fileSystem.(XattrFS)
+	if !ok {
+		return unsupportedError("Setxattr", name)
+	}
+	return xattrFS.Setxattr(name, attr, value)
+}
+
+// Stat implements os.Stat.
+func (t *TrashFS) Stat(name string) (fs.FileInfo, error) {
+	return t.fileSystem.Stat(name)
+}
+
+// Statfs implements Statfser.Statfs if t's underlying FS implements Statfser.
+func (t *TrashFS) Statfs(name string) (*FSStat, error) {
+	statfser, ok := t.fileSystem.(Statfser)
+	if !ok {
+		return nil, unsupportedError("Statfs", name)
+	}
+	return statfser.Statfs(name)
+}
+
+// Symlink implements os.Symlink.
+func (t *TrashFS) Symlink(oldname, newname string) error {
+	return t.fileSystem.Symlink(oldname, newname)
+}
+
+// Truncate implements os.Truncate.
+func (t *TrashFS) Truncate(name string, size int64) error {
+	return t.fileSystem.Truncate(name, size)
+}
+
+// WriteFile implements os.WriteFile.
+func (t *TrashFS) WriteFile(filename string, data []byte, perm fs.FileMode) error {
+	return t.fileSystem.WriteFile(filename, data, perm)
+}
+
+// checkTrashable returns an error if name cannot be moved to the trash. The
+// specification requires absolute paths, and the trash directory cannot be
+// moved into itself.
+func (t *TrashFS) checkTrashable(op, name string) error {
+	switch {
+	case !isAbs(name):
+		return &os.PathError{Op: op, Path: name, Err: errRelativePath}
+	case t.isTrashAncestor(name):
+		return &os.PathError{Op: op, Path: name, Err: errContainsTrashDir}
+	default:
+		return nil
+	}
+}
+
+// filesDir returns the directory containing trashed entries.
+func (t *TrashFS) filesDir() string {
+	return filepath.Join(t.trashDir, "files")
+}
+
+// infoDir returns the directory containing .trashinfo files.
+func (t *TrashFS) infoDir() string {
+	return filepath.Join(t.trashDir, "info")
+}
+
+// infoPath returns the path of the .trashinfo file for the entry with the
+// given name.
+func (t *TrashFS) infoPath(name string) string {
+	return filepath.Join(t.infoDir(), name+".trashinfo")
+}
+
+// isTrashPath returns whether name is t's trash directory or is below it.
+func (t *TrashFS) isTrashPath(name string) bool {
+	name = filepath.Clean(name)
+	return name == t.trashDir || strings.HasPrefix(name, t.trashDir+string(filepath.Separator))
+}
+
+// isTrashAncestor returns whether name is an ancestor of t's trash directory.
+func (t *TrashFS) isTrashAncestor(name string) bool {
+	name = filepath.Clean(name)
+	if !strings.HasSuffix(name, string(filepath.Separator)) {
+		name += string(filepath.Separator)
+	}
+	return strings.HasPrefix(t.trashDir, name)
+}
+
+// readTrashInfo reads the .trashinfo file for the entry with the given name.
+func (t *TrashFS) readTrashInfo(name string) (*TrashEntry, error) {
+	infoPath := t.infoPath(name)
+	data, err := t.fileSystem.ReadFile(infoPath)
+	if err != nil {
+		return nil, err
+	}
+	trashEntry := &TrashEntry{
+		Name: name,
+	}
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	inSection := false
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		switch key, value, _ := strings.Cut(line, "="); {
+		case strings.HasPrefix(line, "["):
+			inSection = line == "[Trash Info]"
+		case !inSection:
+		case key == "Path":
+			path, err := url.PathUnescape(value)
+			if err != nil {
+				return nil, &os.PathError{Op: "readTrashInfo", Path: infoPath, Err: err}
+			}
+			trashEntry.Path = filepath.FromSlash(path)
+		case key == "DeletionDate":
+			deletionDate, err := time.ParseInLocation(trashInfoTimeLayout, value, time.Local)
+			if err != nil {
+				return nil, &os.PathError{Op: "readTrashInfo", Path: infoPath, Err: err}
+			}
+			trashEntry.DeletionDate = deletionDate
+		}
+	}
+	if trashEntry.Path == "" {
+		return nil, &os.PathError{Op: "readTrashInfo", Path: infoPath, Err: errors.New("missing Path")}
+	}
+	return trashEntry, nil
+}
+
+// trash moves name to the trash.
+func (t *TrashFS) trash(name string) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for _, dir := range []string{t.filesDir(), t.infoDir()} {
+		if err := MkdirAll(t.fileSystem, dir, 0o700); err != nil {
+			return err
+		}
+	}
+
+	// Reserve a name in the trash by exclusively creating its .trashinfo
+	// file, as required by the specification.
+	base := filepath.Base(name)
+	path := (&url.URL{Path: filepath.ToSlash(filepath.Clean(name))}).EscapedPath()
+	trashInfo := fmt.Sprintf("[Trash Info]\nPath=%s\nDeletionDate=%s\n", path, time.Now().Format(trashInfoTimeLayout))
+	for i := 1; ; i++ {
+		trashName := base
+		if i > 1 {
+			trashName += "." + strconv.Itoa(i)
+		}
+		infoPath := t.infoPath(trashName)
+		f, err := t.fileSystem.OpenFile(infoPath, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o600)
+		switch {
+		case errors.Is(err, fs.ErrExist):
+			continue
+		case err != nil:
+			return err
+		}
+		trashPath := filepath.Join(t.filesDir(), trashName)
+		switch _, err := t.fileSystem.Lstat(trashPath); {
+		case err == nil:
+			// An orphaned entry without a .trashinfo file already has this
+			// name.
+			f.Close()
+			if err := t.fileSystem.Remove(infoPath); err != nil {
+				return err
+			}
+			continue
+		case !errors.Is(err, fs.ErrNotExist):
+			f.Close()
+			_ = t.fileSystem.Remove(infoPath)
+			return err
+		}
+		_, err = f.WriteString(trashInfo)
+		if closeErr := f.Close(); err == nil {
+			err = closeErr
+		}
+		if err == nil {
+			err = t.fileSystem.Rename(name, trashPath)
+		}
+		if err != nil {
+			_ = t.fileSystem.Remove(infoPath)
+		}
+		return err
+	}
+}
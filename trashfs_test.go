@@ -0,0 +1,11 @@
+package vfs_test
+
+import "github.com/twpayne/go-vfs/v5"
+
+var _ vfs.FS = &vfs.TrashFS{}
+
+var _ vfs.XattrFS = &vfs.TrashFS{}
+
+var _ vfs.Statfser = &vfs.TrashFS{}
+
+var _ vfs.DefaultTempDirer = &vfs.TrashFS{}
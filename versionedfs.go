@@ -0,0 +1,556 @@
+package vfs
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// versionPathName is the name of the file in each version directory that
+// contains the path of the versioned file.
+const versionPathName = "path"
+
+// A FileVersion is a previous version of a file stored by a VersionedFS.
+type FileVersion struct {
+	// Path is the path of the file.
+	Path string
+	// ID identifies the version. IDs sort in the order in which the versions
+	// were stored.
+	ID string
+	// Time is the time at which the version was stored.
+	Time time.Time
+	// Size is the size of the version in bytes.
+	Size int64
+	// Mode is the mode of the file when the version was stored.
+	Mode fs.FileMode
+}
+
+// A VersionedFS operates on an existing FS and, before a regular file is
+// overwritten or removed by Create, OpenFile, Remove, RemoveAll, Rename,
+// Truncate, or WriteFile, stores its previous contents in a versions directory.
+// The versions directory is hidden from ReadDir and Glob. Symlinks and
+// directories are not versioned. OpenFile stores a version whenever the file
+// is opened for writing, even if it is not subsequently modified. The versions
+// of each file are stored in a directory named after a hash of its path, so
+// the length of the path is not limited by the maximum length of a name.
+type VersionedFS struct {
+	fileSystem  FS
+	versionsDir string
+	mu          sync.Mutex
+}
+
+// NewVersionedFS returns a new *VersionedFS operating on fileSystem that stores
+// versions in versionsDir, which is created if needed.
+func NewVersionedFS(fileSystem FS, versionsDir string) *VersionedFS {
+	return &VersionedFS{
+		fileSystem:  fileSystem,
+		versionsDir: filepath.Clean(versionsDir),
+	}
+}
+
+// Chmod implements os.Chmod.
+func (v *VersionedFS) Chmod(name string, mode fs.FileMode) error {
+	return v.fileSystem.Chmod(name, mode)
+}
+
+// Chown implements os.Chown.
+func (v *VersionedFS) Chown(name string, uid, gid int) error {
+	return v.fileSystem.Chown(name, uid, gid)
+}
+
+// Chtimes implements os.Chtimes.
+func (v *VersionedFS) Chtimes(name string, atime, mtime time.Time) error {
+	return v.fileSystem.Chtimes(name, atime, mtime)
+}
+
+// Create implements os.Create.
+func (v *VersionedFS) Create(name string) (*os.File, error) {
+	if err := v.storeVersion(name); err != nil {
+		return nil, err
+	}
+	return v.fileSystem.Create(name)
+}
+
+// DefaultTempDir implements DefaultTempDirer.DefaultTempDir by returning
+// TempDir(v's underlying FS).
+func (v *VersionedFS) DefaultTempDir() string {
+	return TempDir(v.fileSystem)
+}
+
+// Getxattr implements XattrFS.Getxattr if v's underlying FS implements XattrFS.
+func (v *VersionedFS) Getxattr(name, attr string) ([]byte, error) {
+	xattrFS, ok := v.fileSystem.(XattrFS)
+	if !ok {
+		return nil, unsupportedError("Getxattr", name)
+	}
+	return xattrFS.Getxattr(name, attr)
+}
+
+// Glob implements filepath.Glob.
+func (v *VersionedFS) Glob(pattern string) ([]string, error) {
+	matches, err := v.fileSystem.Glob(pattern)
+	if err != nil {
+		return nil, err
+	}
+	result := matches[:0]
+	for _, match := range matches {
+		if !v.isVersionsPath(match) {
+			result = append(result, match)
+		}
+	}
+	return result, nil
+}
+
+// Lchown implements os.Lchown.
+func (v *VersionedFS) Lchown(name string, uid, gid int) error {
+	return v.fileSystem.Lchown(name, uid, gid)
+}
+
+// Lgetxattr implements XattrFS.Lgetxattr if v's underlying FS implements
+// XattrFS.
+func (v *VersionedFS) Lgetxattr(name, attr string) ([]byte, error) {
+	xattrFS, ok := v.fileSystem.(XattrFS)
+	if !ok {
+		return nil, unsupportedError("Lgetxattr", name)
+	}
+	return xattrFS.Lgetxattr(name, attr)
+}
+
+// Link implements os.Link.
+func (v *VersionedFS) Link(oldname, newname string) error {
+	return v.fileSystem.Link(oldname, newname)
+}
+
+// Listxattr implements XattrFS.Listxattr if v's underlying FS implements
+// XattrFS.
+func (v *VersionedFS) Listxattr(name string) ([]string, error) {
+	xattrFS, ok := v.fileSystem.(XattrFS)
+	if !ok {
+		return nil, unsupportedError("Listxattr", name)
+	}
+	return xattrFS.Listxattr(name)
+}
+
+// Llistxattr implements XattrFS.Llistxattr if v's underlying FS implements
+// XattrFS.
+func (v *VersionedFS) Llistxattr(name string) ([]string, error) {
+	xattrFS, ok := v.fileSystem.(XattrFS)
+	if !ok {
+		return nil, unsupportedError("Llistxattr", name)
+	}
+	return xattrFS.Llistxattr(name)
+}
+
+// Lremovexattr implements XattrFS.Lremovexattr if v's underlying FS implements
+// XattrFS.
+func (v *VersionedFS) Lremovexattr(name, attr string) error {
+	xattrFS, ok := v.fileSystem.(XattrFS)
+	if !ok {
+		return unsupportedError("Lremovexattr", name)
+	}
+	return xattrFS.Lremovexattr(name, attr)
+}
+
+// Lsetxattr implements XattrFS.Lsetxattr if v's underlying FS implements
+// XattrFS.
+func (v *VersionedFS) Lsetxattr(name, attr string, value []byte) error {
+	xattrFS, ok := v.fileSystem.(XattrFS)
+	if !ok {
+		return unsupportedError("Lsetxattr", name)
+	}
+	return xattrFS.Lsetxattr(name, attr, value)
+}
+
+// Lstat implements os.Lstat.
+func (v *VersionedFS) Lstat(name string) (fs.FileInfo, error) {
+	return v.fileSystem.Lstat(name)
+}
+
+// Mkdir implements os.Mkdir.
+func (v *VersionedFS) Mkdir(name string, perm fs.FileMode) error {
+	return v.fileSystem.Mkdir(name, perm)
+}
+
+// Open implements os.Open.
+func (v *VersionedFS) Open(name string) (fs.File, error) {
+	return v.fileSystem.Open(name)
+}
+
+// OpenFile implements os.OpenFile.
+func (v *VersionedFS) OpenFile(name string, flag int, perm fs.FileMode) (*os.File, error) {
+	if flag&(os.O_WRONLY|os.O_RDWR|os.O_APPEND|os.O_TRUNC) != 0 {
+		if err := v.storeVersion(name); err != nil {
+			return nil, err
+		}
+	}
+	return v.fileSystem.OpenFile(name, flag, perm)
+}
+
+// PathSeparator implements PathSeparator.
+func (v *VersionedFS) PathSeparator() rune {
+	return v.fileSystem.PathSeparator()
+}
+
+// Prune removes stored versions. If maxAge is greater than zero then versions
+// stored more than maxAge ago are removed. If maxCount is greater than zero
+// then only the maxCount most recent versions of each file are kept. It
+// returns the number of versions removed.
+func (v *VersionedFS) Prune(maxAge time.Duration, maxCount int) (int, error) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	dirEntries, err := v.fileSystem.ReadDir(v.versionsDir)
+	switch {
+	case errors.Is(err, fs.ErrNotExist):
+		return 0, nil
+	case err != nil:
+		return 0, err
+	}
+	now := time.Now()
+	removed := 0
+	for _, dirEntry := range dirEntries {
+		dir := filepath.Join(v.versionsDir, dirEntry.Name())
+		versionEntries, err := v.fileSystem.ReadDir(dir)
+		if err != nil {
+			return removed, err
+		}
+		sort.Sort(dirEntriesByName(versionEntries))
+		var ids []string
+		for _, versionEntry := range versionEntries {
+			if _, err := versionIDTime(versionEntry.Name()); err == nil {
+				ids = append(ids, versionEntry.Name())
+			}
+		}
+		remaining := len(ids)
+		for i, id := range ids {
+			versionTime, _ := versionIDTime(id)
+			tooOld := maxAge > 0 && now.Sub(versionTime) > maxAge
+			tooMany := maxCount > 0 && len(ids)-i > maxCount
+			if !tooOld && !tooMany {
+				continue
+			}
+			if err := v.fileSystem.Remove(filepath.Join(dir, id)); err != nil {
+				return removed, err
+			}
+			removed++
+			remaining--
+		}
+		if remaining == 0 {
+			if err := v.fileSystem.RemoveAll(dir); err != nil {
+				return removed, err
+			}
+		}
+	}
+	return removed, nil
+}
+
+// RawPath implements RawPath.
+func (v *VersionedFS) RawPath(path string) (string, error) {
+	return v.fileSystem.RawPath(path)
+}
+
+// ReadDir implements os.ReadDir.
+func (v *VersionedFS) ReadDir(dirname string) ([]fs.DirEntry, error) {
+	dirEntries, err := v.fileSystem.ReadDir(dirname)
+	if err != nil {
+		return nil, err
+	}
+	if filepath.Clean(dirname) != filepath.Dir(v.versionsDir) {
+		return dirEntries, nil
+	}
+	result := dirEntries[:0]
+	for _, dirEntry := range dirEntries {
+		if dirEntry.Name() != filepath.Base(v.versionsDir) {
+			result = append(result, dirEntry)
+		}
+	}
+	return result, nil
+}
+
+// ReadFile implements os.ReadFile.
+func (v *VersionedFS) ReadFile(filename string) ([]byte, error) {
+	return v.fileSystem.ReadFile(filename)
+}
+
+// ReadVersion returns the contents of the version of name with the given id.
+func (v *VersionedFS) ReadVersion(name, id string) ([]byte, error) {
+	if _, err := versionIDTime(id); err != nil {
+		return nil, &os.PathError{Op: "ReadVersion", Path: name, Err: fs.ErrNotExist}
+	}
+	return v.fileSystem.ReadFile(v.versionPath(name, id))
+}
+
+// Readlink implements os.Readlink.
+func (v *VersionedFS) Readlink(name string) (string, error) {
+	return v.fileSystem.Readlink(name)
+}
+
+// Remove implements os.Remove.
+func (v *VersionedFS) Remove(name string) error {
+	if err := v.storeVersion(name); err != nil {
+		return err
+	}
+	return v.fileSystem.Remove(name)
+}
+
+// RemoveAll implements os.RemoveAll. Versions are stored of all regular files
+// below name before they are removed. If name is an ancestor of the versions
+// directory then everything below name except the versions directory is
+// removed, and name itself is kept.
+func (v *VersionedFS) RemoveAll(name string) error {
+	if err := Walk(v.fileSystem, name, func(path string, info fs.FileInfo, err error) error {
+		switch {
+		case errors.Is(err, fs.ErrNotExist):
+			return nil
+		case err != nil:
+			return err
+		case info.IsDir() && v.isVersionsPath(path):
+			return fs.SkipDir
+		case !info.Mode().IsRegular():
+			return nil
+		default:
+			return v.storeVersion(path)
+		}
+	}); err != nil {
+		return err
+	}
+	return v.removeAll(name)
+}
+
+// Removexattr implements XattrFS.Removexattr if v's underlying FS implements
+// XattrFS.
+func (v *VersionedFS) Removexattr(name, attr string) error {
+	xattrFS, ok := v.fileSystem.(XattrFS)
+	if !ok {
+		return unsupportedError("Removexattr", name)
+	}
+	return xattrFS.Removexattr(name, attr)
+}
+
+// Rename implements os.Rename.
+func (v *VersionedFS) Rename(oldpath, newpath string) error {
+	if err := v.storeVersion(newpath); err != nil {
+		return err
+	}
+	return v.fileSystem.Rename(oldpath, newpath)
+}
+
+// Restore replaces the contents of name with the version with the given id.
+// The current contents of name, if any, are stored as a new version first.
+func (v *VersionedFS) Restore(name, id string) error {
+	if _, err := versionIDTime(id); err != nil {
+		return &os.PathError{Op: "Restore", Path: name, Err: fs.ErrNotExist}
+	}
+	versionPath := v.versionPath(name, id)
+	info, err := v.fileSystem.Lstat(versionPath)
+	if err != nil {
+		return err
+	}
+	data, err := v.fileSystem.ReadFile(versionPath)
+	if err != nil {
+		return err
+	}
+	return v.WriteFile(name, data, info.Mode().Perm())
+}
+
+// Setxattr implements XattrFS.Setxattr if v's underlying FS implements XattrFS.
+func (v *VersionedFS) Setxattr(name, attr string, value []byte) error {
+	xattrFS, ok := v.fileSystem.(XattrFS)
+	if !ok {
+		return unsupportedError("Setxattr", name)
+	}
+	return xattrFS.Setxattr(name, attr, value)
+}
+
+// Stat implements os.Stat.
+func (v *VersionedFS) Stat(name string) (fs.FileInfo, error) {
+	return v.fileSystem.Stat(name)
+}
+
+// Statfs implements Statfser.Statfs if v's underlying FS implements Statfser.
+func (v *VersionedFS) Statfs(name string) (*FSStat, error) {
+	statfser, ok := v.fileSystem.(Statfser)
+	if !ok {
+		return nil, unsupportedError("Statfs", name)
+	}
+	return statfser.Statfs(name)
+}
+
+// Symlink implements os.Symlink.
+func (v *VersionedFS) Symlink(oldname, newname string) error {
+	return v.fileSystem.Symlink(oldname, newname)
+}
+
+// Truncate implements os.Truncate.
+func (v *VersionedFS) Truncate(name string, size int64) error {
+	if err := v.storeVersion(name); err != nil {
+		return err
+	}
+	return v.fileSystem.Truncate(name, size)
+}
+
+// Versions returns the stored versions of name, oldest first.
+func (v *VersionedFS) Versions(name string) ([]*FileVersion, error) {
+	dirEntries, err := v.fileSystem.ReadDir(v.versionDir(name))
+	switch {
+	case errors.Is(err, fs.ErrNotExist):
+		return nil, nil
+	case err != nil:
+		return nil, err
+	}
+	sort.Sort(dirEntriesByName(dirEntries))
+	versions := make([]*FileVersion, 0, len(dirEntries))
+	for _, dirEntry := range dirEntries {
+		versionTime, err := versionIDTime(dirEntry.Name())
+		if err != nil {
+			continue
+		}
+		info, err := dirEntry.Info()
+		if err != nil {
+			return nil, err
+		}
+		versions = append(versions, &FileVersion{
+			Path: name,
+			ID:   dirEntry.Name(),
+			Time: versionTime,
+			Size: info.Size(),
+			Mode: info.Mode(),
+		})
+	}
+	return versions, nil
+}
+
+// WriteFile implements os.WriteFile.
+func (v *VersionedFS) WriteFile(filename string, data []byte, perm fs.FileMode) error {
+	if err := v.storeVersion(filename); err != nil {
+		return err
+	}
+	return v.fileSystem.WriteFile(filename, data, perm)
+}
+
+// isVersionsPath returns whether name is v's versions directory or is below
+// it.
+func (v *VersionedFS) isVersionsPath(name string) bool {
+	name = filepath.Clean(name)
+	return name == v.versionsDir || strings.HasPrefix(name, v.versionsDir+string(filepath.Separator))
+}
+
+// isVersionsAncestor returns whether name is an ancestor of v's versions
+// directory.
+func (v *VersionedFS) isVersionsAncestor(name string) bool {
+	name = filepath.Clean(name)
+	if !strings.HasSuffix(name, string(filepath.Separator)) {
+		name += string(filepath.Separator)
+	}
+	return strings.HasPrefix(v.versionsDir, name)
+}
+
+// removeAll removes name and everything below it, except for v's versions
+// directory.
+func (v *VersionedFS) removeAll(name string) error {
+	if !v.isVersionsAncestor(name) {
+		return v.fileSystem.RemoveAll(name)
+	}
+	dirEntries, err := v.fileSystem.ReadDir(name)
+	switch {
+	case errors.Is(err, fs.ErrNotExist):
+		return nil
+	case err != nil:
+		return err
+	}
+	for _, dirEntry := range dirEntries {
+		path := filepath.Join(name, dirEntry.Name())
+		if path == v.versionsDir {
+			continue
+		}
+		if err := v.removeAll(path); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// storeVersion stores the current contents of name as a new version, if name
+// is a regular file.
+func (v *VersionedFS) storeVersion(name string) error {
+	if v.isVersionsPath(name) {
+		return nil
+	}
+	info, err := v.fileSystem.Lstat(name)
+	switch {
+	case errors.Is(err, fs.ErrNotExist):
+		return nil
+	case err != nil:
+		return err
+	case !info.Mode().IsRegular():
+		return nil
+	}
+	data, err := v.fileSystem.ReadFile(name)
+	if err != nil {
+		return err
+	}
+
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	versionDir := v.versionDir(name)
+	if err := MkdirAll(v.fileSystem, versionDir, 0o700); err != nil {
+		return err
+	}
+	pathName := filepath.Join(versionDir, versionPathName)
+	switch _, err := v.fileSystem.Lstat(pathName); {
+	case errors.Is(err, fs.ErrNotExist):
+		if err := v.fileSystem.WriteFile(pathName, []byte(filepath.ToSlash(filepath.Clean(name))), 0o600); err != nil {
+			return err
+		}
+	case err != nil:
+		return err
+	}
+	// Choose an unused id, in case the clock has not advanced since the
+	// previous version was stored.
+	now := time.Now().UnixNano()
+	for {
+		versionPath := v.versionPath(name, versionID(now))
+		if _, err := v.fileSystem.Lstat(versionPath); errors.Is(err, fs.ErrNotExist) {
+			return v.fileSystem.WriteFile(versionPath, data, info.Mode().Perm())
+		} else if err != nil {
+			return err
+		}
+		now++
+	}
+}
+
+// versionDir returns the directory in which versions of name are stored. Its
+// name is the hex-encoded SHA-256 hash of the cleaned, /-separated name.
+func (v *VersionedFS) versionDir(name string) string {
+	sum := sha256.Sum256([]byte(filepath.ToSlash(filepath.Clean(name))))
+	return filepath.Join(v.versionsDir, hex.EncodeToString(sum[:]))
+}
+
+// versionPath returns the path of the version of name with the given id.
+func (v *VersionedFS) versionPath(name, id string) string {
+	return filepath.Join(v.versionDir(name), id)
+}
+
+// versionID returns the version id for a version stored at unixNano.
+func versionID(unixNano int64) string {
+	return fmt.Sprintf("%020d", unixNano)
+}
+
+// versionIDTime returns the time at which the version with the given id was
+// stored.
+func versionIDTime(id string) (time.Time, error) {
+	unixNano, err := strconv.ParseInt(id, 10, 64)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return time.Unix(0, unixNano), nil
+}
@@ -0,0 +1,11 @@
+package vfs_test
+
+import "github.com/twpayne/go-vfs/v5"
+
+var _ vfs.FS = &vfs.VersionedFS{}
+
+var _ vfs.XattrFS = &vfs.VersionedFS{}
+
+var _ vfs.Statfser = &vfs.VersionedFS{}
+
+var _ vfs.DefaultTempDirer = &vfs.VersionedFS{}
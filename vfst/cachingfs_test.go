@@ -0,0 +1,99 @@
+package vfst_test
+
+import (
+	"io/fs"
+	"testing"
+	"time"
+
+	"github.com/alecthomas/assert/v2"
+
+	vfs "github.com/twpayne/go-vfs/v5"
+	"github.com/twpayne/go-vfs/v5/vfst"
+)
+
+func TestCachingFS(t *testing.T) {
+	fileSystem := vfst.NewTestFSWithT(t, map[string]any{
+		"/home/user/.bashrc": "# .bashrc\n",
+	})
+	metricsFS := vfs.NewMetricsFS(fileSystem)
+	cachingFS := vfs.NewCachingFS(metricsFS, vfs.CachingFSTTL(time.Hour))
+	calls := func(method string) uint64 {
+		return metricsFS.Snapshot().Methods[method].Calls
+	}
+
+	for i := 0; i < 3; i++ {
+		data, err := cachingFS.ReadFile("/home/user/.bashrc")
+		assert.NoError(t, err)
+		assert.Equal(t, "# .bashrc\n", string(data))
+		data[0] = 'X'
+		_, err = cachingFS.Lstat("/home/user/.profile")
+		assert.IsError(t, err, fs.ErrNotExist)
+		dirEntries, err := cachingFS.ReadDir("/home/user")
+		assert.NoError(t, err)
+		assert.Equal(t, 1, len(dirEntries))
+	}
+	assert.Equal(t, 1, calls("ReadFile"))
+	assert.Equal(t, 1, calls("Lstat"))
+	assert.Equal(t, 1, calls("ReadDir"))
+
+	// Modifications through cachingFS invalidate the cache.
+	assert.NoError(t, cachingFS.WriteFile("/home/user/.profile", []byte("# .profile\n"), 0o644))
+	_, err := cachingFS.Lstat("/home/user/.profile")
+	assert.NoError(t, err)
+	dirEntries, err := cachingFS.ReadDir("/home/user")
+	assert.NoError(t, err)
+	assert.Equal(t, 2, len(dirEntries))
+	assert.Equal(t, 2, calls("Lstat"))
+	assert.Equal(t, 2, calls("ReadDir"))
+
+	// Removing a directory invalidates its descendants.
+	assert.NoError(t, cachingFS.RemoveAll("/home/user"))
+	_, err = cachingFS.ReadFile("/home/user/.bashrc")
+	assert.IsError(t, err, fs.ErrNotExist)
+	assert.Equal(t, 2, calls("ReadFile"))
+
+	// Modifications made directly to the underlying FS are only seen after
+	// Invalidate.
+	assert.NoError(t, vfs.MkdirAll(fileSystem, "/home/user", 0o755))
+	assert.NoError(t, fileSystem.WriteFile("/home/user/.bashrc", []byte("# new .bashrc\n"), 0o644))
+	_, err = cachingFS.ReadFile("/home/user/.bashrc")
+	assert.IsError(t, err, fs.ErrNotExist)
+	cachingFS.Invalidate("/home")
+	data, err := cachingFS.ReadFile("/home/user/.bashrc")
+	assert.NoError(t, err)
+	assert.Equal(t, "# new .bashrc\n", string(data))
+}
+
+func TestCachingFSBounds(t *testing.T) {
+	fileSystem := vfst.NewTestFSWithT(t, map[string]any{
+		"/a": "a",
+		"/b": "bb",
+		"/c": "ccc",
+	})
+	metricsFS := vfs.NewMetricsFS(fileSystem)
+	calls := func() uint64 {
+		return metricsFS.Snapshot().Methods["ReadFile"].Calls
+	}
+
+	cachingFS := vfs.NewCachingFS(metricsFS, vfs.CachingFSMaxEntries(2))
+	for _, name := range []string{"/a", "/b", "/c", "/c", "/b", "/a"} {
+		_, err := cachingFS.ReadFile(name)
+		assert.NoError(t, err)
+	}
+	assert.Equal(t, 4, calls())
+
+	cachingFS = vfs.NewCachingFS(metricsFS, vfs.CachingFSMaxBytes(2))
+	for _, name := range []string{"/c", "/c", "/b", "/b"} {
+		_, err := cachingFS.ReadFile(name)
+		assert.NoError(t, err)
+	}
+	assert.Equal(t, 4+3, calls())
+
+	cachingFS = vfs.NewCachingFS(metricsFS, vfs.CachingFSTTL(time.Millisecond))
+	for i := 0; i < 2; i++ {
+		_, err := cachingFS.ReadFile("/a")
+		assert.NoError(t, err)
+		time.Sleep(2 * time.Millisecond)
+	}
+	assert.Equal(t, 4+3+2, calls())
+}
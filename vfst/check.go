@@ -0,0 +1,321 @@
+package vfst
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/fs"
+	"testing"
+
+	vfs "github.com/twpayne/go-vfs/v5"
+)
+
+// A PathCheck checks a specified path in a vfs.FS and returns an error
+// describing any discrepancy. Errors returned by the PathChecks in this package
+// are *CheckErrors.
+type PathCheck func(vfs.FS, string) error
+
+// A CheckError describes a discrepancy found by a PathCheck.
+type CheckError struct {
+	// Path is the path that was checked.
+	Path string
+	// Check is the name of the check, for example "ModePerm".
+	Check string
+	// Got is the observed value, if any.
+	Got any
+	// Want is the expected value, if any.
+	Want any
+	// Diff is a unified diff from Want to Got, if any.
+	Diff string
+	// Err is the error encountered while checking Path, if any.
+	Err error
+
+	message string
+}
+
+// Error implements error.Error.
+func (e *CheckError) Error() string {
+	switch {
+	case e.Err != nil:
+		return fmt.Sprintf("%s: %s: %v", e.Path, e.Check, e.Err)
+	case e.Diff != "":
+		return fmt.Sprintf("%s: %s:\n%s", e.Path, e.message, e.Diff)
+	default:
+		return fmt.Sprintf("%s: %s", e.Path, e.message)
+	}
+}
+
+// Unwrap returns e's underlying error.
+func (e *CheckError) Unwrap() error {
+	return e.Err
+}
+
+// CheckPath runs pathChecks on path and returns all errors joined with
+// errors.Join.
+func CheckPath(fileSystem vfs.FS, path string, pathChecks ...PathCheck) error {
+	var errs []error
+	for _, pathCheck := range pathChecks {
+		if err := pathCheck(fileSystem, path); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// TestCheck returns a PathTest that reports any error returned by pathCheck.
+func TestCheck(pathCheck PathCheck) PathTest {
+	return func(t *testing.T, fileSystem vfs.FS, path string) {
+		t.Helper()
+		if err := pathCheck(fileSystem, path); err != nil {
+			t.Error(err)
+		}
+	}
+}
+
+// CheckContents returns a PathCheck that verifies the contents of the file are
+// equal to wantContents.
+func CheckContents(wantContents []byte) PathCheck {
+	return func(fileSystem vfs.FS, path string) error {
+		gotContents, err := readFile(fileSystem, path, "Contents")
+		if err != nil {
+			return err
+		}
+		if !bytes.Equal(gotContents, wantContents) {
+			return &CheckError{
+				Path:    path,
+				Check:   "Contents",
+				Got:     gotContents,
+				Want:    wantContents,
+				message: fmt.Sprintf("has contents %v, want %v", gotContents, wantContents),
+			}
+		}
+		return nil
+	}
+}
+
+// CheckContentsString returns a PathCheck that verifies the contents of the
+// file are equal to wantContentsStr.
+func CheckContentsString(wantContentsStr string) PathCheck {
+	return func(fileSystem vfs.FS, path string) error {
+		gotContents, err := readFile(fileSystem, path, "ContentsString")
+		if err != nil {
+			return err
+		}
+		if string(gotContents) != wantContentsStr {
+			return &CheckError{
+				Path:    path,
+				Check:   "ContentsString",
+				Got:     string(gotContents),
+				Want:    wantContentsStr,
+				message: fmt.Sprintf("has contents %q, want %q", gotContents, wantContentsStr),
+			}
+		}
+		return nil
+	}
+}
+
+// CheckDoesNotExist returns a PathCheck that verifies that a file or directory
+// does not exist.
+func CheckDoesNotExist() PathCheck {
+	return func(fileSystem vfs.FS, path string) error {
+		switch info, err := fileSystem.Lstat(path); {
+		case errors.Is(err, fs.ErrNotExist):
+			return nil
+		case err != nil:
+			return &CheckError{
+				Path:  path,
+				Check: "DoesNotExist",
+				Err:   err,
+			}
+		default:
+			return &CheckError{
+				Path:    path,
+				Check:   "DoesNotExist",
+				Got:     info.Mode(),
+				message: fmt.Sprintf("exists with mode %v, want it to not exist", info.Mode()),
+			}
+		}
+	}
+}
+
+// CheckIsDir returns a PathCheck that verifies that the path is a directory.
+func CheckIsDir() PathCheck {
+	return CheckModeType(fs.ModeDir)
+}
+
+// CheckMinSize returns a PathCheck that verifies that path's Size() is at least
+// wantMinSize.
+func CheckMinSize(wantMinSize int64) PathCheck {
+	return func(fileSystem vfs.FS, path string) error {
+		info, err := lstat(fileSystem, path, "MinSize")
+		if err != nil {
+			return err
+		}
+		if gotSize := info.Size(); gotSize < wantMinSize {
+			return &CheckError{
+				Path:    path,
+				Check:   "MinSize",
+				Got:     gotSize,
+				Want:    wantMinSize,
+				message: fmt.Sprintf("has size %d, want >=%d", gotSize, wantMinSize),
+			}
+		}
+		return nil
+	}
+}
+
+// CheckModeIsRegular returns a PathCheck that verifies that the path is a
+// regular file.
+func CheckModeIsRegular() PathCheck {
+	return CheckModeType(0)
+}
+
+// CheckModePerm returns a PathCheck that verifies that the path's permissions
+// are equal to wantPerm.
+func CheckModePerm(wantPerm fs.FileMode) PathCheck {
+	return func(fileSystem vfs.FS, path string) error {
+		info, err := lstat(fileSystem, path, "ModePerm")
+		if err != nil {
+			return err
+		}
+		if gotPerm := info.Mode() & fs.ModePerm; !PermEqual(gotPerm, wantPerm) {
+			return &CheckError{
+				Path:    path,
+				Check:   "ModePerm",
+				Got:     gotPerm,
+				Want:    wantPerm,
+				message: fmt.Sprintf("has permissions 0%o, want 0%o", gotPerm, wantPerm),
+			}
+		}
+		return nil
+	}
+}
+
+// CheckModeType returns a PathCheck that verifies that the path's mode type is
+// equal to wantModeType.
+func CheckModeType(wantModeType fs.FileMode) PathCheck {
+	return func(fileSystem vfs.FS, path string) error {
+		info, err := lstat(fileSystem, path, "ModeType")
+		if err != nil {
+			return err
+		}
+		if gotModeType := info.Mode() & fs.ModeType; gotModeType != wantModeType {
+			return &CheckError{
+				Path:    path,
+				Check:   "ModeType",
+				Got:     gotModeType,
+				Want:    wantModeType,
+				message: fmt.Sprintf("is a %s, want a %s", modeTypeName(gotModeType), modeTypeName(wantModeType)),
+			}
+		}
+		return nil
+	}
+}
+
+// CheckSize returns a PathCheck that verifies that path's Size() is equal to
+// wantSize.
+func CheckSize(wantSize int64) PathCheck {
+	return func(fileSystem vfs.FS, path string) error {
+		info, err := lstat(fileSystem, path, "Size")
+		if err != nil {
+			return err
+		}
+		if gotSize := info.Size(); gotSize != wantSize {
+			return &CheckError{
+				Path:    path,
+				Check:   "Size",
+				Got:     gotSize,
+				Want:    wantSize,
+				message: fmt.Sprintf("has size %d, want %d", gotSize, wantSize),
+			}
+		}
+		return nil
+	}
+}
+
+// CheckSymlinkTarget returns a PathCheck that verifies that path's target is
+// wantTarget.
+func CheckSymlinkTarget(wantTarget string) PathCheck {
+	return func(fileSystem vfs.FS, path string) error {
+		gotTarget, err := fileSystem.Readlink(path)
+		if err != nil {
+			return &CheckError{
+				Path:  path,
+				Check: "SymlinkTarget",
+				Err:   err,
+			}
+		}
+		if gotTarget != wantTarget {
+			return &CheckError{
+				Path:    path,
+				Check:   "SymlinkTarget",
+				Got:     gotTarget,
+				Want:    wantTarget,
+				message: fmt.Sprintf("has target %s, want %s", gotTarget, wantTarget),
+			}
+		}
+		return nil
+	}
+}
+
+// CheckXattr returns a PathCheck that verifies that the extended attribute attr
+// of path, not following symbolic links, is equal to wantValue.
+func CheckXattr(attr string, wantValue []byte) PathCheck {
+	return func(fileSystem vfs.FS, path string) error {
+		xattrFS, ok := fileSystem.(vfs.XattrFS)
+		if !ok {
+			return &CheckError{
+				Path:  path,
+				Check: "Xattr",
+				Err:   errors.ErrUnsupported,
+			}
+		}
+		gotValue, err := xattrFS.Lgetxattr(path, attr)
+		if err != nil {
+			return &CheckError{
+				Path:  path,
+				Check: "Xattr",
+				Want:  wantValue,
+				Err:   err,
+			}
+		}
+		if !bytes.Equal(gotValue, wantValue) {
+			return &CheckError{
+				Path:    path,
+				Check:   "Xattr",
+				Got:     gotValue,
+				Want:    wantValue,
+				message: fmt.Sprintf("has extended attribute %s %q, want %q", attr, gotValue, wantValue),
+			}
+		}
+		return nil
+	}
+}
+
+// lstat returns the result of calling fileSystem.Lstat on path, wrapping any
+// error in a *CheckError for check.
+func lstat(fileSystem vfs.FS, path, check string) (fs.FileInfo, error) {
+	info, err := fileSystem.Lstat(path)
+	if err != nil {
+		return nil, &CheckError{
+			Path:  path,
+			Check: check,
+			Err:   err,
+		}
+	}
+	return info, nil
+}
+
+// readFile returns the result of calling fileSystem.ReadFile on path, wrapping
+// any error in a *CheckError for check.
+func readFile(fileSystem vfs.FS, path, check string) ([]byte, error) {
+	contents, err := fileSystem.ReadFile(path)
+	if err != nil {
+		return nil, &CheckError{
+			Path:  path,
+			Check: check,
+			Err:   err,
+		}
+	}
+	return contents, nil
+}
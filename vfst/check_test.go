@@ -0,0 +1,140 @@
+package vfst_test
+
+import (
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/alecthomas/assert/v2"
+
+	"github.com/twpayne/go-vfs/v5/vfst"
+)
+
+func TestCheckPath(t *testing.T) {
+	fileSystem := vfst.NewTestFSWithT(t, map[string]any{
+		"/home/user/.bashrc":      "# contents of .bashrc\n",
+		"/home/user/config.json":  `{"a": 1}`,
+		"/home/user/symlink":      &vfst.Symlink{Target: ".bashrc"},
+		"/home/user/subdir/empty": "",
+	})
+	for _, tc := range []struct {
+		name          string
+		path          string
+		pathCheck     vfst.PathCheck
+		expectedCheck string
+		expectedErr   error
+		expectedDiff  bool
+	}{
+		{
+			name:      "contents_string",
+			path:      "/home/user/.bashrc",
+			pathCheck: vfst.CheckContentsString("# contents of .bashrc\n"),
+		},
+		{
+			name:          "contents_string_mismatch",
+			path:          "/home/user/.bashrc",
+			pathCheck:     vfst.CheckContentsString(""),
+			expectedCheck: "ContentsString",
+		},
+		{
+			name:          "contents_not_exist",
+			path:          "/home/user/notexist",
+			pathCheck:     vfst.CheckContents(nil),
+			expectedCheck: "Contents",
+			expectedErr:   fs.ErrNotExist,
+		},
+		{
+			name:          "does_not_exist",
+			path:          "/home/user/.bashrc",
+			pathCheck:     vfst.CheckDoesNotExist(),
+			expectedCheck: "DoesNotExist",
+		},
+		{
+			name:          "is_dir",
+			path:          "/home/user/.bashrc",
+			pathCheck:     vfst.CheckIsDir(),
+			expectedCheck: "ModeType",
+		},
+		{
+			name:          "size",
+			path:          "/home/user/subdir/empty",
+			pathCheck:     vfst.CheckSize(1),
+			expectedCheck: "Size",
+		},
+		{
+			name:          "min_size",
+			path:          "/home/user/subdir/empty",
+			pathCheck:     vfst.CheckMinSize(1),
+			expectedCheck: "MinSize",
+		},
+		{
+			name:          "symlink_target",
+			path:          "/home/user/symlink",
+			pathCheck:     vfst.CheckSymlinkTarget("subdir"),
+			expectedCheck: "SymlinkTarget",
+		},
+		{
+			name:          "contents_json",
+			path:          "/home/user/config.json",
+			pathCheck:     vfst.CheckContentsJSON(`{"a": 2}`),
+			expectedCheck: "ContentsJSON",
+			expectedDiff:  true,
+		},
+		{
+			name:          "contents_unmarshal",
+			path:          "/home/user/config.json",
+			pathCheck:     vfst.CheckContentsUnmarshal(json.Unmarshal, []byte(`{"b": 1}`)),
+			expectedCheck: "ContentsUnmarshal",
+			expectedDiff:  true,
+		},
+		{
+			name:          "contents_line_set",
+			path:          "/home/user/.bashrc",
+			pathCheck:     vfst.CheckContentsLineSet("# other contents"),
+			expectedCheck: "ContentsLineSet",
+			expectedDiff:  true,
+		},
+		{
+			name:          "contents_match_regexp",
+			path:          "/home/user/.bashrc",
+			pathCheck:     vfst.CheckContentsMatchRegexp(regexp.MustCompile(`zsh`)),
+			expectedCheck: "ContentsMatchRegexp",
+		},
+		{
+			name:          "contents_contains",
+			path:          "/home/user/.bashrc",
+			pathCheck:     vfst.CheckContentsContains("zsh"),
+			expectedCheck: "ContentsContains",
+		},
+		{
+			name: "contents_func",
+			path: "/home/user/.bashrc",
+			pathCheck: vfst.CheckContentsFunc(func([]byte) error {
+				return errSentinel
+			}),
+			expectedCheck: "ContentsFunc",
+			expectedErr:   errSentinel,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			err := vfst.CheckPath(fileSystem, tc.path, tc.pathCheck)
+			if tc.expectedCheck == "" {
+				assert.NoError(t, err)
+				return
+			}
+			var checkError *vfst.CheckError
+			assert.True(t, errors.As(err, &checkError))
+			assert.Equal(t, tc.path, checkError.Path)
+			assert.Equal(t, tc.expectedCheck, checkError.Check)
+			if tc.expectedErr != nil {
+				assert.IsError(t, err, tc.expectedErr)
+			}
+			assert.Equal(t, tc.expectedDiff, strings.Contains(err.Error(), "\n@@"))
+		})
+	}
+}
+
+var errSentinel = errors.New("sentinel")
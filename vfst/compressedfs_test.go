@@ -0,0 +1,129 @@
+package vfst_test
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"io/fs"
+	"strings"
+	"testing"
+
+	"github.com/alecthomas/assert/v2"
+
+	vfs "github.com/twpayne/go-vfs/v5"
+	"github.com/twpayne/go-vfs/v5/vfst"
+)
+
+func TestCompressedFS(t *testing.T) {
+	fileSystem := vfst.NewTestFSWithT(t, map[string]any{
+		"/var/log": map[string]any{
+			"old.log": "written before compression\n",
+		},
+	})
+	compressedFS := vfs.NewCompressedFS(fileSystem, vfs.CompressedFSPolicy(func(name string) bool {
+		return strings.HasSuffix(name, ".log")
+	}))
+	contents := bytes.Repeat([]byte("GET / HTTP/1.1 200\n"), 1000)
+
+	assert.NoError(t, compressedFS.WriteFile("/var/log/access.log", contents, 0o644))
+	assert.NoError(t, compressedFS.WriteFile("/var/log/README", []byte("plain\n"), 0o644))
+
+	data, err := compressedFS.ReadFile("/var/log/access.log")
+	assert.NoError(t, err)
+	assert.True(t, bytes.Equal(contents, data))
+
+	info, err := compressedFS.Stat("/var/log/access.log")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(len(contents)), info.Size())
+	underlyingInfo, err := fileSystem.Stat("/var/log/access.log")
+	assert.NoError(t, err)
+	assert.True(t, underlyingInfo.Size() < info.Size()/10)
+
+	file, err := compressedFS.Open("/var/log/access.log")
+	assert.NoError(t, err)
+	data, err = io.ReadAll(file)
+	assert.NoError(t, err)
+	fileInfo, err := file.Stat()
+	assert.NoError(t, err)
+	assert.NoError(t, file.Close())
+	assert.True(t, bytes.Equal(contents, data))
+	assert.Equal(t, int64(len(contents)), fileInfo.Size())
+
+	dirEntries, err := compressedFS.ReadDir("/var/log")
+	assert.NoError(t, err)
+	sizes := make(map[string]int64)
+	for _, dirEntry := range dirEntries {
+		info, err := dirEntry.Info()
+		assert.NoError(t, err)
+		sizes[dirEntry.Name()] = info.Size()
+	}
+	assert.Equal(t, map[string]int64{
+		"README":     6,
+		"access.log": int64(len(contents)),
+		"old.log":    27,
+	}, sizes)
+
+	// Files written before compression are read unchanged.
+	data, err = compressedFS.ReadFile("/var/log/old.log")
+	assert.NoError(t, err)
+	assert.Equal(t, "written before compression\n", string(data))
+
+	// Renaming between compressed and uncompressed paths converts the file.
+	assert.NoError(t, compressedFS.Rename("/var/log/access.log", "/var/log/access.txt"))
+	assert.NoError(t, compressedFS.Rename("/var/log/README", "/var/log/README.log"))
+	assert.NoError(t, compressedFS.Truncate("/var/log/README.log", 3))
+
+	_, err = compressedFS.Create("/var/log/new.log")
+	assert.IsError(t, err, errors.ErrUnsupported)
+	file, err = compressedFS.Create("/var/log/new.txt")
+	assert.NoError(t, err)
+	assert.NoError(t, file.Close())
+
+	vfst.RunTests(t, fileSystem, "",
+		vfst.TestPath("/var/log/access.log",
+			vfst.TestDoesNotExist(),
+		),
+		vfst.TestPath("/var/log/access.txt",
+			vfst.TestContents(contents),
+		),
+		vfst.TestPath("/var/log/README.log",
+			vfst.TestModePerm(0o644),
+		),
+	)
+	data, err = fileSystem.ReadFile("/var/log/README.log")
+	assert.NoError(t, err)
+	assert.False(t, bytes.Equal([]byte("pla"), data))
+	data, err = compressedFS.ReadFile("/var/log/README.log")
+	assert.NoError(t, err)
+	assert.Equal(t, "pla", string(data))
+
+	// Files that cannot be opened are reported with their stored sizes.
+	unreadableFS := vfs.NewCompressedFS(&openErrorFS{
+		FS:   fileSystem,
+		name: "/var/log/README.log",
+		err:  fs.ErrPermission,
+	}, vfs.CompressedFSPolicy(func(name string) bool {
+		return strings.HasSuffix(name, ".log")
+	}))
+	info, err = unreadableFS.Stat("/var/log/README.log")
+	assert.NoError(t, err)
+	underlyingInfo, err = fileSystem.Stat("/var/log/README.log")
+	assert.NoError(t, err)
+	assert.Equal(t, underlyingInfo.Size(), info.Size())
+
+	// Files whose decompressed size does not match their header cannot be read.
+	assert.NoError(t, compressedFS.WriteFile("/var/log/corrupt.log", contents, 0o644))
+	data, err = fileSystem.ReadFile("/var/log/corrupt.log")
+	assert.NoError(t, err)
+	headerSize := bytes.Index(data, []byte{0x1f, 0x8b})
+	assert.True(t, headerSize > 0)
+	data[headerSize-1]++
+	assert.NoError(t, fileSystem.WriteFile("/var/log/corrupt.log", data, 0o644))
+	_, err = compressedFS.ReadFile("/var/log/corrupt.log")
+	assert.Error(t, err)
+	file, err = compressedFS.Open("/var/log/corrupt.log")
+	assert.NoError(t, err)
+	_, err = io.ReadAll(file)
+	assert.Error(t, err)
+	assert.NoError(t, file.Close())
+}
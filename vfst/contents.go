@@ -0,0 +1,260 @@
+package vfst
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/hexops/gotextdiff"
+	"github.com/hexops/gotextdiff/myers"
+	"github.com/hexops/gotextdiff/span"
+
+	vfs "github.com/twpayne/go-vfs/v5"
+)
+
+// An UnmarshalFunc unmarshals data into v. encoding/json.Unmarshal,
+// gopkg.in/yaml.v3.Unmarshal, and github.com/BurntSushi/toml.Unmarshal are all
+// UnmarshalFuncs.
+type UnmarshalFunc func(data []byte, v any) error
+
+// CheckContentsContains returns a PathCheck that verifies that the contents of
+// the file contain substr.
+func CheckContentsContains(substr string) PathCheck {
+	return func(fileSystem vfs.FS, path string) error {
+		gotContents, err := readFile(fileSystem, path, "ContentsContains")
+		if err != nil {
+			return err
+		}
+		if !bytes.Contains(gotContents, []byte(substr)) {
+			return &CheckError{
+				Path:    path,
+				Check:   "ContentsContains",
+				Got:     string(gotContents),
+				Want:    substr,
+				message: fmt.Sprintf("has contents %q, want contents containing %q", gotContents, substr),
+			}
+		}
+		return nil
+	}
+}
+
+// CheckContentsFunc returns a PathCheck that verifies that f returns nil when
+// called with the contents of the file.
+func CheckContentsFunc(f func([]byte) error) PathCheck {
+	return func(fileSystem vfs.FS, path string) error {
+		gotContents, err := readFile(fileSystem, path, "ContentsFunc")
+		if err != nil {
+			return err
+		}
+		if err := f(gotContents); err != nil {
+			return &CheckError{
+				Path:  path,
+				Check: "ContentsFunc",
+				Got:   string(gotContents),
+				Err:   err,
+			}
+		}
+		return nil
+	}
+}
+
+// CheckContentsJSON returns a PathCheck that verifies that the contents of the
+// file are semantically equal JSON to wantContentsJSON, ignoring differences in
+// whitespace and object key order.
+func CheckContentsJSON(wantContentsJSON string) PathCheck {
+	return func(fileSystem vfs.FS, path string) error {
+		gotContents, err := readFile(fileSystem, path, "ContentsJSON")
+		if err != nil {
+			return err
+		}
+		var gotValue, wantValue any
+		if err := json.Unmarshal(gotContents, &gotValue); err != nil {
+			return &CheckError{
+				Path:  path,
+				Check: "ContentsJSON",
+				Err:   err,
+			}
+		}
+		if err := json.Unmarshal([]byte(wantContentsJSON), &wantValue); err != nil {
+			return &CheckError{
+				Path:  path,
+				Check: "ContentsJSON",
+				Err:   fmt.Errorf("want: %w", err),
+			}
+		}
+		if !reflect.DeepEqual(gotValue, wantValue) {
+			gotIndented, _ := json.MarshalIndent(gotValue, "", "  ")
+			wantIndented, _ := json.MarshalIndent(wantValue, "", "  ")
+			return &CheckError{
+				Path:    path,
+				Check:   "ContentsJSON",
+				Got:     gotValue,
+				Want:    wantValue,
+				Diff:    unifiedDiff(string(wantIndented)+"\n", string(gotIndented)+"\n"),
+				message: "JSON contents differ",
+			}
+		}
+		return nil
+	}
+}
+
+// CheckContentsLineSet returns a PathCheck that verifies that the set of lines
+// in the file is equal to the set of wantLines, ignoring order and duplicates.
+func CheckContentsLineSet(wantLines ...string) PathCheck {
+	return func(fileSystem vfs.FS, path string) error {
+		gotContents, err := readFile(fileSystem, path, "ContentsLineSet")
+		if err != nil {
+			return err
+		}
+		var gotLineSet []string
+		if len(gotContents) != 0 {
+			gotLineSet = lineSet(strings.Split(strings.TrimSuffix(string(gotContents), "\n"), "\n"))
+		}
+		wantLineSet := lineSet(wantLines)
+		if !reflect.DeepEqual(gotLineSet, wantLineSet) {
+			return &CheckError{
+				Path:    path,
+				Check:   "ContentsLineSet",
+				Got:     gotLineSet,
+				Want:    wantLineSet,
+				Diff:    unifiedDiff(joinLines(wantLineSet), joinLines(gotLineSet)),
+				message: "sorted lines differ",
+			}
+		}
+		return nil
+	}
+}
+
+// CheckContentsMatchRegexp returns a PathCheck that verifies that the contents
+// of the file match re.
+func CheckContentsMatchRegexp(re *regexp.Regexp) PathCheck {
+	return func(fileSystem vfs.FS, path string) error {
+		gotContents, err := readFile(fileSystem, path, "ContentsMatchRegexp")
+		if err != nil {
+			return err
+		}
+		if !re.Match(gotContents) {
+			return &CheckError{
+				Path:    path,
+				Check:   "ContentsMatchRegexp",
+				Got:     string(gotContents),
+				Want:    re,
+				message: fmt.Sprintf("has contents %q, want contents matching %s", gotContents, re),
+			}
+		}
+		return nil
+	}
+}
+
+// CheckContentsUnmarshal returns a PathCheck that verifies that the contents of
+// the file and wantContents are semantically equal after both are unmarshaled
+// with unmarshal.
+func CheckContentsUnmarshal(unmarshal UnmarshalFunc, wantContents []byte) PathCheck {
+	return func(fileSystem vfs.FS, path string) error {
+		gotContents, err := readFile(fileSystem, path, "ContentsUnmarshal")
+		if err != nil {
+			return err
+		}
+		var gotValue, wantValue any
+		if err := unmarshal(gotContents, &gotValue); err != nil {
+			return &CheckError{
+				Path:  path,
+				Check: "ContentsUnmarshal",
+				Err:   err,
+			}
+		}
+		if err := unmarshal(wantContents, &wantValue); err != nil {
+			return &CheckError{
+				Path:  path,
+				Check: "ContentsUnmarshal",
+				Err:   fmt.Errorf("want: %w", err),
+			}
+		}
+		if !reflect.DeepEqual(gotValue, wantValue) {
+			return &CheckError{
+				Path:    path,
+				Check:   "ContentsUnmarshal",
+				Got:     gotValue,
+				Want:    wantValue,
+				Diff:    unifiedDiff(string(wantContents), string(gotContents)),
+				message: "contents differ",
+			}
+		}
+		return nil
+	}
+}
+
+// TestContentsContains returns a PathTest that verifies that the contents of
+// the file contain substr.
+func TestContentsContains(substr string) PathTest {
+	return TestCheck(CheckContentsContains(substr))
+}
+
+// TestContentsFunc returns a PathTest that verifies that f returns nil when
+// called with the contents of the file.
+func TestContentsFunc(f func([]byte) error) PathTest {
+	return TestCheck(CheckContentsFunc(f))
+}
+
+// TestContentsJSON returns a PathTest that verifies that the contents of the
+// file are semantically equal JSON to wantContentsJSON, ignoring differences
+// in whitespace and object key order.
+func TestContentsJSON(wantContentsJSON string) PathTest {
+	return TestCheck(CheckContentsJSON(wantContentsJSON))
+}
+
+// TestContentsLineSet returns a PathTest that verifies that the set of lines in
+// the file is equal to the set of wantLines, ignoring order and duplicates.
+func TestContentsLineSet(wantLines ...string) PathTest {
+	return TestCheck(CheckContentsLineSet(wantLines...))
+}
+
+// TestContentsMatchRegexp returns a PathTest that verifies that the contents of
+// the file match re.
+func TestContentsMatchRegexp(re *regexp.Regexp) PathTest {
+	return TestCheck(CheckContentsMatchRegexp(re))
+}
+
+// TestContentsUnmarshal returns a PathTest that verifies that the contents of
+// the file and wantContents are semantically equal after both are unmarshaled
+// with unmarshal. It can be used to compare YAML or TOML files, for example.
+func TestContentsUnmarshal(unmarshal UnmarshalFunc, wantContents []byte) PathTest {
+	return TestCheck(CheckContentsUnmarshal(unmarshal, wantContents))
+}
+
+// joinLines joins lines, terminating each with a newline.
+func joinLines(lines []string) string {
+	var sb strings.Builder
+	for _, line := range lines {
+		sb.WriteString(line)
+		sb.WriteByte('\n')
+	}
+	return sb.String()
+}
+
+// lineSet returns the sorted distinct elements of lines.
+func lineSet(lines []string) []string {
+	if len(lines) == 0 {
+		return nil
+	}
+	set := make(map[string]struct{}, len(lines))
+	for _, line := range lines {
+		set[line] = struct{}{}
+	}
+	result := make([]string, 0, len(set))
+	for line := range set {
+		result = append(result, line)
+	}
+	sort.Strings(result)
+	return result
+}
+
+// unifiedDiff returns a unified diff from want to got.
+func unifiedDiff(want, got string) string {
+	edits := myers.ComputeEdits(span.URIFromPath("want"), want, got)
+	return fmt.Sprint(gotextdiff.ToUnified("want", "got", want, edits))
+}
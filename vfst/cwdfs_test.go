@@ -0,0 +1,126 @@
+package vfst_test
+
+import (
+	"errors"
+	"io/fs"
+	"path/filepath"
+	"runtime"
+	"syscall"
+	"testing"
+
+	"github.com/alecthomas/assert/v2"
+
+	vfs "github.com/twpayne/go-vfs/v5"
+	"github.com/twpayne/go-vfs/v5/vfst"
+)
+
+func TestCwdFS(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("symlinks are not supported on Windows")
+	}
+	fileSystem := vfst.NewTestFSWithT(t, map[string]any{
+		"/home/user": map[string]any{
+			".bashrc": "# .bashrc\n",
+			"src": map[string]any{
+				"a.go": "package a\n",
+				"b.go": "package b\n",
+			},
+		},
+	})
+	cwdFS, err := vfs.NewCwdFS(fileSystem, "/home/user")
+	assert.NoError(t, err)
+
+	data, err := cwdFS.ReadFile(".bashrc")
+	assert.NoError(t, err)
+	assert.Equal(t, "# .bashrc\n", string(data))
+
+	assert.NoError(t, cwdFS.Chdir("src"))
+	cwd, err := cwdFS.Getwd()
+	assert.NoError(t, err)
+	assert.Equal(t, filepath.FromSlash("/home/user/src"), cwd)
+
+	matches, err := cwdFS.Glob("*.go")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"a.go", "b.go"}, matches)
+	matches, err = cwdFS.Glob("../.bash*")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{filepath.FromSlash("../.bashrc")}, matches)
+	matches, err = cwdFS.Glob("/home/user/src/a.*")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{filepath.FromSlash("/home/user/src/a.go")}, matches)
+
+	assert.NoError(t, cwdFS.WriteFile("c.go", []byte("package c\n"), 0o644))
+	assert.NoError(t, cwdFS.Mkdir("../bin", 0o755))
+	assert.NoError(t, cwdFS.Rename("c.go", "../bin/c.go"))
+	assert.NoError(t, cwdFS.Symlink("../.bashrc", "bashrc"))
+	target, err := cwdFS.Readlink("bashrc")
+	assert.NoError(t, err)
+	assert.Equal(t, "../.bashrc", target)
+	data, err = cwdFS.ReadFile("bashrc")
+	assert.NoError(t, err)
+	assert.Equal(t, "# .bashrc\n", string(data))
+
+	assert.IsError(t, cwdFS.Chdir("a.go"), syscall.ENOTDIR)
+	assert.IsError(t, cwdFS.Chdir("missing"), fs.ErrNotExist)
+	cwd, err = cwdFS.Getwd()
+	assert.NoError(t, err)
+	assert.Equal(t, filepath.FromSlash("/home/user/src"), cwd)
+
+	assert.NoError(t, cwdFS.Chdir("/home"))
+	_, err = cwdFS.Lstat("user/bin/c.go")
+	assert.NoError(t, err)
+
+	vfst.RunTests(t, fileSystem, "",
+		vfst.TestPath("/home/user/bin/c.go",
+			vfst.TestContentsString("package c\n"),
+		),
+		vfst.TestPath("/home/user/src/bashrc",
+			vfst.TestModeType(fs.ModeSymlink),
+			vfst.TestSymlinkTarget("../.bashrc"),
+		),
+		vfst.TestPath("/home/user/src/c.go",
+			vfst.TestDoesNotExist(),
+		),
+	)
+}
+
+func TestCwdFSRelativeCwd(t *testing.T) {
+	fileSystem := vfst.NewEmptyTestFSWithT(t)
+	_, err := vfs.NewCwdFS(fileSystem, "home/user")
+	assert.IsError(t, err, fs.ErrInvalid)
+}
+
+func TestCwdFSCapabilities(t *testing.T) {
+	fileSystem := vfst.NewTestFSWithT(t, map[string]any{
+		"/home/user/.bashrc": "# .bashrc\n",
+	})
+	cwdFS, err := vfs.NewCwdFS(fileSystem, "/home/user")
+	assert.NoError(t, err)
+
+	if _, ok := any(vfs.OSFS).(vfs.Locker); ok {
+		lock, err := cwdFS.Lock(".bashrc", vfs.LockExclusive, false)
+		assert.NoError(t, err)
+		_, err = fileSystem.Lock("/home/user/.bashrc", vfs.LockExclusive, false)
+		assert.IsError(t, err, vfs.ErrLocked)
+		assert.NoError(t, lock.Unlock())
+	}
+
+	if _, ok := any(vfs.OSFS).(vfs.Statfser); ok {
+		fsStat, err := cwdFS.Statfs(".bashrc")
+		assert.NoError(t, err)
+		assert.NotZero(t, fsStat.TotalBytes)
+		_, err = cwdFS.Statfs("missing")
+		assert.IsError(t, err, fs.ErrNotExist)
+	}
+
+	// Optional capabilities that the underlying FS does not implement are
+	// unsupported.
+	bareCwdFS, err := vfs.NewCwdFS(struct{ vfs.FS }{fileSystem}, "/home/user")
+	assert.NoError(t, err)
+	_, err = bareCwdFS.Lock(".bashrc", vfs.LockShared, false)
+	assert.IsError(t, err, errors.ErrUnsupported)
+	_, err = bareCwdFS.Statfs(".bashrc")
+	assert.IsError(t, err, errors.ErrUnsupported)
+	_, err = bareCwdFS.Getxattr(".bashrc", "user.attr")
+	assert.IsError(t, err, errors.ErrUnsupported)
+}
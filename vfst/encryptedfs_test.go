@@ -0,0 +1,167 @@
+package vfst_test
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"io/fs"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"syscall"
+	"testing"
+	"testing/iotest"
+
+	"github.com/alecthomas/assert/v2"
+
+	vfs "github.com/twpayne/go-vfs/v5"
+	"github.com/twpayne/go-vfs/v5/vfst"
+)
+
+func TestEncryptedFS(t *testing.T) {
+	key := []byte("0123456789abcdef")
+	largeContents := bytes.Repeat([]byte("0123456789"), 20000)
+
+	for _, encryptNames := range []bool{false, true} {
+		name := "contents"
+		if encryptNames {
+			name = "names"
+		}
+		t.Run(name, func(t *testing.T) {
+			fileSystem := vfst.NewEmptyTestFSWithT(t)
+			encryptedFS, err := vfs.NewEncryptedFS(fileSystem, key, vfs.EncryptedFSEncryptNames(encryptNames))
+			assert.NoError(t, err)
+			assert.NoError(t, vfs.MkdirAll(encryptedFS, "/home/user", 0o755))
+
+			for _, contents := range [][]byte{nil, []byte("secret\n"), largeContents, largeContents[:64<<10]} {
+				assert.NoError(t, encryptedFS.WriteFile("/home/user/secret", contents, 0o600))
+
+				data, err := encryptedFS.ReadFile("/home/user/secret")
+				assert.NoError(t, err)
+				assert.Equal(t, len(contents), len(data))
+				assert.True(t, bytes.Equal(contents, data))
+
+				info, err := encryptedFS.Stat("/home/user/secret")
+				assert.NoError(t, err)
+				assert.Equal(t, int64(len(contents)), info.Size())
+				assert.Equal(t, "secret", info.Name())
+
+				file, err := encryptedFS.Open("/home/user/secret")
+				assert.NoError(t, err)
+				data, err = io.ReadAll(file)
+				assert.NoError(t, err)
+				assert.NoError(t, file.Close())
+				assert.True(t, bytes.Equal(contents, data))
+
+				// Streamed writes produce the same plaintext.
+				assert.NoError(t, encryptedFS.WriteFileFrom("/home/user/streamed", iotest.HalfReader(bytes.NewReader(contents)), 0o600))
+				data, err = encryptedFS.ReadFile("/home/user/streamed")
+				assert.NoError(t, err)
+				assert.True(t, bytes.Equal(contents, data))
+				assert.NoError(t, encryptedFS.Remove("/home/user/streamed"))
+			}
+
+			dirEntries, err := encryptedFS.ReadDir("/home/user")
+			assert.NoError(t, err)
+			assert.Equal(t, 1, len(dirEntries))
+			assert.Equal(t, "secret", dirEntries[0].Name())
+			info, err := dirEntries[0].Info()
+			assert.NoError(t, err)
+			assert.Equal(t, int64(64<<10), info.Size())
+
+			assert.NoError(t, encryptedFS.Truncate("/home/user/secret", 4))
+			data, err := encryptedFS.ReadFile("/home/user/secret")
+			assert.NoError(t, err)
+			assert.Equal(t, "0123", string(data))
+
+			matches, err := encryptedFS.Glob("/home/*/sec*")
+			assert.NoError(t, err)
+			assert.Equal(t, []string{filepath.FromSlash("/home/user/secret")}, matches)
+
+			if runtime.GOOS != "windows" {
+				assert.NoError(t, encryptedFS.Symlink("secret", "/home/user/link"))
+				target, err := encryptedFS.Readlink("/home/user/link")
+				assert.NoError(t, err)
+				assert.Equal(t, "secret", target)
+				data, err = encryptedFS.ReadFile("/home/user/link")
+				assert.NoError(t, err)
+				assert.Equal(t, "0123", string(data))
+			}
+
+			// The underlying FS contains only ciphertext.
+			assert.NoError(t, vfs.Walk(fileSystem, "/", func(path string, info fs.FileInfo, err error) error {
+				assert.NoError(t, err)
+				if path == "/" {
+					return nil
+				}
+				assert.Equal(t, encryptNames, !strings.Contains(path, "home"), path)
+				if info.Mode().IsRegular() {
+					data, err := fileSystem.ReadFile(path)
+					assert.NoError(t, err)
+					assert.False(t, bytes.Contains(data, []byte("0123")))
+				}
+				return nil
+			}))
+
+			// Ciphertext encrypted with a different key cannot be decrypted.
+			otherEncryptedFS, err := vfs.NewEncryptedFS(fileSystem, []byte("fedcba9876543210"))
+			assert.NoError(t, err)
+			if !encryptNames {
+				_, err = otherEncryptedFS.ReadFile("/home/user/secret")
+				assert.IsError(t, err, vfs.ErrDecrypt)
+			}
+
+			_, err = encryptedFS.Create("/home/user/file")
+			assert.IsError(t, err, errors.ErrUnsupported)
+
+			// Streamed writes that fail cannot be decrypted.
+			errRead := errors.New("read error")
+			assert.IsError(t, encryptedFS.WriteFileFrom("/home/user/secret", io.MultiReader(bytes.NewReader(largeContents), iotest.ErrReader(errRead)), 0o600), errRead)
+			_, err = encryptedFS.ReadFile("/home/user/secret")
+			assert.IsError(t, err, vfs.ErrDecrypt)
+
+			// Long names can only be used if names are not encrypted.
+			longName := "/home/user/" + strings.Repeat("x", 164)
+			err = encryptedFS.WriteFile(longName, nil, 0o600)
+			if encryptNames {
+				assert.IsError(t, err, syscall.ENAMETOOLONG)
+				assert.NoError(t, encryptedFS.WriteFile(longName[:len(longName)-1], nil, 0o600))
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestEncryptedFSTampering(t *testing.T) {
+	fileSystem := vfst.NewTestFSWithT(t, map[string]any{
+		"/home/user": &vfst.Dir{Perm: 0o755},
+	})
+	encryptedFS, err := vfs.NewEncryptedFS(fileSystem, []byte("0123456789abcdef"))
+	assert.NoError(t, err)
+	assert.NoError(t, encryptedFS.WriteFile("/home/user/secret", bytes.Repeat([]byte("x"), 100000), 0o600))
+	ciphertext, err := fileSystem.ReadFile("/home/user/secret")
+	assert.NoError(t, err)
+
+	for name, tamperedCiphertext := range map[string][]byte{
+		"flipped":   append(append([]byte(nil), ciphertext[:100]...), append([]byte{ciphertext[100] ^ 1}, ciphertext[101:]...)...),
+		"truncated": ciphertext[:64<<10+16+20],
+		"short":     ciphertext[:10],
+	} {
+		t.Run(name, func(t *testing.T) {
+			assert.NoError(t, fileSystem.WriteFile("/home/user/secret", tamperedCiphertext, 0o600))
+			_, err := encryptedFS.ReadFile("/home/user/secret")
+			assert.IsError(t, err, vfs.ErrDecrypt)
+			if file, err := encryptedFS.Open("/home/user/secret"); err == nil {
+				_, err = io.ReadAll(file)
+				assert.IsError(t, err, vfs.ErrDecrypt)
+				assert.NoError(t, file.Close())
+			} else {
+				assert.IsError(t, err, vfs.ErrDecrypt)
+			}
+		})
+	}
+
+	_, err = vfs.NewEncryptedFS(fileSystem, []byte("short"))
+	assert.Error(t, err)
+}
@@ -0,0 +1,171 @@
+package vfst_test
+
+import (
+	"io"
+	"io/fs"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alecthomas/assert/v2"
+
+	vfs "github.com/twpayne/go-vfs/v5"
+	"github.com/twpayne/go-vfs/v5/vfst"
+)
+
+func TestHTTPFS(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("symlinks are not supported on Windows")
+	}
+
+	fileSystem := vfst.NewTestFSWithT(t, map[string]any{
+		"/srv": map[string]any{
+			"secret": "secret\n",
+			"www": map[string]any{
+				".git/config": "[core]\n",
+				"docs": map[string]any{
+					"index.html": "<h1>docs</h1>\n",
+				},
+				"escape": &vfst.Symlink{Target: "../secret"},
+				"inside": &vfst.Symlink{Target: "docs/index.html"},
+				"list": map[string]any{
+					".hidden": "hidden\n",
+					"visible": "visible\n",
+				},
+				"page.txt": "0123456789",
+			},
+		},
+	})
+	modTime := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+	assert.NoError(t, fileSystem.Chtimes("/srv/www/page.txt", modTime, modTime))
+
+	get := func(t *testing.T, handler http.Handler, path string, header map[string]string) (int, string) {
+		t.Helper()
+		server := httptest.NewServer(handler)
+		defer server.Close()
+		req, err := http.NewRequest(http.MethodGet, server.URL+path, nil)
+		assert.NoError(t, err)
+		for key, value := range header {
+			req.Header.Set(key, value)
+		}
+		resp, err := server.Client().Do(req)
+		assert.NoError(t, err)
+		defer resp.Body.Close()
+		body, err := io.ReadAll(resp.Body)
+		assert.NoError(t, err)
+		return resp.StatusCode, string(body)
+	}
+
+	t.Run("default", func(t *testing.T) {
+		handler := vfs.NewHTTPHandler(fileSystem, "/srv/www")
+
+		status, body := get(t, handler, "/page.txt", nil)
+		assert.Equal(t, http.StatusOK, status)
+		assert.Equal(t, "0123456789", body)
+
+		status, body = get(t, handler, "/page.txt", map[string]string{"Range": "bytes=2-4"})
+		assert.Equal(t, http.StatusPartialContent, status)
+		assert.Equal(t, "234", body)
+
+		status, _ = get(t, handler, "/page.txt", map[string]string{
+			"If-Modified-Since": modTime.Format(http.TimeFormat),
+		})
+		assert.Equal(t, http.StatusNotModified, status)
+		status, _ = get(t, handler, "/page.txt", map[string]string{
+			"If-Modified-Since": modTime.Add(-time.Hour).Format(http.TimeFormat),
+		})
+		assert.Equal(t, http.StatusOK, status)
+
+		status, body = get(t, handler, "/list/", nil)
+		assert.Equal(t, http.StatusOK, status)
+		assert.True(t, strings.Contains(body, ".hidden"))
+		assert.True(t, strings.Contains(body, "visible"))
+
+		status, body = get(t, handler, "/escape", nil)
+		assert.Equal(t, http.StatusOK, status)
+		assert.Equal(t, "secret\n", body)
+	})
+
+	t.Run("restricted", func(t *testing.T) {
+		handler := vfs.NewHTTPHandler(fileSystem, "/srv/www",
+			vfs.HTTPFSConfineSymlinks(true),
+			vfs.HTTPFSHideDotfiles(true),
+			vfs.HTTPFSListDirectories(false),
+		)
+
+		status, _ := get(t, handler, "/.git/config", nil)
+		assert.Equal(t, http.StatusNotFound, status)
+		status, _ = get(t, handler, "/list/.hidden", nil)
+		assert.Equal(t, http.StatusNotFound, status)
+
+		status, _ = get(t, handler, "/list/", nil)
+		assert.Equal(t, http.StatusNotFound, status)
+		status, body := get(t, handler, "/docs/", nil)
+		assert.Equal(t, http.StatusOK, status)
+		assert.Equal(t, "<h1>docs</h1>\n", body)
+
+		status, _ = get(t, handler, "/escape", nil)
+		assert.Equal(t, http.StatusForbidden, status)
+		status, body = get(t, handler, "/inside", nil)
+		assert.Equal(t, http.StatusOK, status)
+		assert.Equal(t, "<h1>docs</h1>\n", body)
+	})
+
+	t.Run("listing", func(t *testing.T) {
+		httpFS := vfs.NewHTTPFS(fileSystem, "/srv/www",
+			vfs.HTTPFSConfineSymlinks(true),
+			vfs.HTTPFSHideDotfiles(true),
+		)
+		dir, err := httpFS.Open("/")
+		assert.NoError(t, err)
+		infos, err := dir.Readdir(-1)
+		assert.NoError(t, err)
+		assert.NoError(t, dir.Close())
+		names := make([]string, 0, len(infos))
+		for _, info := range infos {
+			names = append(names, info.Name())
+		}
+		assert.Equal(t, []string{"docs", "inside", "list", "page.txt"}, names)
+	})
+}
+
+func TestHTTPFSRelativeRoot(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("symlinks are not supported on Windows")
+	}
+
+	fileSystem := vfst.NewTestFSWithT(t, map[string]any{
+		"/srv": map[string]any{
+			"secret": "secret\n",
+			"public": map[string]any{
+				"escape":     &vfst.Symlink{Target: "../secret"},
+				"index.html": "<h1>index</h1>\n",
+				"inside":     &vfst.Symlink{Target: "./index.html"},
+			},
+		},
+	})
+	srvDir, err := fileSystem.RawPath("/srv")
+	assert.NoError(t, err)
+	wd, err := os.Getwd()
+	assert.NoError(t, err)
+	assert.NoError(t, os.Chdir(srvDir))
+	defer func() {
+		assert.NoError(t, os.Chdir(wd))
+	}()
+
+	httpFS := vfs.NewHTTPFS(vfs.OSFS, "public", vfs.HTTPFSConfineSymlinks(true))
+	for _, name := range []string{"/index.html", "/inside"} {
+		file, err := httpFS.Open(name)
+		assert.NoError(t, err)
+		data, err := io.ReadAll(file)
+		assert.NoError(t, err)
+		assert.Equal(t, "<h1>index</h1>\n", string(data))
+		assert.NoError(t, file.Close())
+	}
+	_, err = httpFS.Open("/escape")
+	assert.IsError(t, err, fs.ErrPermission)
+}
@@ -0,0 +1,242 @@
+package vfst_test
+
+import (
+	"errors"
+	"io"
+	"io/fs"
+	"testing"
+
+	"github.com/alecthomas/assert/v2"
+
+	vfs "github.com/twpayne/go-vfs/v5"
+	"github.com/twpayne/go-vfs/v5/vfst"
+)
+
+func TestIntegrityFS(t *testing.T) {
+	fileSystem := vfst.NewTestFSWithT(t, map[string]any{
+		"/home/user": map[string]any{
+			"unchecked": "written before integrity\n",
+		},
+		"/var/lib": &vfst.Dir{Perm: 0o755},
+	})
+	integrityFS, err := vfs.NewIntegrityFS(fileSystem, "/var/lib/manifest.json")
+	assert.NoError(t, err)
+	testIntegrityFS(t, fileSystem, integrityFS)
+
+	// Checksums persist in the manifest.
+	integrityFS, err = vfs.NewIntegrityFS(fileSystem, "/var/lib/manifest.json")
+	assert.NoError(t, err)
+	_, err = integrityFS.ReadFile("/home/user/dir2/b")
+	var checksumMismatchError *vfs.ChecksumMismatchError
+	assert.True(t, errors.As(err, &checksumMismatchError))
+	data, err := integrityFS.ReadFile("/home/user/dir2/a")
+	assert.NoError(t, err)
+	assert.Equal(t, "a\n", string(data))
+
+	// Hard links are not supported with a manifest.
+	assert.IsError(t, integrityFS.Link("/home/user/dir2/a", "/home/user/dir2/c"), errors.ErrUnsupported)
+	vfst.RunTests(t, fileSystem, "",
+		vfst.TestPath("/home/user/dir2/c",
+			vfst.TestDoesNotExist(),
+		),
+	)
+
+	// Scrub continues after errors reading individual files.
+	errRead := errors.New("read error")
+	integrityFS, err = vfs.NewIntegrityFS(&openErrorFS{
+		FS:   fileSystem,
+		name: "/home/user/dir2/a",
+		err:  errRead,
+	}, "/var/lib/manifest.json")
+	assert.NoError(t, err)
+	mismatches, err := integrityFS.Scrub("/home/user")
+	assert.IsError(t, err, errRead)
+	assert.Equal(t, 1, len(mismatches))
+	assert.Equal(t, "/home/user/dir2/b", mismatches[0].Path)
+}
+
+// An openErrorFS is an FS whose Open returns err for name.
+type openErrorFS struct {
+	vfs.FS
+	name string
+	err  error
+}
+
+func (f *openErrorFS) Open(name string) (fs.File, error) {
+	if name == f.name {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: f.err}
+	}
+	return f.FS.Open(name)
+}
+
+func TestIntegrityFSWriteFileError(t *testing.T) {
+	fileSystem := vfst.NewTestFSWithT(t, map[string]any{
+		"/home/user": &vfst.Dir{Perm: 0o755},
+		"/var/lib":   &vfst.Dir{Perm: 0o755},
+	})
+	integrityFS, err := vfs.NewIntegrityFS(fileSystem, "/var/lib/manifest.json")
+	assert.NoError(t, err)
+	assert.NoError(t, integrityFS.WriteFile("/home/user/file", []byte("v1\n"), 0o644))
+
+	// If recording the new checksum fails then the file has no checksum.
+	errWrite := errors.New("write error")
+	integrityFS, err = vfs.NewIntegrityFS(&writeErrorFS{
+		FS:   fileSystem,
+		name: "/var/lib/manifest.json.tmp",
+		skip: 1,
+		err:  errWrite,
+	}, "/var/lib/manifest.json")
+	assert.NoError(t, err)
+	assert.IsError(t, integrityFS.WriteFile("/home/user/file", []byte("v2\n"), 0o644), errWrite)
+	integrityFS, err = vfs.NewIntegrityFS(fileSystem, "/var/lib/manifest.json")
+	assert.NoError(t, err)
+	data, err := integrityFS.ReadFile("/home/user/file")
+	assert.NoError(t, err)
+	assert.Equal(t, "v2\n", string(data))
+}
+
+// A writeErrorFS is an FS whose WriteFile returns err for name after skip
+// successful calls.
+type writeErrorFS struct {
+	vfs.FS
+	name string
+	skip int
+	err  error
+}
+
+func (f *writeErrorFS) WriteFile(filename string, data []byte, perm fs.FileMode) error {
+	if filename == f.name {
+		if f.skip == 0 {
+			return &fs.PathError{Op: "write", Path: filename, Err: f.err}
+		}
+		f.skip--
+	}
+	return f.FS.WriteFile(filename, data, perm)
+}
+
+func TestXattrIntegrityFS(t *testing.T) {
+	fileSystem := vfst.NewTestFSWithT(t, map[string]any{
+		"/home/user": map[string]any{
+			"unchecked": "written before integrity\n",
+		},
+	})
+	if err := fileSystem.Setxattr("/home/user/unchecked", "user.probe", nil); err != nil {
+		t.Skipf("extended attributes are not supported: %v", err)
+	}
+	assert.NoError(t, fileSystem.Removexattr("/home/user/unchecked", "user.probe"))
+	integrityFS, err := vfs.NewXattrIntegrityFS(fileSystem)
+	assert.NoError(t, err)
+	testIntegrityFS(t, fileSystem, integrityFS)
+
+	// Wrappers forward extended attributes.
+	_, err = vfs.NewXattrIntegrityFS(vfs.NewMetricsFS(fileSystem))
+	assert.NoError(t, err)
+	_, err = vfs.NewXattrIntegrityFS(struct{ vfs.FS }{fileSystem})
+	assert.IsError(t, err, errors.ErrUnsupported)
+}
+
+func TestXattrIntegrityFSUnsupported(t *testing.T) {
+	fileSystem := vfst.NewTestFSWithT(t, map[string]any{
+		"/home/user/file": "contents\n",
+	})
+	integrityFS, err := vfs.NewXattrIntegrityFS(&noXattrFS{TestFS: fileSystem})
+	assert.NoError(t, err)
+
+	// Files are read and opened for writing without checksums.
+	data, err := integrityFS.ReadFile("/home/user/file")
+	assert.NoError(t, err)
+	assert.Equal(t, "contents\n", string(data))
+	file, err := integrityFS.Create("/home/user/file")
+	assert.NoError(t, err)
+	assert.NoError(t, file.Close())
+
+	// Checksums cannot be recorded.
+	assert.IsError(t, integrityFS.WriteFile("/home/user/file", []byte("new\n"), 0o644), errors.ErrUnsupported)
+}
+
+// A noXattrFS is a *vfst.TestFS on a filesystem that does not support extended
+// attributes.
+type noXattrFS struct {
+	*vfst.TestFS
+}
+
+func (f *noXattrFS) Getxattr(name, attr string) ([]byte, error) {
+	return nil, &fs.PathError{Op: "getxattr", Path: name, Err: errors.ErrUnsupported}
+}
+
+func (f *noXattrFS) Removexattr(name, attr string) error {
+	return &fs.PathError{Op: "removexattr", Path: name, Err: errors.ErrUnsupported}
+}
+
+func (f *noXattrFS) Setxattr(name, attr string, value []byte) error {
+	return &fs.PathError{Op: "setxattr", Path: name, Err: errors.ErrUnsupported}
+}
+
+func testIntegrityFS(t *testing.T, fileSystem *vfst.TestFS, integrityFS *vfs.IntegrityFS) {
+	t.Helper()
+
+	assert.NoError(t, integrityFS.WriteFile("/home/user/file", []byte("contents\n"), 0o644))
+	data, err := integrityFS.ReadFile("/home/user/file")
+	assert.NoError(t, err)
+	assert.Equal(t, "contents\n", string(data))
+
+	// Files without checksums are not verified.
+	data, err = integrityFS.ReadFile("/home/user/unchecked")
+	assert.NoError(t, err)
+	assert.Equal(t, "written before integrity\n", string(data))
+
+	// Corruption is detected by ReadFile and Open.
+	assert.NoError(t, fileSystem.WriteFile("/home/user/file", []byte("corrupt\n"), 0o644))
+	_, err = integrityFS.ReadFile("/home/user/file")
+	var checksumMismatchError *vfs.ChecksumMismatchError
+	assert.True(t, errors.As(err, &checksumMismatchError))
+	assert.Equal(t, "/home/user/file", checksumMismatchError.Path)
+	assert.NotEqual(t, checksumMismatchError.Want, checksumMismatchError.Got)
+	file, err := integrityFS.Open("/home/user/file")
+	assert.NoError(t, err)
+	_, err = io.ReadAll(file)
+	assert.True(t, errors.As(err, &checksumMismatchError))
+	assert.NoError(t, file.Close())
+
+	// Truncate verifies the file before truncating it.
+	assert.True(t, errors.As(integrityFS.Truncate("/home/user/file", 1), &checksumMismatchError))
+	assert.NoError(t, integrityFS.WriteFile("/home/user/file", []byte("contents\n"), 0o644))
+	assert.NoError(t, integrityFS.Truncate("/home/user/file", 4))
+	file, err = integrityFS.Open("/home/user/file")
+	assert.NoError(t, err)
+	data, err = io.ReadAll(file)
+	assert.NoError(t, err)
+	assert.NoError(t, file.Close())
+	assert.Equal(t, "cont", string(data))
+
+	// Checksums follow renames and are removed with files.
+	assert.NoError(t, integrityFS.Mkdir("/home/user/dir", 0o755))
+	assert.NoError(t, integrityFS.WriteFile("/home/user/dir/a", []byte("a\n"), 0o644))
+	assert.NoError(t, integrityFS.WriteFile("/home/user/dir/b", []byte("b\n"), 0o644))
+	assert.NoError(t, integrityFS.Rename("/home/user/dir", "/home/user/dir2"))
+	assert.NoError(t, fileSystem.WriteFile("/home/user/dir2/b", []byte("x\n"), 0o644))
+	assert.NoError(t, integrityFS.Remove("/home/user/file"))
+	assert.NoError(t, fileSystem.WriteFile("/home/user/file", []byte("new\n"), 0o644))
+	data, err = integrityFS.ReadFile("/home/user/file")
+	assert.NoError(t, err)
+	assert.Equal(t, "new\n", string(data))
+
+	// Opening a file for writing removes its checksum.
+	assert.NoError(t, integrityFS.WriteFile("/home/user/file", []byte("checked\n"), 0o644))
+	f, err := integrityFS.Create("/home/user/file")
+	assert.NoError(t, err)
+	_, err = f.WriteString("unchecked\n")
+	assert.NoError(t, err)
+	assert.NoError(t, f.Close())
+	data, err = integrityFS.ReadFile("/home/user/file")
+	assert.NoError(t, err)
+	assert.Equal(t, "unchecked\n", string(data))
+
+	mismatches, err := integrityFS.Scrub("/home/user")
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(mismatches))
+	assert.Equal(t, "/home/user/dir2/b", mismatches[0].Path)
+
+	_, err = integrityFS.Scrub("/home/user/missing")
+	assert.IsError(t, err, fs.ErrNotExist)
+}
@@ -0,0 +1,84 @@
+package vfst_test
+
+import (
+	"errors"
+	"io/fs"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/alecthomas/assert/v2"
+
+	vfs "github.com/twpayne/go-vfs/v5"
+	"github.com/twpayne/go-vfs/v5/vfst"
+)
+
+func TestLchtimes(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("symlinks are not supported on Windows")
+	}
+	fileSystem := vfst.NewTestFSWithT(t, map[string]any{
+		"/home/user/.bashrc":  "# .bashrc\n",
+		"/home/user/.profile": &vfst.Symlink{Target: ".bashrc"},
+	})
+	bashrcInfo, err := fileSystem.Lstat("/home/user/.bashrc")
+	assert.NoError(t, err)
+
+	mtime := time.Date(2001, 2, 3, 4, 5, 6, 0, time.UTC)
+	if _, ok := any(vfs.OSFS).(vfs.Lchtimeser); ok {
+		assert.NoError(t, vfs.Lchtimes(fileSystem, "/home/user/.profile", mtime, mtime))
+		profileInfo, err := fileSystem.Lstat("/home/user/.profile")
+		assert.NoError(t, err)
+		assert.True(t, profileInfo.ModTime().Equal(mtime))
+
+		assert.NoError(t, vfs.Lchtimes(fileSystem, "/home/user/.profile", time.Time{}, time.Time{}))
+		profileInfo, err = fileSystem.Lstat("/home/user/.profile")
+		assert.NoError(t, err)
+		assert.True(t, profileInfo.ModTime().Equal(mtime))
+	}
+
+	newBashrcInfo, err := fileSystem.Lstat("/home/user/.bashrc")
+	assert.NoError(t, err)
+	assert.True(t, newBashrcInfo.ModTime().Equal(bashrcInfo.ModTime()))
+
+	// NotifyingFS does not implement Lchtimeser, so Lchtimes falls back to
+	// Chtimes for regular files and fails for symlinks.
+	notifyingFS := vfs.NewNotifyingFS(fileSystem)
+	assert.NoError(t, vfs.Lchtimes(notifyingFS, "/home/user/.bashrc", mtime, mtime))
+	newBashrcInfo, err = fileSystem.Lstat("/home/user/.bashrc")
+	assert.NoError(t, err)
+	assert.True(t, newBashrcInfo.ModTime().Equal(mtime))
+	assert.IsError(t, vfs.Lchtimes(notifyingFS, "/home/user/.profile", mtime, mtime), errors.ErrUnsupported)
+	assert.IsError(t, vfs.Lchtimes(notifyingFS, "/home/user/.missing", mtime, mtime), fs.ErrNotExist)
+
+	assert.IsError(t, vfs.Lchtimes(vfs.NewReadOnlyFS(fileSystem), "/home/user/.profile", mtime, mtime), fs.ErrPermission)
+}
+
+func TestLchmod(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("symlinks are not supported on Windows")
+	}
+	fileSystem := vfst.NewTestFSWithT(t, map[string]any{
+		"/home/user/.bashrc":  "# .bashrc\n",
+		"/home/user/.profile": &vfst.Symlink{Target: ".bashrc"},
+	})
+
+	assert.NoError(t, vfs.Lchmod(fileSystem, "/home/user/.bashrc", 0o600))
+	if _, ok := any(vfs.OSFS).(vfs.Lchmoder); ok {
+		assert.NoError(t, vfs.Lchmod(fileSystem, "/home/user/.profile", 0o700))
+		vfst.RunTests(t, fileSystem, "",
+			vfst.TestPath("/home/user/.profile",
+				vfst.TestModeType(fs.ModeSymlink),
+				vfst.TestModePerm(0o700),
+			),
+		)
+	} else {
+		assert.IsError(t, vfs.Lchmod(fileSystem, "/home/user/.profile", 0o700), errors.ErrUnsupported)
+	}
+
+	vfst.RunTests(t, fileSystem, "",
+		vfst.TestPath("/home/user/.bashrc",
+			vfst.TestModePerm(0o600),
+		),
+	)
+}
@@ -0,0 +1,76 @@
+package vfst_test
+
+import (
+	"io/fs"
+	"os"
+	"strconv"
+	"testing"
+
+	"github.com/alecthomas/assert/v2"
+
+	vfs "github.com/twpayne/go-vfs/v5"
+	"github.com/twpayne/go-vfs/v5/vfst"
+)
+
+func TestLock(t *testing.T) {
+	if _, ok := any(vfs.OSFS).(vfs.Locker); !ok {
+		t.Skip("Lock is not supported on this platform")
+	}
+	fileSystem := vfst.NewTestFSWithT(t, map[string]any{
+		"/state": &vfst.Dir{Perm: 0o755},
+	})
+
+	lock, err := fileSystem.Lock("/state", vfs.LockExclusive, false)
+	assert.NoError(t, err)
+	_, err = fileSystem.Lock("/state", vfs.LockExclusive, false)
+	assert.IsError(t, err, vfs.ErrLocked)
+	_, err = fileSystem.Lock("/state", vfs.LockShared, false)
+	assert.IsError(t, err, vfs.ErrLocked)
+	assert.NoError(t, lock.Unlock())
+	assert.NoError(t, lock.Unlock())
+
+	sharedLock1, err := fileSystem.Lock("/state", vfs.LockShared, false)
+	assert.NoError(t, err)
+	sharedLock2, err := fileSystem.Lock("/state", vfs.LockShared, true)
+	assert.NoError(t, err)
+	_, err = fileSystem.Lock("/state", vfs.LockExclusive, false)
+	assert.IsError(t, err, vfs.ErrLocked)
+	assert.NoError(t, sharedLock1.Unlock())
+	assert.NoError(t, sharedLock2.Unlock())
+
+	readOnlyFS := vfs.NewReadOnlyFS(fileSystem)
+	_, err = readOnlyFS.Lock("/state", vfs.LockExclusive, false)
+	assert.IsError(t, err, fs.ErrPermission)
+	readOnlyLock, err := readOnlyFS.Lock("/state", vfs.LockShared, false)
+	assert.NoError(t, err)
+	assert.NoError(t, readOnlyLock.Unlock())
+
+	_, err = fileSystem.Lock("/notexist", vfs.LockShared, false)
+	assert.IsError(t, err, fs.ErrNotExist)
+}
+
+func TestAcquireLockFile(t *testing.T) {
+	fileSystem := vfst.NewTestFSWithT(t, map[string]any{
+		"/state": &vfst.Dir{Perm: 0o755},
+	})
+
+	lockFile, err := vfs.AcquireLockFile(fileSystem, "/state/lock")
+	assert.NoError(t, err)
+	vfst.RunTests(t, fileSystem, "",
+		vfst.TestTree("/state", map[string]any{
+			"lock": strconv.Itoa(os.Getpid()) + "\n",
+		}),
+	)
+	_, err = vfs.AcquireLockFile(fileSystem, "/state/lock")
+	assert.IsError(t, err, vfs.ErrLocked)
+	assert.NoError(t, lockFile.Release())
+
+	// A lock file containing the PID of a process that does not exist is stale.
+	assert.NoError(t, fileSystem.WriteFile("/state/lock", []byte("2147483647\n"), 0o644))
+	lockFile, err = vfs.AcquireLockFile(fileSystem, "/state/lock")
+	assert.NoError(t, err)
+	assert.NoError(t, lockFile.Release())
+	vfst.RunTests(t, fileSystem, "",
+		vfst.TestTree("/state", map[string]any{}),
+	)
+}
@@ -0,0 +1,100 @@
+package vfst_test
+
+import (
+	"bytes"
+	"log/slog"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/alecthomas/assert/v2"
+
+	vfs "github.com/twpayne/go-vfs/v5"
+	"github.com/twpayne/go-vfs/v5/vfst"
+)
+
+func TestLoggingFS(t *testing.T) {
+	fileSystem := vfst.NewTestFSWithT(t, map[string]any{
+		"/home/user/.bashrc": "# .bashrc\n",
+	})
+
+	for _, tc := range []struct {
+		name      string
+		options   []vfs.LoggingFSOption
+		f         func(*vfs.LoggingFS)
+		wantLines []string
+	}{
+		{
+			name: "default",
+			f: func(loggingFS *vfs.LoggingFS) {
+				_, _ = loggingFS.ReadFile("/home/user/.bashrc")
+				_ = loggingFS.WriteFile("/home/user/.profile", []byte("# .profile\n"), 0o644)
+				_ = loggingFS.Rename("/home/user/.profile", "/home/user/.profile.bak")
+				_ = loggingFS.Remove("/home/user/.missing")
+			},
+			wantLines: []string{
+				`level=INFO msg=WriteFile method=WriteFile path=/home/user/.profile bytes=11 perm=-rw-r--r--`,
+				`level=INFO msg=Rename method=Rename oldpath=/home/user/.profile newpath=/home/user/.profile.bak`,
+				`level=INFO msg=Remove method=Remove path=/home/user/.missing err="remove `,
+			},
+		},
+		{
+			name: "levels",
+			options: []vfs.LoggingFSOption{
+				vfs.LoggingFSLevel(vfs.MethodClassRead, slog.LevelInfo),
+				vfs.LoggingFSLevel(vfs.MethodClassWrite, slog.LevelWarn),
+			},
+			f: func(loggingFS *vfs.LoggingFS) {
+				_, _ = loggingFS.ReadFile("/home/user/.bashrc")
+				_ = loggingFS.Chmod("/home/user/.bashrc", 0o600)
+				f, err := loggingFS.OpenFile("/home/user/.bashrc", os.O_WRONLY|os.O_APPEND, 0)
+				if err == nil {
+					f.Close()
+				}
+			},
+			wantLines: []string{
+				`level=INFO msg=ReadFile method=ReadFile path=/home/user/.bashrc bytes=10`,
+				`level=WARN msg=OpenFile method=OpenFile path=/home/user/.bashrc flag=O_WRONLY|O_APPEND perm=----------`,
+			},
+		},
+		{
+			name: "filter",
+			options: []vfs.LoggingFSOption{
+				vfs.LoggingFSLevel(vfs.MethodClassRead, slog.LevelInfo),
+				vfs.LoggingFSFilter(func(path string) bool {
+					return strings.HasSuffix(path, ".bashrc")
+				}),
+			},
+			f: func(loggingFS *vfs.LoggingFS) {
+				_, _ = loggingFS.ReadFile("/home/user/.bashrc")
+				_, _ = loggingFS.ReadDir("/home/user")
+				_ = loggingFS.Link("/home/user/.bashrc", "/home/user/.bashrc.link")
+			},
+			wantLines: []string{
+				`level=INFO msg=ReadFile method=ReadFile path=/home/user/.bashrc bytes=10`,
+				`level=INFO msg=Link method=Link oldpath=/home/user/.bashrc newpath=/home/user/.bashrc.link`,
+			},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			buffer := &bytes.Buffer{}
+			logger := slog.New(slog.NewTextHandler(buffer, &slog.HandlerOptions{
+				Level: slog.LevelInfo,
+				ReplaceAttr: func(groups []string, attr slog.Attr) slog.Attr {
+					switch attr.Key {
+					case slog.TimeKey, "duration":
+						return slog.Attr{}
+					default:
+						return attr
+					}
+				},
+			}))
+			tc.f(vfs.NewLoggingFS(fileSystem, logger, tc.options...))
+			gotLines := strings.Split(strings.TrimSuffix(buffer.String(), "\n"), "\n")
+			assert.Equal(t, len(tc.wantLines), len(gotLines), buffer.String())
+			for i, wantLine := range tc.wantLines {
+				assert.True(t, strings.HasPrefix(gotLines[i], wantLine), gotLines[i])
+			}
+		})
+	}
+}
@@ -0,0 +1,73 @@
+package vfst_test
+
+import (
+	"encoding/json"
+	"io/fs"
+	"testing"
+	"time"
+
+	"github.com/alecthomas/assert/v2"
+
+	vfs "github.com/twpayne/go-vfs/v5"
+	"github.com/twpayne/go-vfs/v5/vfst"
+)
+
+func TestMetricsFS(t *testing.T) {
+	fileSystem := vfst.NewTestFSWithT(t, map[string]any{
+		"/home/user/.bashrc": "# .bashrc\n",
+	})
+	metricsFS := vfs.NewMetricsFS(fileSystem)
+
+	_, err := metricsFS.ReadFile("/home/user/.bashrc")
+	assert.NoError(t, err)
+	_, err = metricsFS.ReadFile("/home/user/.missing")
+	assert.IsError(t, err, fs.ErrNotExist)
+	assert.NoError(t, metricsFS.WriteFile("/home/user/.profile", []byte("# .profile\n"), 0o644))
+	_, err = metricsFS.Lstat("/home/user/.profile")
+	assert.NoError(t, err)
+
+	snapshot := metricsFS.Snapshot()
+	assert.Equal(t, []time.Duration{
+		time.Microsecond,
+		10 * time.Microsecond,
+		100 * time.Microsecond,
+		time.Millisecond,
+		10 * time.Millisecond,
+		100 * time.Millisecond,
+		time.Second,
+	}, snapshot.LatencyBuckets)
+	assert.Equal(t, 3, len(snapshot.Methods))
+	for method, want := range map[string]struct {
+		calls, errors, bytes uint64
+	}{
+		"Lstat":     {calls: 1},
+		"ReadFile":  {calls: 2, errors: 1, bytes: 10},
+		"WriteFile": {calls: 1, bytes: 11},
+	} {
+		got := snapshot.Methods[method]
+		assert.Equal(t, want.calls, got.Calls, method)
+		assert.Equal(t, want.errors, got.Errors, method)
+		assert.Equal(t, want.bytes, got.Bytes, method)
+		assert.Equal(t, len(snapshot.LatencyBuckets)+1, len(got.LatencyCounts), method)
+		var latencyCount uint64
+		for _, count := range got.LatencyCounts {
+			latencyCount += count
+		}
+		assert.Equal(t, got.Calls, latencyCount, method)
+	}
+
+	var expvarSnapshot vfs.MetricsSnapshot
+	assert.NoError(t, json.Unmarshal([]byte(metricsFS.Var().String()), &expvarSnapshot))
+	assert.Equal(t, snapshot.Methods["ReadFile"].Calls, expvarSnapshot.Methods["ReadFile"].Calls)
+
+	// Latency buckets can be set per MetricsFS.
+	latencyBuckets := []time.Duration{time.Millisecond, time.Second}
+	customMetricsFS := vfs.NewMetricsFS(fileSystem, vfs.MetricsFSLatencyBuckets(latencyBuckets...))
+	latencyBuckets[0] = time.Hour
+	_, err = customMetricsFS.Lstat("/home/user/.bashrc")
+	assert.NoError(t, err)
+	customSnapshot := customMetricsFS.Snapshot()
+	assert.Equal(t, []time.Duration{time.Millisecond, time.Second}, customSnapshot.LatencyBuckets)
+	assert.Equal(t, 3, len(customSnapshot.Methods["Lstat"].LatencyCounts))
+	assert.Equal(t, 7, len(vfs.NewMetricsFS(fileSystem).Snapshot().LatencyBuckets))
+}
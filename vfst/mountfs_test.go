@@ -0,0 +1,204 @@
+package vfst_test
+
+import (
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"runtime"
+	"syscall"
+	"testing"
+	"testing/fstest"
+
+	"github.com/alecthomas/assert/v2"
+
+	vfs "github.com/twpayne/go-vfs/v5"
+	"github.com/twpayne/go-vfs/v5/vfst"
+)
+
+func TestMountFS(t *testing.T) {
+	fileSystem := vfst.NewTestFSWithT(t, map[string]any{
+		"/root": map[string]any{
+			"etc": map[string]any{
+				"hidden": "shadowed by mount\n",
+			},
+			"home/user/.bashrc": "# bashrc\n",
+		},
+		"/defaults": map[string]any{
+			"hosts":  "127.0.0.1 localhost\n",
+			"passwd": "root:x:0:0::/root:/bin/sh\n",
+		},
+		"/data": map[string]any{
+			"file": "data\n",
+		},
+	})
+	mountFS := vfs.NewMountFS()
+	assert.NoError(t, mountFS.Mount("/", vfs.NewPathFS(fileSystem, "/root")))
+	assert.NoError(t, mountFS.Mount("/etc", vfs.NewPathFS(fileSystem, "/defaults")))
+	assert.NoError(t, mountFS.Mount("/mnt/data/", vfs.NewPathFS(fileSystem, "/data")))
+	assert.IsError(t, mountFS.Mount("/etc", vfs.NewPathFS(fileSystem, "/data")), fs.ErrExist)
+
+	// Names are routed to the longest prefix.
+	data, err := mountFS.ReadFile("/etc/hosts")
+	assert.NoError(t, err)
+	assert.Equal(t, "127.0.0.1 localhost\n", string(data))
+	data, err = mountFS.ReadFile("/home/user/.bashrc")
+	assert.NoError(t, err)
+	assert.Equal(t, "# bashrc\n", string(data))
+	_, err = mountFS.ReadFile("/etc/hidden")
+	assert.IsError(t, err, fs.ErrNotExist)
+	assert.NoError(t, mountFS.WriteFile("/mnt/data/new", []byte("new\n"), 0o644))
+	vfst.RunTests(t, fileSystem, "",
+		vfst.TestPath("/data/new",
+			vfst.TestContentsString("new\n"),
+		),
+	)
+
+	// Mount points and the directories above them are synthesized.
+	readDirNames := func(dirname string) []string {
+		t.Helper()
+		dirEntries, err := mountFS.ReadDir(dirname)
+		assert.NoError(t, err)
+		names := make([]string, 0, len(dirEntries))
+		for _, dirEntry := range dirEntries {
+			assert.True(t, dirEntry.Name() != "data" || dirEntry.IsDir())
+			names = append(names, dirEntry.Name())
+		}
+		return names
+	}
+	assert.Equal(t, []string{"etc", "home", "mnt"}, readDirNames("/"))
+	assert.Equal(t, []string{"data"}, readDirNames("/mnt"))
+	assert.Equal(t, []string{"file", "new"}, readDirNames("/mnt/data"))
+	info, err := mountFS.Stat("/mnt")
+	assert.NoError(t, err)
+	assert.True(t, info.IsDir())
+	assert.Equal(t, "mnt", info.Name())
+	info, err = mountFS.Lstat("/mnt/data")
+	assert.NoError(t, err)
+	assert.True(t, info.IsDir())
+	assert.Equal(t, "data", info.Name())
+
+	// Glob spans mounts.
+	matches, err := mountFS.Glob("/*/*")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"/etc/hosts", "/etc/passwd", "/home/user", "/mnt/data"}, matches)
+	matches, err = mountFS.Glob("/mnt/*/f*")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"/mnt/data/file"}, matches)
+
+	// Renames and links between mounts fail with EXDEV.
+	assert.IsError(t, mountFS.Rename("/etc/hosts", "/home/user/hosts"), syscall.EXDEV)
+	assert.IsError(t, mountFS.Link("/etc/hosts", "/home/user/hosts"), syscall.EXDEV)
+	assert.NoError(t, mountFS.Rename("/etc/hosts", "/etc/hosts.bak"))
+
+	// Mount points cannot be removed or renamed.
+	assert.IsError(t, mountFS.Remove("/etc"), syscall.EBUSY)
+	assert.IsError(t, mountFS.RemoveAll("/mnt"), syscall.EBUSY)
+	assert.IsError(t, mountFS.Rename("/home", "/mnt"), syscall.EBUSY)
+
+	assert.NoError(t, mountFS.Unmount("/etc"))
+	assert.IsError(t, mountFS.Unmount("/etc"), fs.ErrNotExist)
+	data, err = mountFS.ReadFile("/etc/hidden")
+	assert.NoError(t, err)
+	assert.Equal(t, "shadowed by mount\n", string(data))
+
+	assert.NoError(t, mountFS.Unmount("/"))
+	_, err = mountFS.Stat("/home")
+	assert.IsError(t, err, fs.ErrNotExist)
+	assert.Equal(t, []string{"mnt"}, readDirNames("/"))
+}
+
+func TestMountFSSymlink(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("symlinks are not supported on Windows")
+	}
+	tempDir := filepath.ToSlash(t.TempDir())
+	mountFS := vfs.NewMountFS()
+	assert.NoError(t, mountFS.Mount("/mnt/os", vfs.OSFS))
+	mountTempDir := "/mnt/os" + tempDir
+	assert.NoError(t, mountFS.WriteFile(mountTempDir+"/file", []byte("file\n"), 0o644))
+
+	// Absolute targets in the same mount round trip.
+	assert.NoError(t, mountFS.Symlink(mountTempDir+"/file", mountTempDir+"/link"))
+	target, err := vfs.OSFS.Readlink(tempDir + "/link")
+	assert.NoError(t, err)
+	assert.Equal(t, tempDir+"/file", target)
+	target, err = mountFS.Readlink(mountTempDir + "/link")
+	assert.NoError(t, err)
+	assert.Equal(t, mountTempDir+"/file", target)
+	data, err := mountFS.ReadFile(mountTempDir + "/link")
+	assert.NoError(t, err)
+	assert.Equal(t, "file\n", string(data))
+
+	// Relative targets are not translated.
+	assert.NoError(t, mountFS.Symlink("file", mountTempDir+"/relative"))
+	target, err = mountFS.Readlink(mountTempDir + "/relative")
+	assert.NoError(t, err)
+	assert.Equal(t, "file", target)
+}
+
+func TestMountFSIOFS(t *testing.T) {
+	fileSystem := vfst.NewTestFSWithT(t, map[string]any{
+		"/home/user/.bashrc": "# bashrc\n",
+	})
+	mountFS := vfs.NewMountFS()
+	assert.NoError(t, mountFS.Mount("/", fileSystem))
+	assert.NoError(t, mountFS.Mount("/usr/share", vfs.NewIOFS(fstest.MapFS{
+		"doc/README": &fstest.MapFile{
+			Data: []byte("readme\n"),
+			Mode: 0o644,
+		},
+		"man/man1/ls.1": &fstest.MapFile{
+			Data: []byte(".TH LS 1\n"),
+			Mode: 0o644,
+		},
+	})))
+
+	data, err := mountFS.ReadFile("/usr/share/doc/README")
+	assert.NoError(t, err)
+	assert.Equal(t, "readme\n", string(data))
+
+	f, err := mountFS.Open("/usr/share/man/man1/ls.1")
+	assert.NoError(t, err)
+	data, err = io.ReadAll(f)
+	assert.NoError(t, err)
+	assert.NoError(t, f.Close())
+	assert.Equal(t, ".TH LS 1\n", string(data))
+
+	dirEntries, err := mountFS.ReadDir("/usr/share")
+	assert.NoError(t, err)
+	names := make([]string, 0, len(dirEntries))
+	for _, dirEntry := range dirEntries {
+		names = append(names, dirEntry.Name())
+	}
+	assert.Equal(t, []string{"doc", "man"}, names)
+
+	info, err := mountFS.Stat("/usr/share/man/man1")
+	assert.NoError(t, err)
+	assert.True(t, info.IsDir())
+
+	matches, err := mountFS.Glob("/usr/share/*/README")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"/usr/share/doc/README"}, matches)
+
+	_, err = mountFS.Stat("/usr/share/missing")
+	assert.IsError(t, err, fs.ErrNotExist)
+	var pathErr *fs.PathError
+	assert.True(t, errors.As(err, &pathErr))
+	assert.Equal(t, "/missing", pathErr.Path)
+
+	// The IOFS is read-only.
+	assert.IsError(t, mountFS.WriteFile("/usr/share/doc/README", nil, 0o644), fs.ErrPermission)
+	assert.IsError(t, mountFS.Remove("/usr/share/doc/README"), fs.ErrPermission)
+	assert.IsError(t, mountFS.Mkdir("/usr/share/info", 0o755), fs.ErrPermission)
+	_, err = mountFS.OpenFile("/usr/share/doc/README", os.O_RDWR, 0)
+	assert.IsError(t, err, fs.ErrPermission)
+	_, err = mountFS.OpenFile("/usr/share/doc/README", os.O_RDONLY, 0)
+	assert.IsError(t, err, errors.ErrUnsupported)
+	_, err = mountFS.Readlink("/usr/share/doc/README")
+	assert.IsError(t, err, syscall.EINVAL)
+
+	// The rest of the MountFS is still writable.
+	assert.NoError(t, mountFS.WriteFile("/home/user/.profile", nil, 0o644))
+}
@@ -0,0 +1,144 @@
+package vfst_test
+
+import (
+	"errors"
+	"runtime"
+	"syscall"
+	"testing"
+
+	"github.com/alecthomas/assert/v2"
+
+	vfs "github.com/twpayne/go-vfs/v5"
+	"github.com/twpayne/go-vfs/v5/vfst"
+)
+
+func TestStatfs(t *testing.T) {
+	if _, ok := any(vfs.OSFS).(vfs.Statfser); !ok {
+		t.Skip("Statfs is not supported on this platform")
+	}
+	fileSystem := vfst.NewTestFSWithT(t, map[string]any{
+		"/home/user/.bashrc": "# .bashrc\n",
+	})
+
+	fsStat, err := fileSystem.Statfs("/home/user/.bashrc")
+	assert.NoError(t, err)
+	assert.NotZero(t, fsStat.TotalBytes)
+	assert.NotZero(t, fsStat.BlockSize)
+
+	_, err = fileSystem.Statfs("/missing")
+	assert.Error(t, err)
+
+	readOnlyFSStat, err := vfs.NewReadOnlyFS(fileSystem).Statfs("/home/user/.bashrc")
+	assert.NoError(t, err)
+	assert.True(t, readOnlyFSStat.ReadOnly)
+	assert.Zero(t, readOnlyFSStat.AvailableBytes)
+	assert.Equal(t, fsStat.TotalBytes, readOnlyFSStat.TotalBytes)
+}
+
+func TestQuotaFS(t *testing.T) {
+	fileSystem := vfst.NewTestFSWithT(t, map[string]any{
+		"/home/user/.bashrc": "# .bashrc\n",
+		"/srv":               &vfst.Dir{Perm: 0o755},
+	})
+	quotaFS := vfs.NewQuotaFS(fileSystem, "/home", 16, 3)
+
+	fsStat, err := quotaFS.Statfs("/home")
+	assert.NoError(t, err)
+	assert.Equal(t, &vfs.FSStat{
+		Type:           "quota",
+		BlockSize:      1,
+		TotalBytes:     16,
+		FreeBytes:      6,
+		AvailableBytes: 6,
+		TotalInodes:    3,
+		FreeInodes:     1,
+	}, fsStat)
+
+	assert.IsError(t, quotaFS.WriteFile("/home/user/.profile", []byte("# .profile\n"), 0o644), syscall.ENOSPC)
+	assert.NoError(t, quotaFS.WriteFile("/home/user/.bashrc", []byte("# .bashrc.\n# .\n"), 0o644))
+	assert.IsError(t, quotaFS.Truncate("/home/user/.bashrc", 17), syscall.ENOSPC)
+	assert.NoError(t, quotaFS.Truncate("/home/user/.bashrc", 0))
+	assert.NoError(t, quotaFS.Mkdir("/home/user/.config", 0o755))
+	assert.IsError(t, quotaFS.Mkdir("/home/user/.cache", 0o755), syscall.ENOSPC)
+	if runtime.GOOS != "windows" {
+		assert.IsError(t, quotaFS.Symlink(".bashrc", "/home/user/.profile"), syscall.ENOSPC)
+	}
+
+	vfst.RunTests(t, fileSystem, "",
+		vfst.TestPath("/home/user/.bashrc",
+			vfst.TestSize(0),
+		),
+		vfst.TestPath("/home/user/.cache",
+			vfst.TestDoesNotExist(),
+		),
+		vfst.TestPath("/home/user/.profile",
+			vfst.TestDoesNotExist(),
+		),
+	)
+
+	// Names outside the root are not limited.
+	assert.NoError(t, quotaFS.WriteFile("/srv/large", []byte("larger than the quota\n"), 0o644))
+	assert.NoError(t, quotaFS.WriteFile("/srv/large", nil, 0o644))
+	assert.NoError(t, quotaFS.WriteFile("/srv/large", []byte("larger than the quota\n"), 0o644))
+
+	// Renaming into the root is limited.
+	assert.IsError(t, quotaFS.Rename("/srv/large", "/home/user/large"), syscall.ENOSPC)
+
+	// Removing entries frees space.
+	assert.NoError(t, quotaFS.Remove("/home/user/.config"))
+	assert.NoError(t, quotaFS.WriteFile("/srv/small", []byte("small\n"), 0o644))
+	assert.NoError(t, quotaFS.Rename("/srv/small", "/home/user/small"))
+	fsStat, err = quotaFS.Statfs("/home")
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(10), fsStat.FreeBytes)
+	assert.Equal(t, uint64(0), fsStat.FreeInodes)
+	assert.NoError(t, quotaFS.RemoveAll("/home/user"))
+	fsStat, err = quotaFS.Statfs("/home")
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(16), fsStat.FreeBytes)
+	assert.Equal(t, uint64(3), fsStat.FreeInodes)
+
+	// Statfs outside the root is passed to the underlying FS.
+	if _, ok := any(vfs.OSFS).(vfs.Statfser); ok {
+		fsStat, err = quotaFS.Statfs("/srv")
+		assert.NoError(t, err)
+		assert.NotEqual(t, "quota", fsStat.Type)
+		assert.NotEqual(t, uint64(16), fsStat.TotalBytes)
+	} else {
+		_, err = quotaFS.Statfs("/srv")
+		assert.IsError(t, err, errors.ErrUnsupported)
+	}
+}
+
+func TestQuotaFSLink(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("hard links are not counted on Windows")
+	}
+	fileSystem := vfst.NewTestFSWithT(t, map[string]any{
+		"/home/user/file": "0123456789",
+	})
+	quotaFS := vfs.NewQuotaFS(fileSystem, "/home", 16, 4)
+
+	// Hard links count as entries but their size is only counted once.
+	assert.NoError(t, quotaFS.Link("/home/user/file", "/home/user/link"))
+	fsStat, err := quotaFS.Statfs("/home")
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(6), fsStat.FreeBytes)
+	assert.Equal(t, uint64(1), fsStat.FreeInodes)
+	fsStat, err = vfs.NewQuotaFS(fileSystem, "/home", 16, 4).Statfs("/home")
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(6), fsStat.FreeBytes)
+	assert.Equal(t, uint64(1), fsStat.FreeInodes)
+
+	// Removing one link does not free the file's size.
+	assert.NoError(t, quotaFS.Remove("/home/user/link"))
+	fsStat, err = quotaFS.Statfs("/home")
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(6), fsStat.FreeBytes)
+	assert.Equal(t, uint64(2), fsStat.FreeInodes)
+	assert.NoError(t, quotaFS.Remove("/home/user/file"))
+	fsStat, err = quotaFS.Statfs("/home")
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(16), fsStat.FreeBytes)
+	assert.Equal(t, uint64(3), fsStat.FreeInodes)
+}
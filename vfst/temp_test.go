@@ -0,0 +1,109 @@
+package vfst_test
+
+import (
+	"io"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/alecthomas/assert/v2"
+
+	vfs "github.com/twpayne/go-vfs/v5"
+	"github.com/twpayne/go-vfs/v5/vfst"
+)
+
+func TestCreateTemp(t *testing.T) {
+	fileSystem := vfst.NewTestFSWithT(t, map[string]any{
+		"/home/user": &vfst.Dir{Perm: 0o755},
+	})
+	for _, wrappedFS := range []vfs.FS{
+		vfs.NewCachingFS(fileSystem),
+		vfs.NewLoggingFS(fileSystem, slog.New(slog.NewTextHandler(io.Discard, nil))),
+		vfs.NewMetricsFS(fileSystem),
+		vfs.NewNotifyingFS(fileSystem),
+		vfs.NewTrashFS(fileSystem, "/home/user/.Trash"),
+		vfs.NewVersionedFS(fileSystem, "/home/user/.versions"),
+	} {
+		assert.Equal(t, "/tmp", vfs.TempDir(wrappedFS))
+	}
+
+	f, err := vfs.CreateTemp(fileSystem, "", "prefix-*.txt")
+	assert.NoError(t, err)
+	_, err = f.Write([]byte("contents"))
+	assert.NoError(t, err)
+	assert.NoError(t, f.Close())
+	name := filepath.Base(f.Name())
+	assert.True(t, strings.HasPrefix(name, "prefix-"))
+	assert.True(t, strings.HasSuffix(name, ".txt"))
+	assert.True(t, strings.HasPrefix(f.Name(), fileSystem.TempDir()))
+
+	dir, err := vfs.MkdirTemp(fileSystem, "/home/user", "dir")
+	assert.NoError(t, err)
+	assert.True(t, strings.HasPrefix(dir, filepath.FromSlash("/home/user/dir")))
+
+	_, err = vfs.CreateTemp(fileSystem, "", "a/b")
+	assert.Error(t, err)
+	_, err = vfs.MkdirTemp(fileSystem, "/missing", "dir")
+	assert.IsError(t, err, fs.ErrNotExist)
+
+	vfst.RunTests(t, fileSystem, "",
+		vfst.TestPath(filepath.ToSlash(filepath.Join("/tmp", name)),
+			vfst.TestModeIsRegular(),
+			vfst.TestContentsString("contents"),
+		),
+		vfst.TestPath(filepath.ToSlash(dir),
+			vfst.TestIsDir(),
+			vfst.TestModePerm(0o700),
+		),
+	)
+
+	// The default temporary directory is created if needed.
+	emptyFS := vfst.NewEmptyTestFSWithT(t)
+	dir, err = vfs.MkdirTemp(emptyFS, "", "dir")
+	assert.NoError(t, err)
+	assert.True(t, strings.HasPrefix(dir, filepath.FromSlash("/tmp/dir")))
+}
+
+func TestCreateTempCollision(t *testing.T) {
+	fileSystem := vfst.NewTestFSWithT(t, map[string]any{
+		"/tmp": &vfst.Dir{Perm: 0o700},
+	})
+	collidingFS := &collidingFS{FS: fileSystem, collisions: 3}
+	f, err := vfs.CreateTemp(collidingFS, "/tmp", "file")
+	assert.NoError(t, err)
+	assert.NoError(t, f.Close())
+	assert.Equal(t, 0, collidingFS.collisions)
+
+	collidingFS.collisions = 3
+	_, err = vfs.MkdirTemp(collidingFS, "/tmp", "dir")
+	assert.NoError(t, err)
+	assert.Equal(t, 0, collidingFS.collisions)
+}
+
+// A collidingFS is a vfs.FS whose first collisions calls to OpenFile and Mkdir
+// fail with fs.ErrExist.
+type collidingFS struct {
+	vfs.FS
+	collisions int
+}
+
+// Mkdir implements vfs.FS.Mkdir.
+func (c *collidingFS) Mkdir(name string, perm fs.FileMode) error {
+	if c.collisions > 0 {
+		c.collisions--
+		return &fs.PathError{Op: "Mkdir", Path: name, Err: fs.ErrExist}
+	}
+	return c.FS.Mkdir(name, perm)
+}
+
+// OpenFile implements vfs.FS.OpenFile.
+func (c *collidingFS) OpenFile(name string, flag int, perm fs.FileMode) (*os.File, error) {
+	if c.collisions > 0 {
+		c.collisions--
+		return nil, &fs.PathError{Op: "OpenFile", Path: name, Err: fs.ErrExist}
+	}
+	return c.FS.OpenFile(name, flag, perm)
+}
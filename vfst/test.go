@@ -4,9 +4,9 @@
 package vfst
 
 import (
+	"fmt"
 	"io/fs"
 	"syscall"
-	"testing"
 
 	vfs "github.com/twpayne/go-vfs/v5"
 )
@@ -22,19 +22,31 @@ func PermEqual(perm1, perm2 fs.FileMode) bool {
 	return perm1&fs.ModePerm&^umask == perm2&fs.ModePerm&^umask
 }
 
-// TestSysNlink returns a PathTest that verifies that the path's
+// CheckSysNlink returns a PathCheck that verifies that the path's
 // Sys().(*syscall.Stat_t).Nlink is equal to wantNlink. If path's Sys() cannot
 // be converted to a *syscall.Stat_t, it does nothing.
-func TestSysNlink(wantNlink int) PathTest {
-	return func(t *testing.T, fileSystem vfs.FS, path string) {
-		t.Helper()
-		info, err := fileSystem.Lstat(path)
+func CheckSysNlink(wantNlink int) PathCheck {
+	return func(fileSystem vfs.FS, path string) error {
+		info, err := lstat(fileSystem, path, "SysNlink")
 		if err != nil {
-			t.Errorf("fileSystem.Lstat(%q) == %+v, %v, want !<nil>, <nil>", path, info, err)
-			return
+			return err
 		}
 		if stat, ok := info.Sys().(*syscall.Stat_t); ok && int(stat.Nlink) != wantNlink { //nolint:gosec
-			t.Errorf("fileSystem.Lstat(%q).Sys().(*syscall.Stat_t).Nlink == %d, want %d", path, stat.Nlink, wantNlink)
+			return &CheckError{
+				Path:    path,
+				Check:   "SysNlink",
+				Got:     int(stat.Nlink), //nolint:gosec
+				Want:    wantNlink,
+				message: fmt.Sprintf("has %d links, want %d", stat.Nlink, wantNlink),
+			}
 		}
+		return nil
 	}
 }
+
+// TestSysNlink returns a PathTest that verifies that the path's
+// Sys().(*syscall.Stat_t).Nlink is equal to wantNlink. If path's Sys() cannot
+// be converted to a *syscall.Stat_t, it does nothing.
+func TestSysNlink(wantNlink int) PathTest {
+	return TestCheck(CheckSysNlink(wantNlink))
+}
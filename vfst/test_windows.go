@@ -2,7 +2,6 @@ package vfst
 
 import (
 	"io/fs"
-	"testing"
 
 	"github.com/twpayne/go-vfs/v5"
 )
@@ -13,10 +12,18 @@ func PermEqual(perm1, perm2 fs.FileMode) bool {
 	return true
 }
 
+// CheckSysNlink returns a PathCheck that verifies that the path's
+// Sys().(*syscall.Stat_t).Nlink is equal to wantNlink. On Windows, it does
+// nothing.
+func CheckSysNlink(wantNlink int) PathCheck {
+	return func(vfs.FS, string) error {
+		return nil
+	}
+}
+
 // TestSysNlink returns a PathTest that verifies that the the path's
 // Sys().(*syscall.Stat_t).Nlink is equal to wantNlink. If path's Sys() cannot
 // be converted to a *syscall.Stat_t, it does nothing.
 func TestSysNlink(wantNlink int) PathTest {
-	return func(*testing.T, vfs.FS, string) {
-	}
+	return TestCheck(CheckSysNlink(wantNlink))
 }
@@ -4,6 +4,9 @@ import (
 	"errors"
 	"io/fs"
 	"os"
+	"strings"
+	"testing"
+	"unicode"
 
 	vfs "github.com/twpayne/go-vfs/v5"
 )
@@ -44,6 +47,52 @@ func NewTestFS(root any, builderOptions ...BuilderOption) (*TestFS, func(), erro
 	return fileSystem, cleanup, nil
 }
 
+// NewEmptyTestFSWithT returns a new empty *TestFS whose temporary directory is
+// named after t.Name() and is removed when t and all its subtests complete. If
+// t has failed, the temporary directory is kept and its path is logged.
+func NewEmptyTestFSWithT(t testing.TB) *TestFS {
+	t.Helper()
+	tempDir, err := os.MkdirTemp("", "go-vfs-vfst-"+tempDirPattern(t.Name()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	fileSystem := &TestFS{
+		PathFS:  *vfs.NewPathFS(vfs.OSFS, tempDir),
+		tempDir: tempDir,
+		keep:    false,
+	}
+	t.Cleanup(func() {
+		if t.Failed() {
+			fileSystem.Keep()
+			t.Logf("keeping temporary directory %s", tempDir)
+		}
+		fileSystem.cleanup()
+	})
+	return fileSystem
+}
+
+// NewTestFSWithT returns a new *TestFS populated with root. Its temporary
+// directory is managed as for NewEmptyTestFSWithT. Any error is reported with
+// t.Fatal.
+func NewTestFSWithT(t testing.TB, root any, builderOptions ...BuilderOption) *TestFS {
+	t.Helper()
+	fileSystem := NewEmptyTestFSWithT(t)
+	if err := NewBuilder(builderOptions...).Build(fileSystem, root); err != nil {
+		t.Fatal(err)
+	}
+	return fileSystem
+}
+
+// DefaultTempDir implements vfs.DefaultTempDirer.DefaultTempDir. It returns
+// "/tmp" in t, so that temporary files created with vfs.CreateTemp and
+// vfs.MkdirTemp are removed with t. "/tmp" is not created with t, but
+// vfs.CreateTemp and vfs.MkdirTemp create it if it does not exist. It is
+// distinct from TempDir, which returns the real temporary directory containing
+// t.
+func (t *TestFS) DefaultTempDir() string {
+	return "/tmp"
+}
+
 // Keep prevents t's cleanup function from removing the temporary directory. It
 // has no effect if cleanup has already been called.
 func (t *TestFS) Keep() {
@@ -77,3 +126,20 @@ func (t *TestFS) cleanup() {
 		}
 	}
 }
+
+// tempDirPattern returns a pattern for os.MkdirTemp derived from testName.
+// Characters that are not letters, digits, hyphens, or underscores are replaced
+// with underscores and long names are truncated.
+func tempDirPattern(testName string) string {
+	const maxLen = 64
+	pattern := strings.Map(func(r rune) rune {
+		if r < unicode.MaxASCII && (unicode.IsLetter(r) || unicode.IsDigit(r) || r == '-' || r == '_') {
+			return r
+		}
+		return '_'
+	}, testName)
+	if len(pattern) > maxLen {
+		pattern = pattern[:maxLen]
+	}
+	return pattern + "-"
+}
@@ -0,0 +1,91 @@
+package vfst_test
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/alecthomas/assert/v2"
+
+	"github.com/twpayne/go-vfs/v5/vfst"
+)
+
+// A recordingTB is a testing.TB that records cleanup functions and log
+// messages and can be marked as failed.
+type recordingTB struct {
+	testing.TB
+	cleanups []func()
+	failed   bool
+	logs     []string
+}
+
+func (r *recordingTB) Cleanup(f func()) { r.cleanups = append(r.cleanups, f) }
+func (r *recordingTB) Failed() bool     { return r.failed }
+func (r *recordingTB) Helper()          {}
+
+func (r *recordingTB) Logf(format string, args ...any) {
+	r.logs = append(r.logs, fmt.Sprintf(format, args...))
+}
+
+func (r *recordingTB) runCleanups() {
+	for i := len(r.cleanups) - 1; i >= 0; i-- {
+		r.cleanups[i]()
+	}
+}
+
+func TestNewTestFSWithT(t *testing.T) {
+	var tempDir string
+	t.Run("sub/test", func(t *testing.T) {
+		fileSystem := vfst.NewTestFSWithT(t, map[string]any{
+			"/home/user/.bashrc": "# contents of user's .bashrc\n",
+		})
+		tempDir = fileSystem.TempDir()
+		assert.True(t, strings.HasPrefix(filepath.Base(tempDir), "go-vfs-vfst-TestNewTestFSWithT_sub_test-"))
+		vfst.RunTests(t, fileSystem, "",
+			vfst.TestPath("/home/user/.bashrc",
+				vfst.TestContentsString("# contents of user's .bashrc\n"),
+			),
+		)
+	})
+	_, err := os.Stat(tempDir)
+	assert.IsError(t, err, fs.ErrNotExist)
+}
+
+func TestNewTestFSWithTKeepsOnFailure(t *testing.T) {
+	for _, tc := range []struct {
+		name         string
+		failed       bool
+		expectedKeep bool
+	}{
+		{
+			name:         "passed",
+			failed:       false,
+			expectedKeep: false,
+		},
+		{
+			name:         "failed",
+			failed:       true,
+			expectedKeep: true,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			tb := &recordingTB{TB: t}
+			fileSystem := vfst.NewEmptyTestFSWithT(tb)
+			tempDir := fileSystem.TempDir()
+			defer os.RemoveAll(tempDir)
+			tb.failed = tc.failed
+			tb.runCleanups()
+			_, err := os.Stat(tempDir)
+			if tc.expectedKeep {
+				assert.NoError(t, err)
+				assert.Equal(t, 1, len(tb.logs))
+			} else {
+				assert.IsError(t, err, fs.ErrNotExist)
+				assert.Equal(t, 0, len(tb.logs))
+			}
+		})
+	}
+}
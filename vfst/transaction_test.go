@@ -0,0 +1,208 @@
+package vfst_test
+
+import (
+	"errors"
+	"io/fs"
+	"runtime"
+	"testing"
+
+	"github.com/alecthomas/assert/v2"
+
+	vfs "github.com/twpayne/go-vfs/v5"
+	"github.com/twpayne/go-vfs/v5/vfst"
+)
+
+// errCrashed is returned by a crashingFS after it has crashed.
+var errCrashed = errors.New("crashed")
+
+// A crashingFS is a vfs.FS whose modifications fail after a number of
+// modifications have succeeded, simulating a process that is interrupted.
+type crashingFS struct {
+	*vfst.TestFS
+	remaining int
+}
+
+func (c *crashingFS) Chmod(name string, mode fs.FileMode) error {
+	if err := c.modify(); err != nil {
+		return err
+	}
+	return c.TestFS.Chmod(name, mode)
+}
+
+func (c *crashingFS) Mkdir(name string, perm fs.FileMode) error {
+	if err := c.modify(); err != nil {
+		return err
+	}
+	return c.TestFS.Mkdir(name, perm)
+}
+
+func (c *crashingFS) Remove(name string) error {
+	if err := c.modify(); err != nil {
+		return err
+	}
+	return c.TestFS.Remove(name)
+}
+
+func (c *crashingFS) RemoveAll(name string) error {
+	if err := c.modify(); err != nil {
+		return err
+	}
+	return c.TestFS.RemoveAll(name)
+}
+
+func (c *crashingFS) Rename(oldpath, newpath string) error {
+	if err := c.modify(); err != nil {
+		return err
+	}
+	return c.TestFS.Rename(oldpath, newpath)
+}
+
+func (c *crashingFS) Symlink(oldname, newname string) error {
+	if err := c.modify(); err != nil {
+		return err
+	}
+	return c.TestFS.Symlink(oldname, newname)
+}
+
+func (c *crashingFS) WriteFile(filename string, data []byte, perm fs.FileMode) error {
+	if err := c.modify(); err != nil {
+		return err
+	}
+	return c.TestFS.WriteFile(filename, data, perm)
+}
+
+func (c *crashingFS) modify() error {
+	if c.remaining == 0 {
+		return errCrashed
+	}
+	c.remaining--
+	return nil
+}
+
+func TestTransaction(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("symlinks are not supported on Windows")
+	}
+
+	root := map[string]any{
+		"/home/user": map[string]any{
+			"config":   "old config\n",
+			"obsolete": "obsolete\n",
+			"script":   &vfst.File{Perm: 0o644, Contents: []byte("#!/bin/sh\n")},
+			"target":   "old target\n",
+		},
+		"/var/lib/installer": &vfst.Dir{Perm: 0o755},
+	}
+	stage := func(fileSystem vfs.FS) *vfs.Transaction {
+		transaction := vfs.NewTransaction(fileSystem, "/var/lib/installer/journal")
+		transaction.Mkdir("/home/user/app", 0o755)
+		transaction.WriteFile("/home/user/app/new", []byte("new\n"), 0o644)
+		transaction.WriteFile("/home/user/config", []byte("new config\n"), 0o644)
+		transaction.Symlink("app/new", "/home/user/link")
+		transaction.Rename("/home/user/config", "/home/user/target")
+		transaction.Remove("/home/user/obsolete")
+		transaction.Chmod("/home/user/script", 0o755)
+		return transaction
+	}
+	unchanged := []any{
+		vfst.TestPath("/home/user/app",
+			vfst.TestDoesNotExist(),
+		),
+		vfst.TestPath("/home/user/link",
+			vfst.TestDoesNotExist(),
+		),
+		vfst.TestPath("/home/user/config",
+			vfst.TestContentsString("old config\n"),
+		),
+		vfst.TestPath("/home/user/obsolete",
+			vfst.TestContentsString("obsolete\n"),
+		),
+		vfst.TestPath("/home/user/script",
+			vfst.TestModePerm(0o644),
+		),
+		vfst.TestPath("/home/user/target",
+			vfst.TestContentsString("old target\n"),
+		),
+		vfst.TestPath("/var/lib/installer/journal",
+			vfst.TestDoesNotExist(),
+		),
+		vfst.TestPath("/var/lib/installer/journal.backup",
+			vfst.TestDoesNotExist(),
+		),
+	}
+
+	t.Run("commit", func(t *testing.T) {
+		fileSystem := vfst.NewTestFSWithT(t, root)
+		assert.NoError(t, stage(fileSystem).Commit())
+		vfst.RunTests(t, fileSystem, "",
+			vfst.TestPath("/home/user/app/new",
+				vfst.TestContentsString("new\n"),
+			),
+			vfst.TestPath("/home/user/link",
+				vfst.TestModeType(fs.ModeSymlink),
+				vfst.TestSymlinkTarget("app/new"),
+			),
+			vfst.TestPath("/home/user/config",
+				vfst.TestDoesNotExist(),
+			),
+			vfst.TestPath("/home/user/obsolete",
+				vfst.TestDoesNotExist(),
+			),
+			vfst.TestPath("/home/user/script",
+				vfst.TestModePerm(0o755),
+			),
+			vfst.TestPath("/home/user/target",
+				vfst.TestContentsString("new config\n"),
+			),
+			vfst.TestPath("/var/lib/installer/journal",
+				vfst.TestDoesNotExist(),
+			),
+			vfst.TestPath("/var/lib/installer/journal.backup",
+				vfst.TestDoesNotExist(),
+			),
+		)
+	})
+
+	t.Run("failure", func(t *testing.T) {
+		fileSystem := vfst.NewTestFSWithT(t, root)
+		transaction := stage(fileSystem)
+		transaction.Mkdir("/home/user/app", 0o755)
+		assert.IsError(t, transaction.Commit(), fs.ErrExist)
+		vfst.RunTests(t, fileSystem, "", unchanged...)
+		assert.Error(t, transaction.Commit())
+	})
+
+	t.Run("rollback", func(t *testing.T) {
+		fileSystem := vfst.NewTestFSWithT(t, root)
+		transaction := stage(fileSystem)
+		assert.NoError(t, transaction.Rollback())
+		assert.Error(t, transaction.Commit())
+		vfst.RunTests(t, fileSystem, "", unchanged...)
+	})
+
+	t.Run("recover", func(t *testing.T) {
+		for remaining := 0; remaining < 40; remaining++ {
+			fileSystem := vfst.NewTestFSWithT(t, root)
+			err := stage(&crashingFS{TestFS: fileSystem, remaining: remaining}).Commit()
+			_, recoverErr := vfs.RecoverTransaction(fileSystem, "/var/lib/installer/journal")
+			assert.NoError(t, recoverErr)
+			if err == nil {
+				// The transaction completed before the crash.
+				vfst.RunTests(t, fileSystem, "",
+					vfst.TestPath("/home/user/target",
+						vfst.TestContentsString("new config\n"),
+					),
+					vfst.TestPath("/var/lib/installer/journal.backup",
+						vfst.TestDoesNotExist(),
+					),
+				)
+				break
+			}
+			assert.IsError(t, err, errCrashed)
+			vfst.RunTests(t, fileSystem, "", unchanged...)
+			recovered, err := vfs.RecoverTransaction(fileSystem, "/var/lib/installer/journal")
+			assert.NoError(t, err)
+			assert.False(t, recovered)
+		}
+	})
+}
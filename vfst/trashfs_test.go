@@ -0,0 +1,108 @@
+package vfst_test
+
+import (
+	"io/fs"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/alecthomas/assert/v2"
+
+	vfs "github.com/twpayne/go-vfs/v5"
+	"github.com/twpayne/go-vfs/v5/vfst"
+)
+
+func TestTrashFS(t *testing.T) {
+	fileSystem := vfst.NewTestFSWithT(t, map[string]any{
+		"/home/user": map[string]any{
+			"file":  "file\n",
+			"empty": &vfst.Dir{Perm: 0o755},
+			"dir": map[string]any{
+				"file": "dir/file\n",
+			},
+			"a b": "a b\n",
+		},
+	})
+	trashFS := vfs.NewTrashFS(fileSystem, "/home/user/.local/share/Trash")
+	start := time.Now().Truncate(time.Second)
+
+	assert.NoError(t, trashFS.Remove("/home/user/file"))
+	assert.NoError(t, trashFS.Remove("/home/user/empty"))
+	assert.IsError(t, trashFS.Remove("/home/user/dir"), syscall.ENOTEMPTY)
+	assert.IsError(t, trashFS.Remove("/home/user/missing"), fs.ErrNotExist)
+	assert.NoError(t, trashFS.RemoveAll("/home/user/dir"))
+	assert.NoError(t, trashFS.RemoveAll("/home/user/missing"))
+	assert.NoError(t, trashFS.Remove("/home/user/a b"))
+	assert.NoError(t, trashFS.WriteFile("/home/user/file", []byte("file 2\n"), 0o644))
+	assert.NoError(t, trashFS.Remove("/home/user/file"))
+
+	vfst.RunTests(t, fileSystem, "",
+		vfst.TestPath("/home/user/file",
+			vfst.TestDoesNotExist(),
+		),
+		vfst.TestPath("/home/user/.local/share/Trash/files/file",
+			vfst.TestContentsString("file\n"),
+		),
+		vfst.TestPath("/home/user/.local/share/Trash/files/file.2",
+			vfst.TestContentsString("file 2\n"),
+		),
+		vfst.TestPath("/home/user/.local/share/Trash/files/dir/file",
+			vfst.TestContentsString("dir/file\n"),
+		),
+		vfst.TestPath("/home/user/.local/share/Trash/files/empty",
+			vfst.TestIsDir(),
+		),
+	)
+	trashInfo, err := fileSystem.ReadFile("/home/user/.local/share/Trash/info/a b.trashinfo")
+	assert.NoError(t, err)
+	assert.True(t, strings.HasPrefix(string(trashInfo), "[Trash Info]\nPath=/home/user/a%20b\nDeletionDate="))
+
+	trashEntries, err := trashFS.ListTrash()
+	assert.NoError(t, err)
+	var names, paths []string
+	for _, trashEntry := range trashEntries {
+		names = append(names, trashEntry.Name)
+		paths = append(paths, trashEntry.Path)
+		assert.False(t, trashEntry.DeletionDate.Before(start))
+	}
+	assert.Equal(t, []string{"a b", "dir", "empty", "file", "file.2"}, names)
+	assert.Equal(t, []string{"/home/user/a b", "/home/user/dir", "/home/user/empty", "/home/user/file", "/home/user/file"}, paths)
+
+	assert.NoError(t, trashFS.RestoreTrash("dir"))
+	assert.NoError(t, trashFS.RestoreTrash("file.2"))
+	assert.IsError(t, trashFS.RestoreTrash("file"), fs.ErrExist)
+	assert.IsError(t, trashFS.RestoreTrash("missing"), fs.ErrNotExist)
+	assert.IsError(t, trashFS.RestoreTrash("../info"), fs.ErrNotExist)
+	vfst.RunTests(t, fileSystem, "",
+		vfst.TestPath("/home/user/dir/file",
+			vfst.TestContentsString("dir/file\n"),
+		),
+		vfst.TestPath("/home/user/file",
+			vfst.TestContentsString("file 2\n"),
+		),
+		vfst.TestPath("/home/user/.local/share/Trash/info/dir.trashinfo",
+			vfst.TestDoesNotExist(),
+		),
+	)
+
+	// Relative names and ancestors of the trash directory cannot be trashed.
+	assert.IsError(t, trashFS.RemoveAll("user/file"), fs.ErrInvalid)
+	assert.IsError(t, trashFS.RemoveAll("/home/user"), fs.ErrInvalid)
+	assert.IsError(t, trashFS.Remove("/home/user/.local"), fs.ErrInvalid)
+	vfst.RunTests(t, fileSystem, "",
+		vfst.TestPath("/home/user/file",
+			vfst.TestContentsString("file 2\n"),
+		),
+	)
+
+	assert.NoError(t, trashFS.EmptyTrash())
+	trashEntries, err = trashFS.ListTrash()
+	assert.NoError(t, err)
+	assert.Equal(t, 0, len(trashEntries))
+	vfst.RunTests(t, fileSystem, "",
+		vfst.TestPath("/home/user/.local/share/Trash/files/file",
+			vfst.TestDoesNotExist(),
+		),
+	)
+}
@@ -0,0 +1,296 @@
+package vfst
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path/filepath"
+	"sort"
+	"testing"
+
+	vfs "github.com/twpayne/go-vfs/v5"
+)
+
+// A treeEntry is the expected state of a single path in a tree.
+type treeEntry struct {
+	modeType  fs.FileMode
+	perm      fs.FileMode
+	checkPerm bool
+	contents  []byte
+	target    string
+	absent    bool
+	optional  bool
+	xattrs    map[string][]byte
+}
+
+// TestTree returns a Test that verifies that the tree rooted at path matches
+// root exactly. root is interpreted relative to path in the same way as
+// Builder.Build interprets it relative to the root directory. Missing, extra,
+// and differing entries are all reported in a single error. Permissions of
+// directories created implicitly, for example with map[string]any, are not
+// checked. Paths specified with *Absent must not exist, but their parent
+// directories are only checked if they exist. Only the extended attributes
+// specified in Dir.Xattrs and File.Xattrs are checked.
+func TestTree(path string, root any) Test {
+	return func(t *testing.T, fileSystem vfs.FS) {
+		t.Helper()
+		if err := CheckTree(fileSystem, path, root); err != nil {
+			t.Error(err)
+		}
+	}
+}
+
+// CheckTree verifies that the tree rooted at path in fileSystem matches root
+// exactly, as described for TestTree. It returns a *CheckError for every
+// discrepancy, sorted by path and joined with errors.Join.
+func CheckTree(fileSystem vfs.FS, path string, root any) error {
+	path = filepath.Clean(path)
+	wantEntries := map[string]*treeEntry{
+		path: {modeType: fs.ModeDir},
+	}
+	if err := flattenTree(wantEntries, path, root); err != nil {
+		return err
+	}
+	return errors.Join(treeDiscrepancies(fileSystem, path, wantEntries)...)
+}
+
+// treeDiscrepancies returns a *CheckError for every difference between the tree
+// at path in fileSystem and wantEntries, sorted by path.
+func treeDiscrepancies(fileSystem vfs.FS, path string, wantEntries map[string]*treeEntry) []error {
+	// Add any implicit parent directories. Parent directories that are only
+	// implied by absent entries are optional, so they are added last.
+	var absentPaths []string
+	for entryPath := range wantEntries {
+		switch {
+		case entryPath == path:
+			continue
+		case wantEntries[entryPath].absent:
+			absentPaths = append(absentPaths, entryPath)
+			continue
+		}
+		addParentTreeEntries(wantEntries, entryPath, false)
+	}
+	for _, absentPath := range absentPaths {
+		addParentTreeEntries(wantEntries, absentPath, true)
+	}
+
+	var discrepancies []*CheckError
+	seen := make(map[string]struct{})
+	_ = vfs.Walk(fileSystem, path, func(gotPath string, info fs.FileInfo, err error) error {
+		switch {
+		case errors.Is(err, fs.ErrNotExist) && gotPath == path:
+			return nil
+		case err != nil:
+			discrepancies = append(discrepancies, &CheckError{
+				Path:  gotPath,
+				Check: "Tree",
+				Err:   err,
+			})
+			if info != nil && info.IsDir() {
+				return vfs.SkipDir
+			}
+			return nil
+		}
+		seen[gotPath] = struct{}{}
+		wantEntry, ok := wantEntries[gotPath]
+		if !ok {
+			discrepancies = append(discrepancies, newTreeCheckError(gotPath, info.Mode().Type(), nil, "unexpected %s", modeTypeName(info.Mode().Type())))
+			if info.IsDir() {
+				return vfs.SkipDir
+			}
+			return nil
+		}
+		if wantEntry.absent {
+			discrepancies = append(discrepancies, newTreeCheckError(gotPath, info.Mode().Type(), nil, "exists as a %s, want absent", modeTypeName(info.Mode().Type())))
+			if info.IsDir() {
+				return vfs.SkipDir
+			}
+			return nil
+		}
+		discrepancies = append(discrepancies, entryDiscrepancies(fileSystem, gotPath, info, wantEntry)...)
+		if info.IsDir() && wantEntry.modeType != fs.ModeDir {
+			return vfs.SkipDir
+		}
+		return nil
+	})
+	for wantPath, wantEntry := range wantEntries {
+		if _, ok := seen[wantPath]; !ok && !wantEntry.absent && !wantEntry.optional {
+			discrepancies = append(discrepancies, newTreeCheckError(wantPath, nil, wantEntry.modeType, "missing %s", modeTypeName(wantEntry.modeType)))
+		}
+	}
+	sort.Slice(discrepancies, func(i, j int) bool {
+		return discrepancies[i].Error() < discrepancies[j].Error()
+	})
+	errs := make([]error, 0, len(discrepancies))
+	for _, discrepancy := range discrepancies {
+		errs = append(errs, discrepancy)
+	}
+	return errs
+}
+
+// addParentTreeEntries adds directory entries for the parents of entryPath to
+// wantEntries, up to the first parent that is already present. If optional is
+// true then the added entries are only checked if they exist.
+func addParentTreeEntries(wantEntries map[string]*treeEntry, entryPath string, optional bool) {
+	for child, dir := entryPath, filepath.Dir(entryPath); dir != child; child, dir = dir, filepath.Dir(dir) {
+		if _, ok := wantEntries[dir]; ok {
+			return
+		}
+		wantEntries[dir] = &treeEntry{
+			modeType: fs.ModeDir,
+			optional: optional,
+		}
+	}
+}
+
+// entryDiscrepancies returns a *CheckError for every difference between the
+// entry at path, with info, and wantEntry.
+func entryDiscrepancies(fileSystem vfs.FS, path string, info fs.FileInfo, wantEntry *treeEntry) []*CheckError {
+	if gotModeType := info.Mode().Type(); gotModeType != wantEntry.modeType {
+		return []*CheckError{
+			newTreeCheckError(path, gotModeType, wantEntry.modeType, "is a %s, want a %s", modeTypeName(gotModeType), modeTypeName(wantEntry.modeType)),
+		}
+	}
+	var discrepancies []*CheckError
+	if gotPerm := info.Mode().Perm(); wantEntry.checkPerm && !PermEqual(gotPerm, wantEntry.perm) {
+		discrepancies = append(discrepancies, newTreeCheckError(path, gotPerm, wantEntry.perm, "has permissions 0%o, want 0%o", gotPerm, wantEntry.perm))
+	}
+	switch wantEntry.modeType {
+	case 0:
+		gotContents, err := fileSystem.ReadFile(path)
+		switch {
+		case err != nil:
+			discrepancies = append(discrepancies, &CheckError{Path: path, Check: "Tree", Err: err})
+		case !bytes.Equal(gotContents, wantEntry.contents):
+			discrepancies = append(discrepancies, newTreeCheckError(path, gotContents, wantEntry.contents, "has contents %q, want %q", gotContents, wantEntry.contents))
+		}
+	case fs.ModeSymlink:
+		gotTarget, err := fileSystem.Readlink(path)
+		switch {
+		case err != nil:
+			discrepancies = append(discrepancies, &CheckError{Path: path, Check: "Tree", Err: err})
+		case gotTarget != wantEntry.target:
+			discrepancies = append(discrepancies, newTreeCheckError(path, gotTarget, wantEntry.target, "has target %s, want %s", gotTarget, wantEntry.target))
+		}
+	}
+	attrs := make([]string, 0, len(wantEntry.xattrs))
+	for attr := range wantEntry.xattrs {
+		attrs = append(attrs, attr)
+	}
+	sort.Strings(attrs)
+	for _, attr := range attrs {
+		if err := CheckXattr(attr, wantEntry.xattrs[attr])(fileSystem, path); err != nil {
+			var checkError *CheckError
+			if errors.As(err, &checkError) {
+				checkError.Check = "Tree"
+				discrepancies = append(discrepancies, checkError)
+			}
+		}
+	}
+	return discrepancies
+}
+
+// flattenTree is a recursive helper for CheckTree that adds the entries
+// described by i at path to entries.
+func flattenTree(entries map[string]*treeEntry, path string, i any) error {
+	switch i := i.(type) {
+	case []any:
+		for _, element := range i {
+			if err := flattenTree(entries, path, element); err != nil {
+				return err
+			}
+		}
+		return nil
+	case *Dir:
+		entries[path] = &treeEntry{
+			modeType:  fs.ModeDir,
+			perm:      i.Perm,
+			checkPerm: true,
+			xattrs:    i.Xattrs,
+		}
+		for entryName, entry := range i.Entries {
+			if err := flattenTree(entries, filepath.Join(path, entryName), entry); err != nil {
+				return err
+			}
+		}
+		return nil
+	case map[string]any:
+		if _, ok := entries[path]; !ok {
+			entries[path] = &treeEntry{modeType: fs.ModeDir}
+		}
+		for entryName, entry := range i {
+			if err := flattenTree(entries, filepath.Join(path, entryName), entry); err != nil {
+				return err
+			}
+		}
+		return nil
+	case map[string]string:
+		if _, ok := entries[path]; !ok {
+			entries[path] = &treeEntry{modeType: fs.ModeDir}
+		}
+		for entryName, contents := range i {
+			entries[filepath.Join(path, entryName)] = newFileTreeEntry([]byte(contents), 0o666)
+		}
+		return nil
+	case *File:
+		entry := newFileTreeEntry(i.Contents, i.Perm)
+		entry.xattrs = i.Xattrs
+		entries[path] = entry
+		return nil
+	case string:
+		entries[path] = newFileTreeEntry([]byte(i), 0o666)
+		return nil
+	case []byte:
+		entries[path] = newFileTreeEntry(i, 0o666)
+		return nil
+	case *Symlink:
+		entries[path] = &treeEntry{
+			modeType: fs.ModeSymlink,
+			target:   i.Target,
+		}
+		return nil
+	case *Absent:
+		entries[path] = &treeEntry{absent: true}
+		return nil
+	case nil:
+		return nil
+	default:
+		return fmt.Errorf("%s: unsupported type %T", path, i)
+	}
+}
+
+// newTreeCheckError returns a new *CheckError for a tree discrepancy at path.
+func newTreeCheckError(path string, got, want any, format string, args ...any) *CheckError {
+	return &CheckError{
+		Path:    path,
+		Check:   "Tree",
+		Got:     got,
+		Want:    want,
+		message: fmt.Sprintf(format, args...),
+	}
+}
+
+// newFileTreeEntry returns a new *treeEntry for a regular file with contents
+// and perm.
+func newFileTreeEntry(contents []byte, perm fs.FileMode) *treeEntry {
+	return &treeEntry{
+		perm:      perm,
+		checkPerm: true,
+		contents:  contents,
+	}
+}
+
+// modeTypeName returns a human-readable name for modeType.
+func modeTypeName(modeType fs.FileMode) string {
+	switch modeType {
+	case 0:
+		return "file"
+	case fs.ModeDir:
+		return "directory"
+	case fs.ModeSymlink:
+		return "symlink"
+	default:
+		return modeType.String()
+	}
+}
@@ -0,0 +1,155 @@
+package vfst_test
+
+import (
+	"errors"
+	"runtime"
+	"testing"
+
+	"github.com/alecthomas/assert/v2"
+
+	"github.com/twpayne/go-vfs/v5/vfst"
+)
+
+func TestCheckTree(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("test uses UNIX file permissions and paths")
+	}
+	fileSystem, cleanup, err := vfst.NewTestFS(map[string]any{
+		"/home/user": map[string]any{
+			".bashrc": "# contents of .bashrc\n",
+			"bin": &vfst.Dir{
+				Perm: 0o700,
+				Entries: map[string]any{
+					"hello.sh": &vfst.File{
+						Perm:     0o755,
+						Contents: []byte("echo hello\n"),
+					},
+				},
+			},
+			"extra":   map[string]any{"file": "extra"},
+			"symlink": &vfst.Symlink{Target: ".bashrc"},
+		},
+	})
+	assert.NoError(t, err)
+	defer cleanup()
+
+	for _, tc := range []struct {
+		name                  string
+		path                  string
+		root                  any
+		expectedDiscrepancies []string
+	}{
+		{
+			name: "match",
+			path: "/home/user",
+			root: map[string]any{
+				".bashrc": "# contents of .bashrc\n",
+				"bin": &vfst.Dir{
+					Perm: 0o700,
+					Entries: map[string]any{
+						"hello.sh": &vfst.File{
+							Perm:     0o755,
+							Contents: []byte("echo hello\n"),
+						},
+					},
+				},
+				"extra/file": "extra",
+				"symlink":    &vfst.Symlink{Target: ".bashrc"},
+			},
+		},
+		{
+			name: "mismatch",
+			path: "/home/user",
+			root: map[string]any{
+				".bashrc": "# different contents of .bashrc\n",
+				"bin": &vfst.Dir{
+					Perm: 0o755,
+					Entries: map[string]any{
+						"hello.sh": "echo hello\n",
+					},
+				},
+				"missing": "",
+				"symlink": &vfst.Symlink{Target: "bin"},
+			},
+			expectedDiscrepancies: []string{
+				"/home/user/.bashrc: has contents \"# contents of .bashrc\\n\", want \"# different contents of .bashrc\\n\"",
+				"/home/user/bin/hello.sh: has permissions 0755, want 0666",
+				"/home/user/bin: has permissions 0700, want 0755",
+				"/home/user/extra: unexpected directory",
+				"/home/user/missing: missing file",
+				"/home/user/symlink: has target .bashrc, want bin",
+			},
+		},
+		{
+			name: "type_mismatch",
+			path: "/home",
+			root: map[string]any{
+				"user/.bashrc": map[string]any{},
+				"user/bin":     "",
+				"user/extra":   map[string]any{"file": "extra"},
+				"user/symlink": &vfst.Symlink{Target: ".bashrc"},
+			},
+			expectedDiscrepancies: []string{
+				"/home/user/.bashrc: is a file, want a directory",
+				"/home/user/bin: is a directory, want a file",
+			},
+		},
+		{
+			name: "absent",
+			path: "/home/user",
+			root: map[string]any{
+				".bashrc":      &vfst.Absent{},
+				"bin/hello.sh": "echo hello\n",
+				"extra":        &vfst.Absent{},
+				"notexist":     &vfst.Absent{},
+				"symlink":      &vfst.Symlink{Target: ".bashrc"},
+			},
+			expectedDiscrepancies: []string{
+				"/home/user/.bashrc: exists as a file, want absent",
+				"/home/user/bin/hello.sh: has permissions 0755, want 0666",
+				"/home/user/extra: exists as a directory, want absent",
+			},
+		},
+		{
+			name: "absent_parents",
+			path: "/home/user",
+			root: map[string]any{
+				".bashrc":       "# contents of .bashrc\n",
+				"bin/hello.sh":  &vfst.Absent{},
+				"extra/gone":    &vfst.Absent{},
+				"notexist/gone": &vfst.Absent{},
+				"symlink":       &vfst.Symlink{Target: ".bashrc"},
+			},
+			expectedDiscrepancies: []string{
+				"/home/user/bin/hello.sh: exists as a file, want absent",
+				"/home/user/extra/file: unexpected file",
+			},
+		},
+		{
+			name: "missing_root",
+			path: "/notexist",
+			root: map[string]any{
+				"file": "",
+			},
+			expectedDiscrepancies: []string{
+				"/notexist/file: missing file",
+				"/notexist: missing directory",
+			},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			err := vfst.CheckTree(fileSystem, tc.path, tc.root)
+			if tc.expectedDiscrepancies == nil {
+				assert.NoError(t, err)
+				return
+			}
+			var actualDiscrepancies []string
+			for _, err := range err.(interface{ Unwrap() []error }).Unwrap() { //nolint:errorlint,forcetypeassert
+				var checkError *vfst.CheckError
+				assert.True(t, errors.As(err, &checkError))
+				actualDiscrepancies = append(actualDiscrepancies, checkError.Error())
+			}
+			assert.Equal(t, tc.expectedDiscrepancies, actualDiscrepancies)
+		})
+	}
+}
@@ -0,0 +1,146 @@
+package vfst_test
+
+import (
+	"io/fs"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alecthomas/assert/v2"
+
+	vfs "github.com/twpayne/go-vfs/v5"
+	"github.com/twpayne/go-vfs/v5/vfst"
+)
+
+func TestVersionedFS(t *testing.T) {
+	fileSystem := vfst.NewTestFSWithT(t, map[string]any{
+		"/home/user": map[string]any{
+			"file": "v1\n",
+			"dir": map[string]any{
+				"a": "a\n",
+			},
+		},
+	})
+	versionedFS := vfs.NewVersionedFS(fileSystem, "/home/user/.versions")
+
+	assert.NoError(t, versionedFS.WriteFile("/home/user/file", []byte("v2\n"), 0o644))
+	assert.NoError(t, versionedFS.Truncate("/home/user/file", 1))
+	assert.NoError(t, versionedFS.WriteFile("/home/user/new", []byte("new\n"), 0o644))
+	assert.NoError(t, versionedFS.Rename("/home/user/new", "/home/user/file"))
+
+	versions, err := versionedFS.Versions("/home/user/file")
+	assert.NoError(t, err)
+	var contents []string
+	for _, version := range versions {
+		assert.Equal(t, "/home/user/file", version.Path)
+		data, err := versionedFS.ReadVersion("/home/user/file", version.ID)
+		assert.NoError(t, err)
+		assert.Equal(t, int64(len(data)), version.Size)
+		contents = append(contents, string(data))
+	}
+	assert.Equal(t, []string{"v1\n", "v2\n", "v"}, contents)
+
+	// Files that were never overwritten have no versions.
+	versions, err = versionedFS.Versions("/home/user/new")
+	assert.NoError(t, err)
+	assert.Equal(t, 0, len(versions))
+
+	// The versions directory is hidden.
+	dirEntries, err := versionedFS.ReadDir("/home/user")
+	assert.NoError(t, err)
+	var names []string
+	for _, dirEntry := range dirEntries {
+		names = append(names, dirEntry.Name())
+	}
+	assert.Equal(t, []string{"dir", "file"}, names)
+	matches, err := versionedFS.Glob("/home/user/.*")
+	assert.NoError(t, err)
+	assert.Equal(t, 0, len(matches))
+
+	// Restoring a version stores the current contents as a new version.
+	versions, err = versionedFS.Versions("/home/user/file")
+	assert.NoError(t, err)
+	assert.NoError(t, versionedFS.Restore("/home/user/file", versions[0].ID))
+	data, err := versionedFS.ReadFile("/home/user/file")
+	assert.NoError(t, err)
+	assert.Equal(t, "v1\n", string(data))
+	versions, err = versionedFS.Versions("/home/user/file")
+	assert.NoError(t, err)
+	assert.Equal(t, 4, len(versions))
+	data, err = versionedFS.ReadVersion("/home/user/file", versions[3].ID)
+	assert.NoError(t, err)
+	assert.Equal(t, "new\n", string(data))
+
+	assert.IsError(t, versionedFS.Restore("/home/user/file", "../../file"), fs.ErrNotExist)
+
+	// Removed files can be restored.
+	assert.NoError(t, versionedFS.RemoveAll("/home/user/dir"))
+	versions, err = versionedFS.Versions("/home/user/dir/a")
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(versions))
+	assert.NoError(t, versionedFS.Mkdir("/home/user/dir", 0o755))
+	assert.NoError(t, versionedFS.Restore("/home/user/dir/a", versions[0].ID))
+	vfst.RunTests(t, fileSystem, "",
+		vfst.TestPath("/home/user/dir/a",
+			vfst.TestModeIsRegular(),
+			vfst.TestContentsString("a\n"),
+		),
+	)
+
+	// Pruning by count keeps the most recent versions.
+	removed, err := versionedFS.Prune(0, 2)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, removed)
+	versions, err = versionedFS.Versions("/home/user/file")
+	assert.NoError(t, err)
+	assert.Equal(t, 2, len(versions))
+	data, err = versionedFS.ReadVersion("/home/user/file", versions[1].ID)
+	assert.NoError(t, err)
+	assert.Equal(t, "new\n", string(data))
+
+	// Pruning by age removes old versions.
+	removed, err = versionedFS.Prune(time.Hour, 0)
+	assert.NoError(t, err)
+	assert.Equal(t, 0, removed)
+	removed, err = versionedFS.Prune(time.Nanosecond, 0)
+	assert.NoError(t, err)
+	assert.Equal(t, 3, removed)
+	vfst.RunTests(t, fileSystem, "",
+		vfst.TestPath("/home/user/.versions",
+			vfst.TestIsDir(),
+		),
+	)
+	dirEntries, err = fileSystem.ReadDir("/home/user/.versions")
+	assert.NoError(t, err)
+	assert.Equal(t, 0, len(dirEntries))
+
+	// Removing an ancestor of the versions directory keeps the versions.
+	assert.NoError(t, versionedFS.RemoveAll("/home/user"))
+	versions, err = versionedFS.Versions("/home/user/file")
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(versions))
+	data, err = versionedFS.ReadVersion("/home/user/file", versions[0].ID)
+	assert.NoError(t, err)
+	assert.Equal(t, "v1\n", string(data))
+	vfst.RunTests(t, fileSystem, "",
+		vfst.TestPath("/home/user/file",
+			vfst.TestDoesNotExist(),
+		),
+		vfst.TestPath("/home/user/dir",
+			vfst.TestDoesNotExist(),
+		),
+	)
+
+	// Paths longer than the maximum length of a name can be versioned.
+	longDir := "/home/user/" + strings.Repeat("d", 200)
+	longName := longDir + "/" + strings.Repeat("f", 200)
+	assert.NoError(t, vfs.MkdirAll(fileSystem, longDir, 0o755))
+	assert.NoError(t, versionedFS.WriteFile(longName, []byte("long 1\n"), 0o644))
+	assert.NoError(t, versionedFS.WriteFile(longName, []byte("long 2\n"), 0o644))
+	versions, err = versionedFS.Versions(longName)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(versions))
+	data, err = versionedFS.ReadVersion(longName, versions[0].ID)
+	assert.NoError(t, err)
+	assert.Equal(t, "long 1\n", string(data))
+}
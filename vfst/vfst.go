@@ -18,16 +18,20 @@ import (
 
 var umask fs.FileMode
 
-// A Dir is a directory with a specified permissions and zero or more Entries.
+// A Dir is a directory with a specified permissions, zero or more Entries, and
+// optional extended attributes.
 type Dir struct {
 	Perm    fs.FileMode
 	Entries map[string]any
+	Xattrs  map[string][]byte
 }
 
-// A File is a file with a specified permissions and contents.
+// A File is a file with a specified permissions, contents, and optional
+// extended attributes.
 type File struct {
 	Perm     fs.FileMode
 	Contents []byte
+	Xattrs   map[string][]byte
 }
 
 // A Symlink is a symbolic link with a specified target.
@@ -35,6 +39,10 @@ type Symlink struct {
 	Target string
 }
 
+// An Absent is a path that must not exist. Builder removes it and TestTree
+// requires that it does not exist.
+type Absent struct{}
+
 // A Test is a test on an vfs.FS.
 type Test func(*testing.T, vfs.FS)
 
@@ -128,6 +136,33 @@ func (b *Builder) MkdirAll(fileSystem vfs.FS, path string, perm fs.FileMode) err
 	return vfs.MkdirAll(fileSystem, path, perm&^b.umask)
 }
 
+// RemoveAll removes path and any children it contains. It is idempotent and
+// will not fail if path does not exist.
+func (b *Builder) RemoveAll(fileSystem vfs.FS, path string) error {
+	if _, err := fileSystem.Lstat(path); errors.Is(err, fs.ErrNotExist) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	if b.verbose {
+		log.Printf("rm -rf %s", path)
+	}
+	return fileSystem.RemoveAll(path)
+}
+
+// Setxattr sets the extended attribute attr on path to value. fileSystem must
+// implement vfs.XattrFS.
+func (b *Builder) Setxattr(fileSystem vfs.FS, path, attr string, value []byte) error {
+	xattrFS, ok := fileSystem.(vfs.XattrFS)
+	if !ok {
+		return fmt.Errorf("%s: %T does not support extended attributes", path, fileSystem)
+	}
+	if b.verbose {
+		log.Printf("setfattr -n %s -v %q %s", attr, value, path)
+	}
+	return xattrFS.Setxattr(path, attr, value)
+}
+
 // Symlink creates a symbolic link from newname to oldname. It will create any
 // missing parent directories with default permissions. It is idempotent and
 // will not fail if the symbolic link already exists and points to oldname.
@@ -255,38 +290,18 @@ func RunTests(t *testing.T, fileSystem vfs.FS, name string, tests ...any) {
 // TestContents returns a PathTest that verifies the contents of the file are
 // equal to wantContents.
 func TestContents(wantContents []byte) PathTest {
-	return func(t *testing.T, fileSystem vfs.FS, path string) {
-		t.Helper()
-		if gotContents, err := fileSystem.ReadFile(path); err != nil || !bytes.Equal(gotContents, wantContents) {
-			t.Errorf("fileSystem.ReadFile(%q) == %v, %v, want %v, <nil>", path, gotContents, err, wantContents)
-		}
-	}
+	return TestCheck(CheckContents(wantContents))
 }
 
 // TestContentsString returns a PathTest that verifies the contetnts of the
 // file are equal to wantContentsStr.
 func TestContentsString(wantContentsStr string) PathTest {
-	return func(t *testing.T, fileSystem vfs.FS, path string) {
-		t.Helper()
-		if gotContents, err := fileSystem.ReadFile(path); err != nil || string(gotContents) != wantContentsStr {
-			t.Errorf("fileSystem.ReadFile(%q) == %q, %v, want %q, <nil>", path, gotContents, err, wantContentsStr)
-		}
-	}
-}
-
-// testDoesNotExist is a PathTest that verifies that a file or directory does
-// not exist.
-var testDoesNotExist = func(t *testing.T, fileSystem vfs.FS, path string) {
-	t.Helper()
-	_, err := fileSystem.Lstat(path)
-	if got, want := errors.Is(err, fs.ErrNotExist), true; got != want {
-		t.Errorf("_, err := fileSystem.Lstat(%q); errors.Is(err, fs.ErrNotExist) == %v, want %v", path, got, want)
-	}
+	return TestCheck(CheckContentsString(wantContentsStr))
 }
 
 // TestDoesNotExist is a PathTest that verifies that a file or directory does
 // not exist.
-var TestDoesNotExist = func() PathTest { return testDoesNotExist }
+var TestDoesNotExist = func() PathTest { return TestCheck(CheckDoesNotExist()) }
 
 // TestIsDir is a PathTest that verifies that the path is a directory.
 var TestIsDir = func() PathTest { return TestModeType(fs.ModeDir) }
@@ -294,17 +309,7 @@ var TestIsDir = func() PathTest { return TestModeType(fs.ModeDir) }
 // TestModePerm returns a PathTest that verifies that the path's permissions
 // are equal to wantPerm.
 func TestModePerm(wantPerm fs.FileMode) PathTest {
-	return func(t *testing.T, fileSystem vfs.FS, path string) {
-		t.Helper()
-		info, err := fileSystem.Lstat(path)
-		if err != nil {
-			t.Errorf("fileSystem.Lstat(%q) == %+v, %v, want !<nil>, <nil>", path, info, err)
-			return
-		}
-		if gotPerm := info.Mode() & fs.ModePerm; !PermEqual(gotPerm, wantPerm) {
-			t.Errorf("fileSystem.Lstat(%q).Mode()&fs.ModePerm == 0%o, want 0%o", path, gotPerm, wantPerm)
-		}
-	}
+	return TestCheck(CheckModePerm(wantPerm))
 }
 
 // TestModeIsRegular is a PathTest that tests that the path is a regular file.
@@ -313,17 +318,13 @@ var TestModeIsRegular = func() PathTest { return TestModeType(0) }
 // TestModeType returns a PathTest that verifies that the path's mode type is
 // equal to wantModeType.
 func TestModeType(wantModeType fs.FileMode) PathTest {
-	return func(t *testing.T, fileSystem vfs.FS, path string) {
-		t.Helper()
-		info, err := fileSystem.Lstat(path)
-		if err != nil {
-			t.Errorf("fileSystem.Lstat(%q) == %+v, %v, want !<nil>, <nil>", path, info, err)
-			return
-		}
-		if gotModeType := info.Mode() & fs.ModeType; gotModeType != wantModeType {
-			t.Errorf("fileSystem.Lstat(%q).Mode()&fs.ModeType == %v, want %v", path, gotModeType, wantModeType)
-		}
-	}
+	return TestCheck(CheckModeType(wantModeType))
+}
+
+// TestXattr returns a PathTest that verifies that the extended attribute attr of
+// path is equal to wantValue.
+func TestXattr(attr string, wantValue []byte) PathTest {
+	return TestCheck(CheckXattr(attr, wantValue))
 }
 
 // TestPath returns a Test that runs pathTests on path.
@@ -342,44 +343,18 @@ func TestPath(path string, pathTests ...PathTest) Test {
 // TestSize returns a PathTest that tests that path's Size() is equal to
 // wantSize.
 func TestSize(wantSize int64) PathTest {
-	return func(t *testing.T, fileSystem vfs.FS, path string) {
-		t.Helper()
-		info, err := fileSystem.Lstat(path)
-		if err != nil {
-			t.Errorf("fileSystem.Lstat(%q) == %+v, %v, want !<nil>, <nil>", path, info, err)
-			return
-		}
-		if gotSize := info.Size(); gotSize != wantSize {
-			t.Errorf("fileSystem.Lstat(%q).Size() == %d, want %d", path, gotSize, wantSize)
-		}
-	}
+	return TestCheck(CheckSize(wantSize))
 }
 
 // TestSymlinkTarget returns a PathTest that tests that path's target is wantTarget.
 func TestSymlinkTarget(wantTarget string) PathTest {
-	return func(t *testing.T, fileSystem vfs.FS, path string) {
-		t.Helper()
-		if gotTarget, err := fileSystem.Readlink(path); err != nil || gotTarget != wantTarget {
-			t.Errorf("fileSystem.Readlink(%q) == %q, %v, want %q, <nil>", path, gotTarget, err, wantTarget)
-			return
-		}
-	}
+	return TestCheck(CheckSymlinkTarget(wantTarget))
 }
 
 // TestMinSize returns a PathTest that tests that path's Size() is at least
 // wantMinSize.
 func TestMinSize(wantMinSize int64) PathTest {
-	return func(t *testing.T, fileSystem vfs.FS, path string) {
-		t.Helper()
-		info, err := fileSystem.Lstat(path)
-		if err != nil {
-			t.Errorf("fileSystem.Lstat(%q) == %+v, %v, want !<nil>, <nil>", path, info, err)
-			return
-		}
-		if gotSize := info.Size(); gotSize < wantMinSize {
-			t.Errorf("fileSystem.Lstat(%q).Size() == %d, want >=%d", path, gotSize, wantMinSize)
-		}
-	}
+	return TestCheck(CheckMinSize(wantMinSize))
 }
 
 // build is a recursive helper for Build.
@@ -401,6 +376,9 @@ func (b *Builder) build(fileSystem vfs.FS, path string, i any) error {
 		if err := b.Mkdir(fileSystem, path, i.Perm); err != nil {
 			return err
 		}
+		if err := b.setxattrs(fileSystem, path, i.Xattrs); err != nil {
+			return err
+		}
 		entryNames := make([]string, 0, len(i.Entries))
 		for entryName := range i.Entries {
 			entryNames = append(entryNames, entryName)
@@ -443,16 +421,36 @@ func (b *Builder) build(fileSystem vfs.FS, path string, i any) error {
 		}
 		return nil
 	case *File:
-		return b.WriteFile(fileSystem, path, i.Contents, i.Perm)
+		if err := b.WriteFile(fileSystem, path, i.Contents, i.Perm); err != nil {
+			return err
+		}
+		return b.setxattrs(fileSystem, path, i.Xattrs)
 	case string:
 		return b.WriteFile(fileSystem, path, []byte(i), 0o666)
 	case []byte:
 		return b.WriteFile(fileSystem, path, i, 0o666)
 	case *Symlink:
 		return b.Symlink(fileSystem, i.Target, path)
+	case *Absent:
+		return b.RemoveAll(fileSystem, path)
 	case nil:
 		return nil
 	default:
 		return fmt.Errorf("%s: unsupported type %T", path, i)
 	}
 }
+
+// setxattrs sets all xattrs on path.
+func (b *Builder) setxattrs(fileSystem vfs.FS, path string, xattrs map[string][]byte) error {
+	attrs := make([]string, 0, len(xattrs))
+	for attr := range xattrs {
+		attrs = append(attrs, attr)
+	}
+	sort.Strings(attrs)
+	for _, attr := range attrs {
+		if err := b.Setxattr(fileSystem, path, attr, xattrs[attr]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
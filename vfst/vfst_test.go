@@ -1,9 +1,11 @@
 package vfst_test
 
 import (
+	"encoding/json"
 	"errors"
 	"io/fs"
 	"path/filepath"
+	"regexp"
 	"strings"
 	"testing"
 
@@ -46,6 +48,9 @@ func TestBuilderBuild(t *testing.T) {
 					vfst.TestModePerm(0o644),
 					vfst.TestContentsString("baz"),
 				),
+				vfst.TestTree("/foo", map[string]any{
+					"bar": "baz",
+				}),
 			},
 		},
 		{
@@ -108,6 +113,33 @@ func TestBuilderBuild(t *testing.T) {
 				),
 			},
 		},
+		{
+			name:  "absent",
+			umask: 0o22,
+			root: []any{
+				map[string]any{
+					"/foo":     "bar",
+					"/baz/qux": "quux",
+				},
+				map[string]any{
+					"/baz":      &vfst.Absent{},
+					"/foo":      &vfst.Absent{},
+					"/notexist": &vfst.Absent{},
+				},
+			},
+			tests: []vfst.Test{
+				vfst.TestPath("/baz",
+					vfst.TestDoesNotExist(),
+				),
+				vfst.TestPath("/foo",
+					vfst.TestDoesNotExist(),
+				),
+				vfst.TestTree("/", map[string]any{
+					"/baz/qux": &vfst.Absent{},
+					"/foo":     &vfst.Absent{},
+				}),
+			},
+		},
 		{
 			name:  "symlink",
 			umask: 0o22,
@@ -367,3 +399,27 @@ func TestIdempotency(t *testing.T) {
 		})
 	}
 }
+
+func TestContentsMatchers(t *testing.T) {
+	fileSystem := vfst.NewTestFSWithT(t, map[string]any{
+		"/home/user/config.json": `{"b": [1, 2], "a": {"c": true}}`,
+		"/home/user/log":         "2006-01-02T15:04:05Z started id=4c6f\nworker ready\nworker ready\n",
+	})
+	vfst.RunTests(t, fileSystem, "",
+		vfst.TestPath("/home/user/config.json",
+			vfst.TestContentsJSON(`{"a":{"c":true},"b":[1,2.0]}`),
+			vfst.TestContentsUnmarshal(json.Unmarshal, []byte(`{"a": {"c": true}, "b": [1, 2]}`)),
+			vfst.TestContentsFunc(func(contents []byte) error {
+				if !json.Valid(contents) {
+					return errors.New("invalid JSON")
+				}
+				return nil
+			}),
+		),
+		vfst.TestPath("/home/user/log",
+			vfst.TestContentsContains("worker ready\n"),
+			vfst.TestContentsMatchRegexp(regexp.MustCompile(`\A\d{4}-\d{2}-\d{2}T\S+ started id=[0-9a-f]+\n`)),
+			vfst.TestContentsLineSet("worker ready", "2006-01-02T15:04:05Z started id=4c6f"),
+		),
+	)
+}
@@ -0,0 +1,101 @@
+package vfst_test
+
+import (
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/alecthomas/assert/v2"
+
+	vfs "github.com/twpayne/go-vfs/v5"
+	"github.com/twpayne/go-vfs/v5/vfst"
+)
+
+func TestNotifyingFSWatch(t *testing.T) {
+	fileSystem := vfs.NewNotifyingFS(vfst.NewTestFSWithT(t, map[string]any{
+		"/home/user/.bashrc":  "# contents of .bashrc\n",
+		"/home/user/dir/file": "",
+		"/tmp":                &vfst.Dir{Perm: 0o777},
+	}))
+
+	watch, err := fileSystem.Watch("/home/user", false)
+	assert.NoError(t, err)
+	defer watch.Close()
+	recursiveWatch, err := fileSystem.Watch("/home/user", true)
+	assert.NoError(t, err)
+	defer recursiveWatch.Close()
+
+	assert.NoError(t, fileSystem.WriteFile("/home/user/.bashrc", []byte("# new contents of .bashrc\n"), 0o644))
+	assert.NoError(t, fileSystem.WriteFile("/home/user/.zshrc", nil, 0o644))
+	assert.NoError(t, fileSystem.WriteFile("/tmp/file", nil, 0o644))
+	assert.NoError(t, fileSystem.Chmod("/home/user/.zshrc", 0o600))
+	assert.NoError(t, fileSystem.Rename("/home/user/.zshrc", "/home/user/dir/.zshrc"))
+	assert.NoError(t, fileSystem.RemoveAll("/home/user/dir"))
+
+	assert.Equal(t, []vfs.Event{
+		{Name: "/home/user/.bashrc", Op: vfs.OpWrite},
+		{Name: "/home/user/.zshrc", Op: vfs.OpCreate},
+		{Name: "/home/user/.zshrc", Op: vfs.OpChmod},
+		{Name: "/home/user/.zshrc", Op: vfs.OpRename},
+		{Name: "/home/user/dir", Op: vfs.OpRemove},
+	}, receiveEvents(t, watch, 5))
+	assert.Equal(t, []vfs.Event{
+		{Name: "/home/user/.bashrc", Op: vfs.OpWrite},
+		{Name: "/home/user/.zshrc", Op: vfs.OpCreate},
+		{Name: "/home/user/.zshrc", Op: vfs.OpChmod},
+		{Name: "/home/user/.zshrc", Op: vfs.OpRename},
+		{Name: "/home/user/dir/.zshrc", Op: vfs.OpCreate},
+		{Name: "/home/user/dir/file", Op: vfs.OpRemove},
+		{Name: "/home/user/dir/.zshrc", Op: vfs.OpRemove},
+		{Name: "/home/user/dir", Op: vfs.OpRemove},
+	}, receiveEvents(t, recursiveWatch, 8))
+
+	assert.NoError(t, watch.Close())
+	_, ok := <-watch.Events()
+	assert.False(t, ok)
+}
+
+func TestPathFSWatch(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("Watch is only implemented on Linux")
+	}
+	fileSystem := vfst.NewTestFSWithT(t, map[string]any{
+		"/home/user": &vfst.Dir{Perm: 0o755},
+	})
+
+	watch, err := fileSystem.Watch("/home/user", true)
+	assert.NoError(t, err)
+	defer watch.Close()
+
+	assert.NoError(t, fileSystem.Mkdir("/home/user/dir", 0o755))
+	assert.Equal(t, []vfs.Event{
+		{Name: "/home/user/dir", Op: vfs.OpCreate},
+	}, receiveEvents(t, watch, 1))
+	assert.NoError(t, fileSystem.WriteFile("/home/user/dir/file", nil, 0o644))
+	assert.Equal(t, []vfs.Event{
+		{Name: "/home/user/dir/file", Op: vfs.OpCreate},
+	}, receiveEvents(t, watch, 1))
+	assert.NoError(t, fileSystem.Remove("/home/user/dir/file"))
+	assert.Equal(t, []vfs.Event{
+		{Name: "/home/user/dir/file", Op: vfs.OpRemove},
+	}, receiveEvents(t, watch, 1))
+}
+
+// receiveEvents receives n events from watch.
+func receiveEvents(t *testing.T, watch *vfs.Watch, n int) []vfs.Event {
+	t.Helper()
+	events := make([]vfs.Event, 0, n)
+	timeout := time.After(5 * time.Second)
+	for len(events) < n {
+		select {
+		case event, ok := <-watch.Events():
+			if !ok {
+				t.Fatalf("watch closed after %d events, want %d", len(events), n)
+			}
+			events = append(events, event)
+		case <-timeout:
+			t.Fatalf("timed out after %d events, want %d", len(events), n)
+		}
+	}
+	return events
+}
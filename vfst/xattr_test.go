@@ -0,0 +1,68 @@
+package vfst_test
+
+import (
+	"errors"
+	"io/fs"
+	"testing"
+
+	"github.com/alecthomas/assert/v2"
+
+	vfs "github.com/twpayne/go-vfs/v5"
+	"github.com/twpayne/go-vfs/v5/vfst"
+)
+
+func TestXattr(t *testing.T) {
+	if _, ok := any(vfs.OSFS).(vfs.XattrFS); !ok {
+		t.Skip("extended attributes are not supported on this platform")
+	}
+	fileSystem := vfst.NewEmptyTestFSWithT(t)
+	if err := fileSystem.WriteFile("/probe", nil, 0o666); err != nil {
+		t.Fatal(err)
+	}
+	if err := fileSystem.Setxattr("/probe", "user.probe", nil); err != nil {
+		t.Skipf("extended attributes are not supported: %v", err)
+	}
+
+	root := map[string]any{
+		"/home/user/file": &vfst.File{
+			Perm:     0o644,
+			Contents: []byte("contents"),
+			Xattrs: map[string][]byte{
+				"user.checksum":   []byte("sha256:0"),
+				"user.provenance": []byte("build"),
+			},
+		},
+		"/home/user/dir": &vfst.Dir{
+			Perm: 0o755,
+			Xattrs: map[string][]byte{
+				"user.empty": {},
+			},
+		},
+		"/probe": &vfst.Absent{},
+	}
+	assert.NoError(t, vfst.NewBuilder().Build(fileSystem, root))
+	vfst.RunTests(t, fileSystem, "",
+		vfst.TestPath("/home/user/file",
+			vfst.TestXattr("user.checksum", []byte("sha256:0")),
+		),
+		vfst.TestTree("/", root),
+	)
+
+	attrs, err := fileSystem.Listxattr("/home/user/file")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"user.checksum", "user.provenance"}, attrs)
+
+	assert.NoError(t, fileSystem.Removexattr("/home/user/file", "user.checksum"))
+	_, err = fileSystem.Getxattr("/home/user/file", "user.checksum")
+	assert.IsError(t, err, vfs.ErrNoXattr)
+	var checkError *vfst.CheckError
+	assert.True(t, errors.As(vfst.CheckTree(fileSystem, "/", root), &checkError))
+	assert.IsError(t, checkError, vfs.ErrNoXattr)
+
+	readOnlyFS := vfs.NewReadOnlyFS(fileSystem)
+	value, err := readOnlyFS.Getxattr("/home/user/file", "user.provenance")
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("build"), value)
+	assert.IsError(t, readOnlyFS.Setxattr("/home/user/file", "user.provenance", nil), fs.ErrPermission)
+	assert.IsError(t, readOnlyFS.Removexattr("/home/user/file", "user.provenance"), fs.ErrPermission)
+}
@@ -0,0 +1,167 @@
+package vfs
+
+import (
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// An Op is a set of filesystem operations reported in an Event.
+type Op uint32
+
+// Ops.
+const (
+	OpCreate Op = 1 << iota
+	OpWrite
+	OpRemove
+	OpRename
+	OpChmod
+)
+
+// An Event is a change to a path.
+type Event struct {
+	Name string
+	Op   Op
+}
+
+// A Watch is an active watch returned by a Watcher.
+type Watch struct {
+	events    <-chan Event
+	closeFunc func() error
+	closeOnce sync.Once
+	closeErr  error
+}
+
+// A Watcher is an FS that can watch paths for changes.
+type Watcher interface {
+	Watch(name string, recursive bool) (*Watch, error)
+}
+
+// NewWatch returns a new *Watch that delivers events and calls closeFunc when
+// closed. It is intended for use by implementations of Watcher. The
+// implementation must close events after closeFunc is called.
+func NewWatch(events <-chan Event, closeFunc func() error) *Watch {
+	return &Watch{
+		events:    events,
+		closeFunc: closeFunc,
+	}
+}
+
+// Close stops w. The channel returned by Events is closed. It is safe to call
+// Close multiple times.
+func (w *Watch) Close() error {
+	w.closeOnce.Do(func() {
+		w.closeErr = w.closeFunc()
+	})
+	return w.closeErr
+}
+
+// Events returns the channel on which w delivers events.
+func (w *Watch) Events() <-chan Event {
+	return w.events
+}
+
+// String returns a string representation of op.
+func (op Op) String() string {
+	var names []string
+	for _, o := range []struct {
+		op   Op
+		name string
+	}{
+		{OpCreate, "CREATE"},
+		{OpWrite, "WRITE"},
+		{OpRemove, "REMOVE"},
+		{OpRename, "RENAME"},
+		{OpChmod, "CHMOD"},
+	} {
+		if op&o.op != 0 {
+			names = append(names, o.name)
+		}
+	}
+	return strings.Join(names, "|")
+}
+
+// An eventQueue is an unbounded queue of Events that are delivered in order on
+// a channel.
+type eventQueue struct {
+	mu     sync.Mutex
+	events []Event
+	notify chan struct{}
+	done   chan struct{}
+	once   sync.Once
+	out    chan Event
+}
+
+// newEventQueue returns a new *eventQueue and starts delivering its events.
+func newEventQueue() *eventQueue {
+	q := &eventQueue{
+		notify: make(chan struct{}, 1),
+		done:   make(chan struct{}),
+		out:    make(chan Event),
+	}
+	go q.run()
+	return q
+}
+
+// close stops q and closes its output channel.
+func (q *eventQueue) close() error {
+	q.once.Do(func() {
+		close(q.done)
+	})
+	return nil
+}
+
+// push adds event to q.
+func (q *eventQueue) push(event Event) {
+	q.mu.Lock()
+	q.events = append(q.events, event)
+	q.mu.Unlock()
+	select {
+	case q.notify <- struct{}{}:
+	default:
+	}
+}
+
+// run delivers q's events until q is closed.
+func (q *eventQueue) run() {
+	defer close(q.out)
+	for {
+		q.mu.Lock()
+		if len(q.events) == 0 {
+			q.mu.Unlock()
+			select {
+			case <-q.notify:
+				continue
+			case <-q.done:
+				return
+			}
+		}
+		event := q.events[0]
+		q.events = q.events[1:]
+		q.mu.Unlock()
+		select {
+		case q.out <- event:
+		case <-q.done:
+			return
+		}
+	}
+}
+
+// watchMatches returns whether an event on eventName should be reported by a
+// watch on name.
+func watchMatches(name string, recursive bool, eventName string) bool {
+	switch {
+	case eventName == name:
+		return true
+	case filepath.Dir(eventName) == name:
+		return true
+	case recursive:
+		prefix := name
+		if !strings.HasSuffix(prefix, string(filepath.Separator)) {
+			prefix += string(filepath.Separator)
+		}
+		return strings.HasPrefix(eventName, prefix)
+	default:
+		return false
+	}
+}
@@ -0,0 +1,191 @@
+package vfs
+
+import (
+	"bytes"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sync"
+	"unsafe"
+
+	"golang.org/x/sys/unix"
+)
+
+const inotifyMask = unix.IN_ATTRIB |
+	unix.IN_CREATE |
+	unix.IN_DELETE |
+	unix.IN_DELETE_SELF |
+	unix.IN_MODIFY |
+	unix.IN_MOVE_SELF |
+	unix.IN_MOVED_FROM |
+	unix.IN_MOVED_TO
+
+// An inotifyWatch is a watch implemented with inotify.
+type inotifyWatch struct {
+	file      *os.File
+	recursive bool
+	queue     *eventQueue
+	mu        sync.Mutex
+	paths     map[int]string
+}
+
+// Watch implements Watcher.Watch with inotify. If recursive is true then
+// directories created after the watch starts are also watched.
+func (osfs) Watch(name string, recursive bool) (*Watch, error) {
+	fd, err := unix.InotifyInit1(unix.IN_CLOEXEC | unix.IN_NONBLOCK)
+	if err != nil {
+		return nil, os.NewSyscallError("inotify_init1", err)
+	}
+	w := &inotifyWatch{
+		file:      os.NewFile(uintptr(fd), "inotify"),
+		recursive: recursive,
+		paths:     make(map[int]string),
+	}
+	if err := w.add(name); err != nil {
+		w.file.Close()
+		return nil, err
+	}
+	w.queue = newEventQueue()
+	go w.run()
+	return NewWatch(w.queue.out, func() error {
+		err := w.file.Close()
+		_ = w.queue.close()
+		return err
+	}), nil
+}
+
+// add adds name to w, and, if w is recursive, all directories below name.
+func (w *inotifyWatch) add(name string) error {
+	if err := w.addOne(name); err != nil {
+		return err
+	}
+	if !w.recursive {
+		return nil
+	}
+	return Walk(OSFS, name, func(path string, info fs.FileInfo, err error) error {
+		switch {
+		case errors.Is(err, fs.ErrNotExist):
+			return nil
+		case err != nil:
+			return err
+		case path == name || !info.IsDir():
+			return nil
+		default:
+			return w.addOne(path)
+		}
+	})
+}
+
+// addOne adds a single inotify watch on name.
+func (w *inotifyWatch) addOne(name string) error {
+	var wd int
+	var err error
+	if rawConnErr := w.rawControl(func(fd int) {
+		wd, err = unix.InotifyAddWatch(fd, name, inotifyMask)
+	}); rawConnErr != nil {
+		return rawConnErr
+	}
+	if err != nil {
+		return &os.PathError{
+			Op:   "Watch",
+			Path: name,
+			Err:  err,
+		}
+	}
+	w.mu.Lock()
+	w.paths[wd] = name
+	w.mu.Unlock()
+	return nil
+}
+
+// rawControl calls f with w's file descriptor.
+func (w *inotifyWatch) rawControl(f func(int)) error {
+	rawConn, err := w.file.SyscallConn()
+	if err != nil {
+		return err
+	}
+	return rawConn.Control(func(fd uintptr) {
+		f(int(fd)) //nolint:gosec
+	})
+}
+
+// run reads inotify events from w's file and pushes them on to w's queue until
+// w's file is closed.
+func (w *inotifyWatch) run() {
+	buf := make([]byte, 64*(unix.SizeofInotifyEvent+unix.NAME_MAX+1))
+	for {
+		n, err := w.file.Read(buf)
+		if err != nil {
+			_ = w.queue.close()
+			return
+		}
+		for offset := 0; offset+unix.SizeofInotifyEvent <= n; {
+			rawEvent := (*unix.InotifyEvent)(unsafe.Pointer(&buf[offset])) //nolint:gosec
+			nameBytes := buf[offset+unix.SizeofInotifyEvent : offset+unix.SizeofInotifyEvent+int(rawEvent.Len)]
+			offset += unix.SizeofInotifyEvent + int(rawEvent.Len)
+			w.handle(int(rawEvent.Wd), rawEvent.Mask, string(bytes.TrimRight(nameBytes, "\x00")))
+		}
+	}
+}
+
+// handle converts a single inotify event into an Event.
+func (w *inotifyWatch) handle(wd int, mask uint32, name string) {
+	w.mu.Lock()
+	dir, ok := w.paths[wd]
+	if mask&unix.IN_IGNORED != 0 {
+		delete(w.paths, wd)
+	}
+	w.mu.Unlock()
+	if !ok {
+		return
+	}
+	path := dir
+	if name != "" {
+		path = filepath.Join(dir, name)
+	}
+	var op Op
+	if mask&(unix.IN_CREATE|unix.IN_MOVED_TO) != 0 {
+		op |= OpCreate
+	}
+	if mask&unix.IN_MODIFY != 0 {
+		op |= OpWrite
+	}
+	if mask&(unix.IN_DELETE|unix.IN_DELETE_SELF) != 0 {
+		op |= OpRemove
+	}
+	if mask&(unix.IN_MOVED_FROM|unix.IN_MOVE_SELF) != 0 {
+		op |= OpRename
+	}
+	if mask&unix.IN_ATTRIB != 0 {
+		op |= OpChmod
+	}
+	if op == 0 {
+		return
+	}
+	// A directory deleted or moved from its parent is also reported on the
+	// directory itself, so only report it once.
+	if name == "" && mask&(unix.IN_DELETE_SELF|unix.IN_MOVE_SELF) != 0 && w.watchedParent(dir) {
+		return
+	}
+	if w.recursive && op&OpCreate != 0 && mask&unix.IN_ISDIR != 0 {
+		_ = w.add(path)
+	}
+	w.queue.push(Event{
+		Name: path,
+		Op:   op,
+	})
+}
+
+// watchedParent returns whether the parent directory of path is watched.
+func (w *inotifyWatch) watchedParent(path string) bool {
+	parent := filepath.Dir(path)
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	for _, watchedPath := range w.paths {
+		if watchedPath == parent {
+			return true
+		}
+	}
+	return false
+}
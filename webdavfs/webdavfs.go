@@ -0,0 +1,157 @@
+// Package webdavfs serves a github.com/twpayne/go-vfs FS over WebDAV.
+package webdavfs
+
+import (
+	"context"
+	"io/fs"
+	"net/http"
+	"os"
+	"path"
+
+	"golang.org/x/net/webdav"
+
+	vfs "github.com/twpayne/go-vfs/v5"
+)
+
+// writeFlags are the flags that open a file for writing.
+const writeFlags = os.O_WRONLY | os.O_RDWR | os.O_APPEND | os.O_CREATE | os.O_TRUNC
+
+// readOnlyMethods are the WebDAV methods that do not modify the filesystem.
+var readOnlyMethods = map[string]struct{}{
+	http.MethodGet:     {},
+	http.MethodHead:    {},
+	http.MethodOptions: {},
+	"PROPFIND":         {},
+}
+
+// A FileSystem implements webdav.FileSystem on an existing vfs.FS. All names
+// are treated as absolute paths in the vfs.FS, so the vfs.FS is typically a
+// *vfs.PathFS. Files opened for reading are read with the vfs.FS's Open, Stat,
+// and ReadDir, so they are served by any vfs.FS. Files opened for writing are
+// opened with the vfs.FS's OpenFile, so vfs.FSs that do not support OpenFile
+// are read-only.
+type FileSystem struct {
+	fileSystem vfs.FS
+	httpFS     *vfs.HTTPFS
+}
+
+// A HandlerOption sets an option on a handler returned by NewHandler.
+type HandlerOption func(*handler)
+
+// A handler is an http.Handler that serves a vfs.FS over WebDAV.
+type handler struct {
+	webdavHandler *webdav.Handler
+	readOnly      bool
+}
+
+// A readOnlyFile is a webdav.File open for reading.
+type readOnlyFile struct {
+	http.File
+	name string
+}
+
+// HandlerReadOnly sets whether a handler rejects requests with methods that
+// would modify the vfs.FS with 403 Forbidden. The default is true if the
+// vfs.FS is a *vfs.ReadOnlyFS and false otherwise. Read-only vfs.FSs that are
+// wrapped in other vfs.FSs, for example a *vfs.PathFS, are not detected, so
+// this must be set explicitly for them.
+func HandlerReadOnly(readOnly bool) HandlerOption {
+	return func(h *handler) {
+		h.readOnly = readOnly
+	}
+}
+
+// NewFileSystem returns a new *FileSystem operating on fileSystem.
+func NewFileSystem(fileSystem vfs.FS) *FileSystem {
+	return &FileSystem{
+		fileSystem: fileSystem,
+		httpFS:     vfs.NewHTTPFS(fileSystem, "/"),
+	}
+}
+
+// NewHandler returns an http.Handler that serves fileSystem over WebDAV at
+// prefix, with locks held in memory, with the given options set.
+func NewHandler(fileSystem vfs.FS, prefix string, options ...HandlerOption) http.Handler {
+	_, readOnly := fileSystem.(*vfs.ReadOnlyFS)
+	h := &handler{
+		webdavHandler: &webdav.Handler{
+			Prefix:     prefix,
+			FileSystem: NewFileSystem(fileSystem),
+			LockSystem: webdav.NewMemLS(),
+		},
+		readOnly: readOnly,
+	}
+	for _, option := range options {
+		option(h)
+	}
+	return h
+}
+
+// Mkdir implements webdav.FileSystem.Mkdir.
+func (f *FileSystem) Mkdir(ctx context.Context, name string, perm fs.FileMode) error {
+	return f.fileSystem.Mkdir(clean(name), perm)
+}
+
+// OpenFile implements webdav.FileSystem.OpenFile.
+func (f *FileSystem) OpenFile(ctx context.Context, name string, flag int, perm fs.FileMode) (webdav.File, error) {
+	name = clean(name)
+	if flag&writeFlags == 0 {
+		file, err := f.httpFS.Open(name)
+		if err != nil {
+			return nil, err
+		}
+		return &readOnlyFile{
+			File: file,
+			name: name,
+		}, nil
+	}
+	file, err := f.fileSystem.OpenFile(name, flag, perm)
+	if err != nil {
+		return nil, err
+	}
+	return file, nil
+}
+
+// RemoveAll implements webdav.FileSystem.RemoveAll. The root directory cannot
+// be removed.
+func (f *FileSystem) RemoveAll(ctx context.Context, name string) error {
+	name = clean(name)
+	if name == "/" {
+		return &os.PathError{Op: "RemoveAll", Path: name, Err: fs.ErrInvalid}
+	}
+	return f.fileSystem.RemoveAll(name)
+}
+
+// Rename implements webdav.FileSystem.Rename. The root directory cannot be
+// renamed.
+func (f *FileSystem) Rename(ctx context.Context, oldName, newName string) error {
+	oldName, newName = clean(oldName), clean(newName)
+	if oldName == "/" || newName == "/" {
+		return &os.LinkError{Op: "Rename", Old: oldName, New: newName, Err: fs.ErrInvalid}
+	}
+	return f.fileSystem.Rename(oldName, newName)
+}
+
+// Stat implements webdav.FileSystem.Stat.
+func (f *FileSystem) Stat(ctx context.Context, name string) (fs.FileInfo, error) {
+	return f.fileSystem.Stat(clean(name))
+}
+
+// ServeHTTP implements http.Handler.ServeHTTP.
+func (h *handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if _, ok := readOnlyMethods[r.Method]; h.readOnly && !ok {
+		http.Error(w, http.StatusText(http.StatusForbidden), http.StatusForbidden)
+		return
+	}
+	h.webdavHandler.ServeHTTP(w, r)
+}
+
+// Write implements io.Writer.Write.
+func (f *readOnlyFile) Write(p []byte) (int, error) {
+	return 0, &os.PathError{Op: "write", Path: f.name, Err: fs.ErrPermission}
+}
+
+// clean returns name as a clean absolute path.
+func clean(name string) string {
+	return path.Clean("/" + name)
+}
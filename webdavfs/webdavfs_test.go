@@ -0,0 +1,190 @@
+package webdavfs_test
+
+import (
+	"context"
+	"errors"
+	"io"
+	"io/fs"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/alecthomas/assert/v2"
+	"golang.org/x/net/webdav"
+
+	vfs "github.com/twpayne/go-vfs/v5"
+	"github.com/twpayne/go-vfs/v5/vfst"
+	"github.com/twpayne/go-vfs/v5/webdavfs"
+)
+
+var _ webdav.FileSystem = &webdavfs.FileSystem{}
+
+// A noOpenFileFS is a vfs.FS that does not support OpenFile.
+type noOpenFileFS struct {
+	vfs.FS
+}
+
+func (noOpenFileFS) OpenFile(name string, flag int, perm fs.FileMode) (*os.File, error) {
+	return nil, &os.PathError{Op: "OpenFile", Path: name, Err: errors.ErrUnsupported}
+}
+
+type davClient struct {
+	t      *testing.T
+	server *httptest.Server
+}
+
+func (c *davClient) do(method, path, body string, header map[string]string) (int, string) {
+	c.t.Helper()
+	var bodyReader io.Reader
+	if body != "" {
+		bodyReader = strings.NewReader(body)
+	}
+	req, err := http.NewRequest(method, c.server.URL+path, bodyReader)
+	assert.NoError(c.t, err)
+	for key, value := range header {
+		req.Header.Set(key, value)
+	}
+	resp, err := c.server.Client().Do(req)
+	assert.NoError(c.t, err)
+	defer resp.Body.Close()
+	respBody, err := io.ReadAll(resp.Body)
+	assert.NoError(c.t, err)
+	return resp.StatusCode, string(respBody)
+}
+
+func TestFileSystem(t *testing.T) {
+	fileSystem := vfst.NewTestFSWithT(t, map[string]any{
+		"/workspace": map[string]any{
+			"README.md":   "# workspace\n",
+			"src/main.go": "package main\n",
+		},
+		"/outside": "outside\n",
+	})
+	server := httptest.NewServer(webdavfs.NewHandler(vfs.NewPathFS(fileSystem, "/workspace"), "/dav"))
+	defer server.Close()
+	client := &davClient{t: t, server: server}
+
+	status, body := client.do(http.MethodGet, "/dav/README.md", "", nil)
+	assert.Equal(t, http.StatusOK, status)
+	assert.Equal(t, "# workspace\n", body)
+
+	status, body = client.do("PROPFIND", "/dav/", "", map[string]string{"Depth": "1"})
+	assert.Equal(t, http.StatusMultiStatus, status)
+	assert.True(t, strings.Contains(body, "/dav/README.md"))
+	assert.True(t, strings.Contains(body, "/dav/src/"))
+	assert.False(t, strings.Contains(body, "outside"))
+
+	status, _ = client.do(http.MethodPut, "/dav/src/new.go", "package new\n", nil)
+	assert.Equal(t, http.StatusCreated, status)
+	status, _ = client.do("MKCOL", "/dav/docs", "", nil)
+	assert.Equal(t, http.StatusCreated, status)
+	status, _ = client.do("MOVE", "/dav/README.md", "", map[string]string{"Destination": server.URL + "/dav/docs/README.md"})
+	assert.Equal(t, http.StatusCreated, status)
+	status, _ = client.do(http.MethodDelete, "/dav/src/main.go", "", nil)
+	assert.Equal(t, http.StatusNoContent, status)
+	status, _ = client.do(http.MethodGet, "/dav/../outside", "", nil)
+	assert.Equal(t, http.StatusNotFound, status)
+
+	vfst.RunTests(t, fileSystem, "",
+		vfst.TestPath("/workspace/src/new.go",
+			vfst.TestContentsString("package new\n"),
+		),
+		vfst.TestPath("/workspace/docs/README.md",
+			vfst.TestContentsString("# workspace\n"),
+		),
+		vfst.TestPath("/workspace/README.md",
+			vfst.TestDoesNotExist(),
+		),
+		vfst.TestPath("/workspace/src/main.go",
+			vfst.TestDoesNotExist(),
+		),
+		vfst.TestPath("/outside",
+			vfst.TestContentsString("outside\n"),
+		),
+	)
+}
+
+func TestReadOnlyFileSystem(t *testing.T) {
+	fileSystem := vfst.NewTestFSWithT(t, map[string]any{
+		"/workspace/README.md": "# workspace\n",
+	})
+	readOnlyFS := vfs.NewReadOnlyFS(vfs.NewPathFS(fileSystem, "/workspace"))
+	server := httptest.NewServer(webdavfs.NewHandler(readOnlyFS, ""))
+	defer server.Close()
+	client := &davClient{t: t, server: server}
+
+	status, body := client.do(http.MethodGet, "/README.md", "", nil)
+	assert.Equal(t, http.StatusOK, status)
+	assert.Equal(t, "# workspace\n", body)
+	status, _ = client.do("PROPFIND", "/", "", map[string]string{"Depth": "1"})
+	assert.Equal(t, http.StatusMultiStatus, status)
+
+	for _, method := range []string{http.MethodPut, http.MethodDelete, "MKCOL", "MOVE", "COPY", "PROPPATCH", "LOCK"} {
+		status, _ := client.do(method, "/README.md", "", map[string]string{"Destination": server.URL + "/copy"})
+		assert.Equal(t, http.StatusForbidden, status, method)
+	}
+
+	// The underlying FileSystem also refuses modifications.
+	_, err := webdavfs.NewFileSystem(readOnlyFS).OpenFile(context.Background(), "/README.md", os.O_WRONLY, 0)
+	assert.Error(t, err)
+
+	vfst.RunTests(t, fileSystem, "",
+		vfst.TestPath("/workspace/README.md",
+			vfst.TestContentsString("# workspace\n"),
+		),
+		vfst.TestPath("/workspace/copy",
+			vfst.TestDoesNotExist(),
+		),
+	)
+}
+
+func TestWrappedReadOnlyFileSystem(t *testing.T) {
+	fileSystem := vfst.NewTestFSWithT(t, map[string]any{
+		"/workspace/README.md": "# workspace\n",
+	})
+	wrappedFS := vfs.NewPathFS(vfs.NewReadOnlyFS(fileSystem), "/workspace")
+	server := httptest.NewServer(webdavfs.NewHandler(wrappedFS, "", webdavfs.HandlerReadOnly(true)))
+	defer server.Close()
+	client := &davClient{t: t, server: server}
+
+	status, body := client.do(http.MethodGet, "/README.md", "", nil)
+	assert.Equal(t, http.StatusOK, status)
+	assert.Equal(t, "# workspace\n", body)
+
+	for _, method := range []string{http.MethodPut, "LOCK"} {
+		status, _ := client.do(method, "/README.md", "", nil)
+		assert.Equal(t, http.StatusForbidden, status, method)
+	}
+
+	vfst.RunTests(t, fileSystem, "",
+		vfst.TestPath("/workspace/README.md",
+			vfst.TestContentsString("# workspace\n"),
+		),
+	)
+}
+
+func TestFileSystemWithoutOpenFile(t *testing.T) {
+	fileSystem := vfst.NewTestFSWithT(t, map[string]any{
+		"/workspace": map[string]any{
+			"README.md":   "# workspace\n",
+			"src/main.go": "package main\n",
+		},
+	})
+	server := httptest.NewServer(webdavfs.NewHandler(noOpenFileFS{FS: vfs.NewPathFS(fileSystem, "/workspace")}, ""))
+	defer server.Close()
+	client := &davClient{t: t, server: server}
+
+	status, body := client.do(http.MethodGet, "/README.md", "", nil)
+	assert.Equal(t, http.StatusOK, status)
+	assert.Equal(t, "# workspace\n", body)
+
+	status, body = client.do("PROPFIND", "/", "", map[string]string{"Depth": "1"})
+	assert.Equal(t, http.StatusMultiStatus, status)
+	assert.True(t, strings.Contains(body, "/README.md"))
+	assert.True(t, strings.Contains(body, "/src/"))
+
+	status, _ = client.do(http.MethodPut, "/new", "new\n", nil)
+	assert.NotEqual(t, http.StatusCreated, status)
+}
@@ -0,0 +1,19 @@
+package vfs
+
+import "errors"
+
+// ErrNoXattr is returned when an extended attribute does not exist.
+var ErrNoXattr = errors.New("no such extended attribute")
+
+// An XattrFS is an FS that supports extended attributes. The L-prefixed
+// methods operate on symbolic links themselves rather than their targets.
+type XattrFS interface {
+	Getxattr(name, attr string) ([]byte, error)
+	Lgetxattr(name, attr string) ([]byte, error)
+	Listxattr(name string) ([]string, error)
+	Llistxattr(name string) ([]string, error)
+	Lremovexattr(name, attr string) error
+	Lsetxattr(name, attr string, value []byte) error
+	Removexattr(name, attr string) error
+	Setxattr(name, attr string, value []byte) error
+}
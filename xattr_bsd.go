@@ -0,0 +1,9 @@
+//go:build darwin || freebsd || netbsd
+
+package vfs
+
+import "golang.org/x/sys/unix"
+
+// errnoNoXattr is the errno returned when an extended attribute does not
+// exist.
+const errnoNoXattr = unix.ENOATTR
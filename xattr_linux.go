@@ -0,0 +1,7 @@
+package vfs
+
+import "golang.org/x/sys/unix"
+
+// errnoNoXattr is the errno returned when an extended attribute does not
+// exist.
+const errnoNoXattr = unix.ENODATA
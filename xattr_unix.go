@@ -0,0 +1,116 @@
+//go:build darwin || freebsd || linux || netbsd
+
+package vfs
+
+import (
+	"errors"
+	"os"
+	"sort"
+	"strings"
+
+	"golang.org/x/sys/unix"
+)
+
+// Getxattr implements XattrFS.Getxattr.
+func (osfs) Getxattr(name, attr string) ([]byte, error) {
+	return getxattr("Getxattr", name, attr, unix.Getxattr)
+}
+
+// Lgetxattr implements XattrFS.Lgetxattr.
+func (osfs) Lgetxattr(name, attr string) ([]byte, error) {
+	return getxattr("Lgetxattr", name, attr, unix.Lgetxattr)
+}
+
+// Listxattr implements XattrFS.Listxattr.
+func (osfs) Listxattr(name string) ([]string, error) {
+	return listxattr("Listxattr", name, unix.Listxattr)
+}
+
+// Llistxattr implements XattrFS.Llistxattr.
+func (osfs) Llistxattr(name string) ([]string, error) {
+	return listxattr("Llistxattr", name, unix.Llistxattr)
+}
+
+// Lremovexattr implements XattrFS.Lremovexattr.
+func (osfs) Lremovexattr(name, attr string) error {
+	return xattrError("Lremovexattr", name, unix.Lremovexattr(name, attr))
+}
+
+// Lsetxattr implements XattrFS.Lsetxattr.
+func (osfs) Lsetxattr(name, attr string, value []byte) error {
+	return xattrError("Lsetxattr", name, unix.Lsetxattr(name, attr, value, 0))
+}
+
+// Removexattr implements XattrFS.Removexattr.
+func (osfs) Removexattr(name, attr string) error {
+	return xattrError("Removexattr", name, unix.Removexattr(name, attr))
+}
+
+// Setxattr implements XattrFS.Setxattr.
+func (osfs) Setxattr(name, attr string, value []byte) error {
+	return xattrError("Setxattr", name, unix.Setxattr(name, attr, value, 0))
+}
+
+// getxattr returns the value of attr on name using get.
+func getxattr(op, name, attr string, get func(string, string, []byte) (int, error)) ([]byte, error) {
+	for {
+		size, err := get(name, attr, nil)
+		if err != nil {
+			return nil, xattrError(op, name, err)
+		}
+		value := make([]byte, size)
+		n, err := get(name, attr, value)
+		switch {
+		case errors.Is(err, unix.ERANGE), err == nil && n > len(value):
+			// The value grew between the two calls, so try again. If the
+			// value was empty then value is a zero-length buffer, which is
+			// treated as another size probe, so n is the new size.
+			continue
+		case err != nil:
+			return nil, xattrError(op, name, err)
+		default:
+			return value[:n], nil
+		}
+	}
+}
+
+// listxattr returns the sorted names of the extended attributes of name using
+// list.
+func listxattr(op, name string, list func(string, []byte) (int, error)) ([]string, error) {
+	for {
+		size, err := list(name, nil)
+		if err != nil {
+			return nil, xattrError(op, name, err)
+		}
+		if size == 0 {
+			return nil, nil
+		}
+		buf := make([]byte, size)
+		n, err := list(name, buf)
+		switch {
+		case errors.Is(err, unix.ERANGE):
+			continue
+		case err != nil:
+			return nil, xattrError(op, name, err)
+		}
+		attrs := strings.Split(strings.TrimSuffix(string(buf[:n]), "\x00"), "\x00")
+		sort.Strings(attrs)
+		return attrs, nil
+	}
+}
+
+// xattrError returns err wrapped in an *os.PathError, replacing the errno for
+// a missing extended attribute with ErrNoXattr.
+func xattrError(op, name string, err error) error {
+	switch {
+	case err == nil:
+		return nil
+	case errors.Is(err, errnoNoXattr):
+		err = ErrNoXattr
+	}
+	return &os.PathError{
+		Op:   op,
+		Path: name,
+		Err:  err,
+	}
+}